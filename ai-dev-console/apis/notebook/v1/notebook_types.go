@@ -58,6 +58,18 @@ type NotebookCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+// StateCondition returns the type of the newest condition telling the state of
+// the notebook, skipping the CullingScheduled and CullingSnoozed conditions the
+// culler keeps along, or an empty string if there is none yet.
+func (s NotebookStatus) StateCondition() string {
+	for _, c := range s.Conditions {
+		if c.Type != "CullingScheduled" && c.Type != "CullingSnoozed" {
+			return c.Type
+		}
+	}
+	return ""
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:storageversion
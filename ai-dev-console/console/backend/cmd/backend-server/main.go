@@ -53,6 +53,14 @@ func main() {
 	}()
 
 	pflag.Parse()
+	if pflag.Arg(0) == "migrate" {
+		if err := runMigrate(pflag.Args()[1:]); err != nil {
+			klog.Errorf("fail to migrate database schema: %v", err)
+			klog.Flush()
+			os.Exit(1)
+		}
+		return
+	}
 	clientmgr.Init()
 	client.Init()
 	registry.RegisterStorageBackends()
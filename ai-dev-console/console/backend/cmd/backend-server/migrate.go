@@ -0,0 +1,82 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/objects/mysql"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+)
+
+func init() {
+	pflag.Int64Var(&migrateTo, "migrate-to", -1, "schema version to migrate to by the migrate command, defaults to the latest one for up and the previous one for down")
+}
+
+var migrateTo int64
+
+// runMigrate runs `backend-server migrate [up|down|version]`, which migrates
+// the schema of mysql object backend up or down to --migrate-to, or prints
+// the version of the schema.
+func runMigrate(args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	db, err := mysql.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := mysql.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "up":
+		target := migrateTo
+		if target < 0 {
+			target = migrator.Latest()
+		}
+		if err := migrator.Up(target); err != nil {
+			return err
+		}
+	case "down":
+		target := migrateTo
+		if target < 0 {
+			target = version - 1
+		}
+		if err := migrator.Down(target); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate action %s, should be one of up, down or version", action)
+	}
+
+	if version, err = migrator.Version(); err != nil {
+		return err
+	}
+	klog.Infof("database schema is at version %d, latest is %d", version, migrator.Latest())
+	return nil
+}
@@ -28,7 +28,6 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
 )
 
 type EvaluateHandler struct {
@@ -131,89 +130,3 @@ type SearchItem struct {
 }
 
 type SearchArray []SearchItem
-
-type MetricsItem struct {
-	SearchItem
-	Metrics map[string]interface{} `json:"metrics"`
-}
-
-type CompareData struct {
-	Names      []string                 `json:"names"`
-	Namespaces []string                 `json:"namespaces"`
-	Metrics    map[string][]interface{} `json:"metrics"`
-}
-
-func (eh *EvaluateHandler) CompareEvaluateJobs(array SearchArray) (CompareData, error) {
-	metricsItems := make([]MetricsItem, 0)
-	metricsRecord := make(map[string]int)
-	for _, item := range array {
-		tempEvaluateJobInfo, err := eh.GetEvaluateJobData(item.Namespace, item.Name, item.ID)
-		if err != nil {
-			klog.Errorf("get job failed, err:%v", err)
-			return CompareData{}, nil
-		}
-
-		var itemMetrics string
-		if tempEvaluateJobInfo.Metrics == "" {
-			itemMetrics = "{}"
-		} else {
-			itemMetrics = strings.Replace(tempEvaluateJobInfo.Metrics, "'", "\"", -1)
-		}
-
-		metrics := make(map[string]interface{})
-		err = json.Unmarshal([]byte(itemMetrics), &metrics)
-		if err != nil {
-			klog.Errorf("get job failed, err:%v", err)
-			fmt.Println("Unmarshal err:", err)
-			return CompareData{}, err
-		}
-
-		for key, _ := range metrics {
-			metricsRecord[key]++
-		}
-
-		itemMetricsItem := MetricsItem{
-			SearchItem: SearchItem{
-				Name:      item.Name,
-				Namespace: item.Namespace,
-			},
-			Metrics: metrics,
-		}
-		metricsItems = append(metricsItems, itemMetricsItem)
-	}
-
-	intersection := make([]string, 0)
-	length := len(array)
-
-	metricsRecord["ROC"]--
-
-	for key, value := range metricsRecord {
-		if value == length {
-			intersection = append(intersection, key)
-		}
-	}
-
-	if len(intersection) < 1 {
-		return CompareData{}, nil
-	}
-
-	result := CompareData{
-		Names:      []string{},
-		Namespaces: []string{},
-		Metrics:    map[string][]interface{}{},
-	}
-
-	for _, item := range intersection {
-		result.Metrics[item] = make([]interface{}, 0, len(metricsItems))
-	}
-
-	for index, _ := range metricsItems {
-		result.Names = append(result.Names, metricsItems[index].Name)
-		result.Namespaces = append(result.Names, metricsItems[index].Namespace)
-		for _, item := range intersection {
-			result.Metrics[item] = append(result.Metrics[item], metricsItems[index].Metrics[item])
-		}
-	}
-
-	return result, nil
-}
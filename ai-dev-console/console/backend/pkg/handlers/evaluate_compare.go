@@ -0,0 +1,363 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MetricsItem is the metrics reported by an evaluate job.
+type MetricsItem struct {
+	SearchItem
+	Metrics map[string]interface{} `json:"metrics"`
+}
+
+// CompareData compares metrics of evaluate jobs, values of each metric are in
+// the order of jobs, and are null for jobs which did not report the metric.
+type CompareData struct {
+	IDs        []string `json:"ids"`
+	Names      []string `json:"names"`
+	Namespaces []string `json:"namespaces"`
+	// Metrics are scalar metrics, nested ones are keyed by their dotted paths,
+	// e.g. per_class.cat.precision.
+	Metrics map[string][]interface{} `json:"metrics"`
+	// Curves are array metrics, e.g. ROC and PR curves.
+	Curves map[string][]interface{} `json:"curves"`
+	// Ranking orders jobs by the metric RankBy, jobs which did not report the
+	// metric are ranked last without a rank.
+	RankBy  string        `json:"rank_by,omitempty"`
+	Ranking []RankingItem `json:"ranking,omitempty"`
+}
+
+type RankingItem struct {
+	SearchItem
+	Rank  int      `json:"rank,omitempty"`
+	Value *float64 `json:"value"`
+}
+
+// CompareEvaluateJobs compares metrics of evaluate jobs, and ranks them by the
+// metric rankBy if it is specified.
+func (eh *EvaluateHandler) CompareEvaluateJobs(array SearchArray, rankBy string, ascending bool) (CompareData, error) {
+	metricsItems := make([]MetricsItem, 0, len(array))
+	for _, item := range array {
+		evaluateJobInfo, err := eh.GetEvaluateJobData(item.Namespace, item.Name, item.ID)
+		if err != nil {
+			return CompareData{}, fmt.Errorf("fail to get evaluate job %s/%s(%s): %v", item.Namespace, item.Name, item.ID, err)
+		}
+		metrics, err := parseMetrics(evaluateJobInfo.Metrics)
+		if err != nil {
+			return CompareData{}, fmt.Errorf("invalid metrics of evaluate job %s/%s(%s): %v", item.Namespace, item.Name, item.ID, err)
+		}
+		metricsItems = append(metricsItems, MetricsItem{SearchItem: item, Metrics: metrics})
+	}
+	return compareMetrics(metricsItems, rankBy, ascending)
+}
+
+func compareMetrics(metricsItems []MetricsItem, rankBy string, ascending bool) (CompareData, error) {
+	result := CompareData{
+		IDs:        make([]string, 0, len(metricsItems)),
+		Names:      make([]string, 0, len(metricsItems)),
+		Namespaces: make([]string, 0, len(metricsItems)),
+		Metrics:    map[string][]interface{}{},
+		Curves:     map[string][]interface{}{},
+	}
+
+	for index, item := range metricsItems {
+		result.IDs = append(result.IDs, item.ID)
+		result.Names = append(result.Names, item.Name)
+		result.Namespaces = append(result.Namespaces, item.Namespace)
+
+		scalars, curves := map[string]interface{}{}, map[string]interface{}{}
+		flattenMetrics("", item.Metrics, scalars, curves)
+		for key, value := range scalars {
+			addMetric(result.Metrics, key, index, len(metricsItems), value)
+		}
+		for key, value := range curves {
+			addMetric(result.Curves, key, index, len(metricsItems), value)
+		}
+	}
+
+	if rankBy == "" {
+		return result, nil
+	}
+	values, ok := result.Metrics[rankBy]
+	if !ok {
+		return CompareData{}, fmt.Errorf("no scalar metric %s to rank by", rankBy)
+	}
+	result.RankBy = rankBy
+	result.Ranking = rankMetrics(metricsItems, values, ascending)
+	return result, nil
+}
+
+// addMetric sets the value of a metric reported by the index-th job, values of
+// jobs which did not report the metric are left null.
+func addMetric(metrics map[string][]interface{}, key string, index, length int, value interface{}) {
+	values, ok := metrics[key]
+	if !ok {
+		values = make([]interface{}, length)
+		metrics[key] = values
+	}
+	values[index] = value
+}
+
+// flattenMetrics flattens nested metrics into scalars and curves keyed by
+// their dotted paths. Arrays, and objects of arrays such as
+// {"fpr": [...], "tpr": [...]}, are curves.
+func flattenMetrics(prefix string, metrics map[string]interface{}, scalars, curves map[string]interface{}) {
+	for key, value := range metrics {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch typed := value.(type) {
+		case []interface{}:
+			curves[key] = typed
+		case map[string]interface{}:
+			if isCurve(typed) {
+				curves[key] = typed
+			} else {
+				flattenMetrics(key, typed, scalars, curves)
+			}
+		default:
+			scalars[key] = typed
+		}
+	}
+}
+
+func isCurve(metrics map[string]interface{}) bool {
+	if len(metrics) == 0 {
+		return false
+	}
+	for _, value := range metrics {
+		if _, ok := value.([]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func rankMetrics(metricsItems []MetricsItem, values []interface{}, ascending bool) []RankingItem {
+	ranking := make([]RankingItem, 0, len(metricsItems))
+	for index, item := range metricsItems {
+		rankingItem := RankingItem{SearchItem: item.SearchItem}
+		if value, ok := values[index].(float64); ok {
+			rankingItem.Value = &value
+		}
+		ranking = append(ranking, rankingItem)
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		vi, vj := ranking[i].Value, ranking[j].Value
+		if vi == nil || vj == nil {
+			return vi != nil
+		}
+		if ascending {
+			return *vi < *vj
+		}
+		return *vi > *vj
+	})
+	for index := range ranking {
+		if ranking[index].Value == nil {
+			break
+		}
+		ranking[index].Rank = index + 1
+		// Jobs with equal values share the same rank.
+		if index > 0 && *ranking[index].Value == *ranking[index-1].Value {
+			ranking[index].Rank = ranking[index-1].Rank
+		}
+	}
+	return ranking
+}
+
+// WriteCSV exports the comparison as CSV, a row for each job and a column for
+// each metric, curves are encoded in JSON.
+func (data *CompareData) WriteCSV(w io.Writer) error {
+	metricKeys := sortedKeys(data.Metrics)
+	curveKeys := sortedKeys(data.Curves)
+	ranks := make(map[SearchItem]int, len(data.Ranking))
+	for _, item := range data.Ranking {
+		ranks[item.SearchItem] = item.Rank
+	}
+
+	writer := csv.NewWriter(w)
+	header := []string{"id", "name", "namespace"}
+	if data.RankBy != "" {
+		header = append(header, "rank")
+	}
+	header = append(append(header, metricKeys...), curveKeys...)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for index := range data.IDs {
+		row := []string{data.IDs[index], data.Names[index], data.Namespaces[index]}
+		if data.RankBy != "" {
+			rank := ""
+			if r := ranks[SearchItem{ID: data.IDs[index], Name: data.Names[index], Namespace: data.Namespaces[index]}]; r > 0 {
+				rank = strconv.Itoa(r)
+			}
+			row = append(row, rank)
+		}
+		for _, key := range metricKeys {
+			row = append(row, csvValue(data.Metrics[key][index]))
+		}
+		for _, key := range curveKeys {
+			row = append(row, csvValue(data.Curves[key][index]))
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func sortedKeys(metrics map[string][]interface{}) []string {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func csvValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'g', -1, 64)
+	default:
+		data, _ := json.Marshal(typed)
+		return string(data)
+	}
+}
+
+// parseMetrics parses metrics reported by evaluate jobs, which are either JSON
+// or Python dict literals, e.g. {'accuracy': 0.9, 'auc': nan}.
+func parseMetrics(raw string) (map[string]interface{}, error) {
+	metrics := make(map[string]interface{})
+	if strings.TrimSpace(raw) == "" {
+		return metrics, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &metrics); err == nil {
+		return metrics, nil
+	}
+	converted, err := pythonLiteralToJSON(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(converted), &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// pythonLiteralToJSON converts a Python literal of dicts, lists, tuples,
+// strings, numbers, booleans and None to JSON. nan and inf have no JSON
+// representation and are converted to null.
+func pythonLiteralToJSON(literal string) (string, error) {
+	var builder strings.Builder
+	runes := []rune(literal)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'' || r == '"':
+			value, end, err := readPythonString(runes, i)
+			if err != nil {
+				return "", err
+			}
+			data, _ := json.Marshal(value)
+			builder.Write(data)
+			i = end
+		case r == '(':
+			builder.WriteRune('[')
+		case r == ')':
+			builder.WriteRune(']')
+		case unicode.IsLetter(r) || r == '_':
+			end := i
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			word := string(runes[i:end])
+			// A letter following a digit belongs to the number, e.g. 1e-05.
+			if i > 0 && unicode.IsDigit(runes[i-1]) {
+				builder.WriteString(word)
+				i = end - 1
+				continue
+			}
+			switch word {
+			case "True":
+				builder.WriteString("true")
+			case "False":
+				builder.WriteString("false")
+			case "None":
+				builder.WriteString("null")
+			case "nan", "NaN", "inf", "Infinity":
+				// Drop the sign of -inf written already.
+				converted := strings.TrimSuffix(builder.String(), "-")
+				builder.Reset()
+				builder.WriteString(converted)
+				builder.WriteString("null")
+			default:
+				return "", fmt.Errorf("unexpected %s in metrics", word)
+			}
+			i = end - 1
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String(), nil
+}
+
+// readPythonString reads a quoted Python string starting at runes[start], and
+// returns its value and the index of the closing quote.
+func readPythonString(runes []rune, start int) (string, int, error) {
+	quote := runes[start]
+	var builder strings.Builder
+	for i := start + 1; i < len(runes); i++ {
+		r := runes[i]
+		if r == quote {
+			return builder.String(), i, nil
+		}
+		if r != '\\' || i+1 == len(runes) {
+			builder.WriteRune(r)
+			continue
+		}
+		i++
+		switch runes[i] {
+		case 'n':
+			builder.WriteRune('\n')
+		case 't':
+			builder.WriteRune('\t')
+		case 'r':
+			builder.WriteRune('\r')
+		case '\\', '\'', '"':
+			builder.WriteRune(runes[i])
+		default:
+			builder.WriteRune('\\')
+			builder.WriteRune(runes[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string in metrics")
+}
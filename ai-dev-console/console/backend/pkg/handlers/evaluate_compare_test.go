@@ -0,0 +1,402 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func TestPythonLiteralToJSON(t *testing.T) {
+	testCases := []struct {
+		testName string
+		literal  string
+		expected string
+		wantErr  bool
+	}{
+		{
+			testName: "single quoted keys",
+			literal:  `{'accuracy': 0.9, 'loss': 0.1}`,
+			expected: `{"accuracy": 0.9, "loss": 0.1}`,
+		},
+		{
+			testName: "quotes inside strings",
+			literal:  `{'label': "cat's", "name": 'say "hi"'}`,
+			expected: `{"label": "cat's", "name": "say \"hi\""}`,
+		},
+		{
+			testName: "escapes",
+			literal:  `{'path': 'a\\b', 'text': 'it\'s\n', 'raw': '\d'}`,
+			expected: `{"path": "a\\b", "text": "it's\n", "raw": "\\d"}`,
+		},
+		{
+			testName: "nan and infinities",
+			literal:  `{'a': nan, 'b': -inf, 'c': inf, 'd': NaN}`,
+			expected: `{"a": null, "b": null, "c": null, "d": null}`,
+		},
+		{
+			testName: "exponents",
+			literal:  `{'lr': 1e-05, 'eps': 2.5E+10}`,
+			expected: `{"lr": 1e-05, "eps": 2.5E+10}`,
+		},
+		{
+			testName: "tuples",
+			literal:  `{'shape': (28, 28), 'roc': ((0.0, 0.1), (1.0, 0.9))}`,
+			expected: `{"shape": [28, 28], "roc": [[0.0, 0.1], [1.0, 0.9]]}`,
+		},
+		{
+			testName: "booleans and None",
+			literal:  `{'converged': True, 'early_stopped': False, 'best': None}`,
+			expected: `{"converged": true, "early_stopped": false, "best": null}`,
+		},
+		{
+			testName: "unknown name",
+			literal:  `{'model': resnet}`,
+			wantErr:  true,
+		},
+		{
+			testName: "unterminated string",
+			literal:  `{'accuracy: 0.9}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			converted, err := pythonLiteralToJSON(testCase.literal)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("pythonLiteralToJSON() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if converted != testCase.expected {
+				t.Errorf("pythonLiteralToJSON() = %s, want %s", converted, testCase.expected)
+			}
+			if !testCase.wantErr && !json.Valid([]byte(converted)) {
+				t.Errorf("pythonLiteralToJSON() = %s, want valid JSON", converted)
+			}
+		})
+	}
+}
+
+func TestParseMetrics(t *testing.T) {
+	testCases := []struct {
+		testName string
+		raw      string
+		expected map[string]interface{}
+		wantErr  bool
+	}{
+		{
+			testName: "empty",
+			raw:      " ",
+			expected: map[string]interface{}{},
+		},
+		{
+			testName: "json",
+			raw:      `{"accuracy": 0.9}`,
+			expected: map[string]interface{}{"accuracy": 0.9},
+		},
+		{
+			testName: "python literal",
+			raw:      `{'accuracy': 0.9, 'auc': nan}`,
+			expected: map[string]interface{}{"accuracy": 0.9, "auc": nil},
+		},
+		{
+			testName: "not a dict",
+			raw:      `[0.9]`,
+			wantErr:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			metrics, err := parseMetrics(testCase.raw)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("parseMetrics() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if !testCase.wantErr && !reflect.DeepEqual(metrics, testCase.expected) {
+				t.Errorf("parseMetrics() = %v, want %v", metrics, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestFlattenMetrics(t *testing.T) {
+	testCases := []struct {
+		testName string
+		metrics  map[string]interface{}
+		scalars  map[string]interface{}
+		curves   map[string]interface{}
+	}{
+		{
+			testName: "scalars",
+			metrics:  map[string]interface{}{"accuracy": 0.9, "model": "resnet", "best": nil},
+			scalars:  map[string]interface{}{"accuracy": 0.9, "model": "resnet", "best": nil},
+			curves:   map[string]interface{}{},
+		},
+		{
+			testName: "nested scalars",
+			metrics: map[string]interface{}{
+				"per_class": map[string]interface{}{
+					"cat": map[string]interface{}{"precision": 0.8},
+					"dog": map[string]interface{}{"precision": 0.7},
+				},
+			},
+			scalars: map[string]interface{}{"per_class.cat.precision": 0.8, "per_class.dog.precision": 0.7},
+			curves:  map[string]interface{}{},
+		},
+		{
+			testName: "curves",
+			metrics: map[string]interface{}{
+				"loss": []interface{}{0.5, 0.3},
+				"roc":  map[string]interface{}{"fpr": []interface{}{0.0, 1.0}, "tpr": []interface{}{0.0, 1.0}},
+				"eval": map[string]interface{}{"pr": map[string]interface{}{"precision": []interface{}{1.0}, "recall": []interface{}{0.5}}, "f1": 0.6},
+			},
+			scalars: map[string]interface{}{"eval.f1": 0.6},
+			curves: map[string]interface{}{
+				"loss":    []interface{}{0.5, 0.3},
+				"roc":     map[string]interface{}{"fpr": []interface{}{0.0, 1.0}, "tpr": []interface{}{0.0, 1.0}},
+				"eval.pr": map[string]interface{}{"precision": []interface{}{1.0}, "recall": []interface{}{0.5}},
+			},
+		},
+		{
+			testName: "empty object",
+			metrics:  map[string]interface{}{"extra": map[string]interface{}{}},
+			scalars:  map[string]interface{}{},
+			curves:   map[string]interface{}{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			scalars, curves := map[string]interface{}{}, map[string]interface{}{}
+			flattenMetrics("", testCase.metrics, scalars, curves)
+			if !reflect.DeepEqual(scalars, testCase.scalars) {
+				t.Errorf("scalars = %v, want %v", scalars, testCase.scalars)
+			}
+			if !reflect.DeepEqual(curves, testCase.curves) {
+				t.Errorf("curves = %v, want %v", curves, testCase.curves)
+			}
+		})
+	}
+}
+
+func TestRankMetrics(t *testing.T) {
+	jobs := []MetricsItem{
+		{SearchItem: SearchItem{ID: "1", Name: "eval-a"}},
+		{SearchItem: SearchItem{ID: "2", Name: "eval-b"}},
+		{SearchItem: SearchItem{ID: "3", Name: "eval-c"}},
+		{SearchItem: SearchItem{ID: "4", Name: "eval-d"}},
+	}
+
+	testCases := []struct {
+		testName  string
+		values    []interface{}
+		ascending bool
+		expected  []RankingItem
+	}{
+		{
+			testName: "descending",
+			values:   []interface{}{0.7, 0.9, 0.8, 0.6},
+			expected: []RankingItem{
+				{SearchItem: jobs[1].SearchItem, Rank: 1, Value: float(0.9)},
+				{SearchItem: jobs[2].SearchItem, Rank: 2, Value: float(0.8)},
+				{SearchItem: jobs[0].SearchItem, Rank: 3, Value: float(0.7)},
+				{SearchItem: jobs[3].SearchItem, Rank: 4, Value: float(0.6)},
+			},
+		},
+		{
+			testName:  "ascending",
+			values:    []interface{}{0.7, 0.9, 0.8, 0.6},
+			ascending: true,
+			expected: []RankingItem{
+				{SearchItem: jobs[3].SearchItem, Rank: 1, Value: float(0.6)},
+				{SearchItem: jobs[0].SearchItem, Rank: 2, Value: float(0.7)},
+				{SearchItem: jobs[2].SearchItem, Rank: 3, Value: float(0.8)},
+				{SearchItem: jobs[1].SearchItem, Rank: 4, Value: float(0.9)},
+			},
+		},
+		{
+			testName: "shared ranks",
+			values:   []interface{}{0.9, 0.8, 0.9, 0.7},
+			expected: []RankingItem{
+				{SearchItem: jobs[0].SearchItem, Rank: 1, Value: float(0.9)},
+				{SearchItem: jobs[2].SearchItem, Rank: 1, Value: float(0.9)},
+				{SearchItem: jobs[1].SearchItem, Rank: 3, Value: float(0.8)},
+				{SearchItem: jobs[3].SearchItem, Rank: 4, Value: float(0.7)},
+			},
+		},
+		{
+			testName:  "jobs without the metric unranked",
+			values:    []interface{}{nil, 0.9, "n/a", 0.8},
+			ascending: true,
+			expected: []RankingItem{
+				{SearchItem: jobs[3].SearchItem, Rank: 1, Value: float(0.8)},
+				{SearchItem: jobs[1].SearchItem, Rank: 2, Value: float(0.9)},
+				{SearchItem: jobs[0].SearchItem},
+				{SearchItem: jobs[2].SearchItem},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			ranking := rankMetrics(jobs, testCase.values, testCase.ascending)
+			if !reflect.DeepEqual(ranking, testCase.expected) {
+				t.Errorf("rankMetrics() = %s, want %s", toJSON(ranking), toJSON(testCase.expected))
+			}
+		})
+	}
+}
+
+func TestCompareMetrics(t *testing.T) {
+	jobs := []MetricsItem{
+		{SearchItem: SearchItem{ID: "1", Name: "eval-a", Namespace: "default"}, Metrics: map[string]interface{}{"accuracy": 0.9, "f1": 0.8, "loss": []interface{}{0.5, 0.3}}},
+		{SearchItem: SearchItem{ID: "2", Name: "eval-b", Namespace: "default"}, Metrics: map[string]interface{}{"accuracy": 0.7}},
+		{SearchItem: SearchItem{ID: "3", Name: "eval-c", Namespace: "default"}, Metrics: map[string]interface{}{"accuracy": 0.8, "f1": 0.6}},
+	}
+
+	testCases := []struct {
+		testName string
+		rankBy   string
+		expected CompareData
+		wantErr  bool
+	}{
+		{
+			testName: "union of metrics",
+			expected: CompareData{
+				IDs:        []string{"1", "2", "3"},
+				Names:      []string{"eval-a", "eval-b", "eval-c"},
+				Namespaces: []string{"default", "default", "default"},
+				Metrics: map[string][]interface{}{
+					"accuracy": {0.9, 0.7, 0.8},
+					"f1":       {0.8, nil, 0.6},
+				},
+				Curves: map[string][]interface{}{
+					"loss": {[]interface{}{0.5, 0.3}, nil, nil},
+				},
+			},
+		},
+		{
+			testName: "ranked by a metric some jobs lack",
+			rankBy:   "f1",
+			expected: CompareData{
+				IDs:        []string{"1", "2", "3"},
+				Names:      []string{"eval-a", "eval-b", "eval-c"},
+				Namespaces: []string{"default", "default", "default"},
+				Metrics: map[string][]interface{}{
+					"accuracy": {0.9, 0.7, 0.8},
+					"f1":       {0.8, nil, 0.6},
+				},
+				Curves: map[string][]interface{}{
+					"loss": {[]interface{}{0.5, 0.3}, nil, nil},
+				},
+				RankBy: "f1",
+				Ranking: []RankingItem{
+					{SearchItem: jobs[0].SearchItem, Rank: 1, Value: float(0.8)},
+					{SearchItem: jobs[2].SearchItem, Rank: 2, Value: float(0.6)},
+					{SearchItem: jobs[1].SearchItem},
+				},
+			},
+		},
+		{
+			testName: "ranked by a curve",
+			rankBy:   "loss",
+			wantErr:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			data, err := compareMetrics(jobs, testCase.rankBy, false)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("compareMetrics() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if !testCase.wantErr && !reflect.DeepEqual(data, testCase.expected) {
+				t.Errorf("compareMetrics() = %s, want %s", toJSON(data), toJSON(testCase.expected))
+			}
+		})
+	}
+}
+
+func TestCompareDataWriteCSV(t *testing.T) {
+	testCases := []struct {
+		testName string
+		data     CompareData
+		expected string
+	}{
+		{
+			testName: "metrics and curves",
+			data: CompareData{
+				IDs:        []string{"1", "2"},
+				Names:      []string{"eval-a", "eval-b"},
+				Namespaces: []string{"default", "default"},
+				Metrics: map[string][]interface{}{
+					"f1":       {nil, 0.6},
+					"accuracy": {0.9, 1e-05},
+					"model":    {"resnet, v2", true},
+				},
+				Curves: map[string][]interface{}{
+					"roc": {map[string]interface{}{"fpr": []interface{}{0.0, 1.0}}, nil},
+				},
+			},
+			expected: "id,name,namespace,accuracy,f1,model,roc\n" +
+				"1,eval-a,default,0.9,,\"resnet, v2\",\"{\"\"fpr\"\":[0,1]}\"\n" +
+				"2,eval-b,default,1e-05,0.6,true,\n",
+		},
+		{
+			testName: "ranked",
+			data: CompareData{
+				IDs:        []string{"1", "2", "3"},
+				Names:      []string{"eval-a", "eval-b", "eval-c"},
+				Namespaces: []string{"default", "default", "default"},
+				Metrics:    map[string][]interface{}{"f1": {0.8, nil, 0.9}},
+				Curves:     map[string][]interface{}{},
+				RankBy:     "f1",
+				Ranking: []RankingItem{
+					{SearchItem: SearchItem{ID: "3", Name: "eval-c", Namespace: "default"}, Rank: 1, Value: float(0.9)},
+					{SearchItem: SearchItem{ID: "1", Name: "eval-a", Namespace: "default"}, Rank: 2, Value: float(0.8)},
+					{SearchItem: SearchItem{ID: "2", Name: "eval-b", Namespace: "default"}},
+				},
+			},
+			expected: "id,name,namespace,rank,f1\n" +
+				"1,eval-a,default,2,0.8\n" +
+				"2,eval-b,default,,\n" +
+				"3,eval-c,default,1,0.9\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			buffer := &bytes.Buffer{}
+			if err := testCase.data.WriteCSV(buffer); err != nil {
+				t.Fatalf("fail to write csv: %v", err)
+			}
+			if buffer.String() != testCase.expected {
+				t.Errorf("WriteCSV() = %q, want %q", buffer.String(), testCase.expected)
+			}
+		})
+	}
+}
+
+func toJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
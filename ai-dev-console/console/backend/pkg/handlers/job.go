@@ -31,6 +31,7 @@ import (
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/clientmgr"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/registry"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/utils"
+	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
 
 	"gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
@@ -61,10 +62,11 @@ func NewJobHandler(objStorage, clientTypeName string, logHandler *LogHandler) (*
 		return nil, err
 	}
 	return &JobHandler{
-		logHandler:    logHandler,
-		objectBackend: objBackend,
-		clientBackend: clientBackend,
-		client:        clientmgr.GetCtrlClient(),
+		logHandler:        logHandler,
+		objectBackend:     objBackend,
+		clientBackend:     clientBackend,
+		client:            clientmgr.GetCtrlClient(),
+		dataSourceHandler: NewDataSourceHandler(),
 		preSubmitHooks: []preSubmitHook{
 			tfJobPreSubmitAutoConvertReplicas,
 			pytorchJobPreSubmitAutoConvertReplicas,
@@ -73,11 +75,12 @@ func NewJobHandler(objStorage, clientTypeName string, logHandler *LogHandler) (*
 }
 
 type JobHandler struct {
-	client         client.Client
-	logHandler     *LogHandler
-	objectBackend  backends.ObjectStorageBackend
-	clientBackend  backends.ObjectClientBackend
-	preSubmitHooks []preSubmitHook
+	client            client.Client
+	logHandler        *LogHandler
+	objectBackend     backends.ObjectStorageBackend
+	clientBackend     backends.ObjectClientBackend
+	dataSourceHandler *DataSourceHandler
+	preSubmitHooks    []preSubmitHook
 }
 
 func (jh *JobHandler) GetJobFromBackend(userName, ns, jobId, jobName, kind, region string) (model.JobInfo, error) {
@@ -206,6 +209,9 @@ func (jh *JobHandler) GetJobJsonData(userName, ns, name, kind string) ([]byte, e
 }
 
 func (jh *JobHandler) SubmitJob(userName string, jobInfo *dmo.SubmitJobInfo) error {
+	if err := annotateOutputModel(jobInfo, jh.dataSourceHandler.ListDataSourceFromConfigMap); err != nil {
+		return err
+	}
 	return jh.clientBackend.UserName(userName).SubmitJob(jobInfo)
 }
 
@@ -217,9 +223,44 @@ func (jh *JobHandler) SubmitJobWithKind(userName string, data []byte, kind strin
 	}
 
 	klog.Infof("received submit job args: %v", string(data))
+	if err = annotateOutputModel(&job.SubmitJobInfo, jh.dataSourceHandler.ListDataSourceFromConfigMap); err != nil {
+		return err
+	}
 	return jh.clientBackend.UserName(userName).SubmitJob(&job.SubmitJobInfo)
 }
 
+// annotateOutputModel annotates the output model declared by a job, so that
+// the model is registered by ModelRegistrar once the job succeeded. The model
+// is rejected unless its data source exists and is backed by a pvc.
+func annotateOutputModel(jobInfo *dmo.SubmitJobInfo, listDataSources func(userName string) (model.DataSourceMap, error)) error {
+	output := jobInfo.OutputModel
+	if output == nil {
+		return nil
+	}
+	if output.Name == "" || output.DataSource == "" || output.Path == "" {
+		return fmt.Errorf("name, dataSource and path of output model should not be empty")
+	}
+	dataSources, err := listDataSources("")
+	if err != nil {
+		return err
+	}
+	if _, err = outputDataSource(dataSources, output); err != nil {
+		return err
+	}
+	if output.CodeSource == "" {
+		output.CodeSource = jobInfo.CodeSource
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		return err
+	}
+	if jobInfo.Annotations == nil {
+		jobInfo.Annotations = make(map[string]string)
+	}
+	jobInfo.Annotations[apiv1.AnnotationOutputModel] = string(data)
+	return nil
+}
+
 func (jh *JobHandler) submitJob(job client.Object) error {
 	for _, hook := range jh.preSubmitHooks {
 		hook(job)
@@ -0,0 +1,94 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/model"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
+)
+
+func TestAnnotateOutputModel(t *testing.T) {
+	listDataSources := func(userName string) (model.DataSourceMap, error) {
+		return testDataSources, nil
+	}
+
+	testCases := []struct {
+		testName        string
+		output          *dmo.OutputModel
+		listDataSources func(userName string) (model.DataSourceMap, error)
+		expected        string
+		wantErr         bool
+	}{
+		{
+			testName: "no output model",
+		},
+		{
+			testName:        "output model",
+			output:          &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "mnist"},
+			listDataSources: listDataSources,
+			expected:        `{"name":"mnist","dataSource":"models","path":"mnist","codeSource":"mnist-code"}`,
+		},
+		{
+			testName:        "code source of output model kept",
+			output:          &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "mnist", CodeSource: "other"},
+			listDataSources: listDataSources,
+			expected:        `{"name":"mnist","dataSource":"models","path":"mnist","codeSource":"other"}`,
+		},
+		{
+			testName:        "name absent",
+			output:          &dmo.OutputModel{DataSource: "models", Path: "mnist"},
+			listDataSources: listDataSources,
+			wantErr:         true,
+		},
+		{
+			testName:        "data source absent",
+			output:          &dmo.OutputModel{Name: "mnist", DataSource: "removed", Path: "mnist"},
+			listDataSources: listDataSources,
+			wantErr:         true,
+		},
+		{
+			testName:        "data source without pvc",
+			output:          &dmo.OutputModel{Name: "mnist", DataSource: "oss", Path: "mnist"},
+			listDataSources: listDataSources,
+			wantErr:         true,
+		},
+		{
+			testName: "data sources unavailable",
+			output:   &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "mnist"},
+			listDataSources: func(userName string) (model.DataSourceMap, error) {
+				return nil, fmt.Errorf("configmap unavailable")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			jobInfo := &dmo.SubmitJobInfo{Name: "mnist-train", CodeSource: "mnist-code", OutputModel: testCase.output}
+			err := annotateOutputModel(jobInfo, testCase.listDataSources)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("annotateOutputModel() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if annotation := jobInfo.Annotations[apiv1.AnnotationOutputModel]; annotation != testCase.expected {
+				t.Errorf("annotation = %s, want %s", annotation, testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/model"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/clientmgr"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/registry"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// modelRegistrationLookback bounds jobs looked up by ModelRegistrar, jobs
+	// created earlier are not registered.
+	modelRegistrationLookback = 7 * 24 * time.Hour
+)
+
+// ModelRegistrar registers the output models of training jobs. Jobs declare
+// their output models by AnnotationOutputModel, ModelRegistrar watches jobs
+// completion through the object backend and registers a new model version of
+// each succeeded job.
+//
+// Registrars of all replicas may register the same job, the object backend
+// refuses a second model version of a job by ErrModelOfJobExists. Models are
+// registered only if the object backend persists them, see
+// backends.JobModelRegistry.
+type ModelRegistrar struct {
+	client          client.Client
+	storageBackend  backends.ObjectStorageBackend
+	modelRegistry   backends.JobModelRegistry
+	listDataSources func(userName string) (model.DataSourceMap, error)
+	interval        time.Duration
+
+	mu sync.Mutex
+	// registered records jobs whose output models have been registered or
+	// failed permanently, a model deleted by user is not registered again
+	// before restarted.
+	registered map[string]bool
+}
+
+func NewModelRegistrar(objStorage string, interval time.Duration) (*ModelRegistrar, error) {
+	objBackend := registry.GetObjectBackend(objStorage)
+	if objBackend == nil {
+		return nil, fmt.Errorf("no object backend storage named: %s", objStorage)
+	}
+	err := objBackend.Initialize()
+	if err != nil {
+		return nil, err
+	}
+	// Backends not persisting models, e.g. arena, leave modelRegistry nil.
+	modelRegistry, _ := objBackend.(backends.JobModelRegistry)
+	return &ModelRegistrar{
+		client:          clientmgr.GetCtrlClient(),
+		storageBackend:  objBackend,
+		modelRegistry:   modelRegistry,
+		listDataSources: NewDataSourceHandler().ListDataSourceFromConfigMap,
+		interval:        interval,
+		registered:      make(map[string]bool),
+	}, nil
+}
+
+// Start registers output models of succeeded jobs periodically until stopCh
+// is closed, it is disabled by a non-positive interval or an object backend
+// not persisting models.
+func (mr *ModelRegistrar) Start(stopCh <-chan struct{}) {
+	if mr.interval <= 0 {
+		klog.Infof("model registration of completed jobs is disabled")
+		return
+	}
+	if mr.modelRegistry == nil {
+		klog.Infof("model registration of completed jobs is disabled, object backend %s does not persist models",
+			mr.storageBackend.Name())
+		return
+	}
+	go wait.Until(mr.registerSucceededJobs, mr.interval, stopCh)
+}
+
+func (mr *ModelRegistrar) registerSucceededJobs() {
+	namespaces := &corev1.NamespaceList{}
+	if err := mr.client.List(context.TODO(), namespaces); err != nil {
+		klog.Errorf("fail to list namespaces to register models, err: %v", err)
+		return
+	}
+	query := &backends.Query{
+		Status:              apiv1.JobSucceeded,
+		StartTime:           time.Now().Add(-modelRegistrationLookback),
+		EndTime:             time.Now(),
+		AllocatedNamespaces: make([]string, 0, len(namespaces.Items)),
+	}
+	for _, namespace := range namespaces.Items {
+		query.AllocatedNamespaces = append(query.AllocatedNamespaces, namespace.Name)
+	}
+
+	// Jobs created by crons are listed apart from the others.
+	for _, isCron := range []bool{false, true} {
+		query.IsCron = isCron
+		jobs, err := mr.storageBackend.ListJobs(query)
+		if err != nil {
+			klog.Errorf("fail to list succeeded jobs to register models, err: %v", err)
+			return
+		}
+		for _, job := range jobs {
+			if err = mr.registerJob(job); err != nil {
+				klog.Errorf("fail to register output model of job %s/%s, err: %v", job.Namespace, job.Name, err)
+			}
+		}
+	}
+}
+
+// registerJob registers the output model of a succeeded job, if the job
+// declared one and it has not been registered. Failures that never recover,
+// e.g. the data source was removed, are returned once and not retried.
+func (mr *ModelRegistrar) registerJob(job *dmo.Job) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if mr.registered[job.UID] {
+		return nil
+	}
+
+	output, err := outputModelOfJob(job)
+	if err != nil || output == nil {
+		// An invalid annotation never turns valid, the job is skipped from now.
+		mr.registered[job.UID] = true
+		return err
+	}
+
+	models, err := mr.storageBackend.ListModels(&backends.ModelsQuery{JobID: job.UID})
+	if err != nil {
+		return err
+	}
+	if len(models) > 0 {
+		mr.registered[job.UID] = true
+		return nil
+	}
+
+	dataSources, err := mr.listDataSources("")
+	if err != nil {
+		return err
+	}
+	dataSource, err := outputDataSource(dataSources, output)
+	if err != nil {
+		mr.registered[job.UID] = true
+		return err
+	}
+
+	registered := newModelOfJob(job, output, dataSource)
+	err = mr.modelRegistry.RegisterModelOfJob(registered)
+	if errors.Is(err, backends.ErrModelOfJobExists) {
+		// Registered by another replica in the meantime.
+		mr.registered[job.UID] = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	mr.registered[job.UID] = true
+	klog.Infof("registered version %s of model %s from job %s/%s", registered.Version, registered.Name, job.Namespace, job.Name)
+	return nil
+}
+
+// outputModelOfJob returns the output model annotated on the job persisted in
+// JobJson, it returns nil if the job declared none.
+func outputModelOfJob(job *dmo.Job) (*dmo.OutputModel, error) {
+	if job.JobJson == "" {
+		return nil, nil
+	}
+	object := struct {
+		metav1.ObjectMeta `json:"metadata"`
+	}{}
+	if err := json.Unmarshal([]byte(job.JobJson), &object); err != nil {
+		return nil, err
+	}
+	annotation := object.Annotations[apiv1.AnnotationOutputModel]
+	if annotation == "" {
+		return nil, nil
+	}
+
+	output := &dmo.OutputModel{}
+	if err := json.Unmarshal([]byte(annotation), output); err != nil {
+		return nil, fmt.Errorf("invalid annotation %s: %v", apiv1.AnnotationOutputModel, err)
+	}
+	if output.Name == "" || output.DataSource == "" || output.Path == "" {
+		return nil, fmt.Errorf("invalid annotation %s: name, dataSource and path should not be empty", apiv1.AnnotationOutputModel)
+	}
+	return output, nil
+}
+
+// outputDataSource returns the data source an output model is saved to, which
+// should exist and be backed by a pvc.
+func outputDataSource(dataSources model.DataSourceMap, output *dmo.OutputModel) (model.DataSource, error) {
+	dataSource, ok := dataSources[output.DataSource]
+	if !ok {
+		return model.DataSource{}, fmt.Errorf("data source %s of output model does not exist", output.DataSource)
+	}
+	if dataSource.PvcName == "" {
+		return model.DataSource{}, fmt.Errorf("data source %s of output model is not backed by a pvc", output.DataSource)
+	}
+	return dataSource, nil
+}
+
+// newModelOfJob returns the model version output by a succeeded job, the model
+// and its metrics file are located in the pvc of data source.
+func newModelOfJob(job *dmo.Job, output *dmo.OutputModel, dataSource model.DataSource) *dmo.Model {
+	metadata := dmo.ModelMetadata{
+		"job_kind":   job.Kind,
+		"job_status": string(job.Status),
+	}
+	if job.GmtJobFinished != nil {
+		metadata["job_finished"] = job.GmtJobFinished.Format(time.RFC3339)
+	}
+	if output.MetricsPath != "" {
+		metadata["metrics_file"] = pvcURI(dataSource.PvcName, output.MetricsPath)
+	}
+
+	return &dmo.Model{
+		Name:         output.Name,
+		JobID:        job.UID,
+		JobName:      job.Name,
+		JobNamespace: job.Namespace,
+		DataSource:   output.DataSource,
+		CodeSource:   output.CodeSource,
+		Stage:        dmo.ModelStageNone,
+		Description:  fmt.Sprintf("registered from job %s/%s", job.Namespace, job.Name),
+		Metadata:     metadata,
+		User:         job.User,
+		Artifacts: []dmo.ModelArtifact{
+			{Type: dmo.ModelArtifactPVC, URI: pvcURI(dataSource.PvcName, output.Path)},
+		},
+	}
+}
+
+// pvcURI locates a path under the root of pvc as <pvc name>:<path>.
+func pvcURI(pvcName, subPath string) string {
+	return pvcName + ":" + path.Join("/", strings.TrimSpace(subPath))
+}
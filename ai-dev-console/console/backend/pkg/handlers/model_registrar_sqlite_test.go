@@ -0,0 +1,120 @@
+//go:build cgo
+
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/model"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/objects/sql"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+)
+
+func newTestModelRegistrar(t *testing.T, listDataSources func(userName string) (model.DataSourceMap, error)) *ModelRegistrar {
+	t.Setenv(sql.EnvSQLitePath, ":memory:")
+	backend := sql.NewSQLiteBackendService()
+	if err := backend.Initialize(); err != nil {
+		t.Fatalf("fail to initialize sqlite backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return &ModelRegistrar{
+		storageBackend:  backend,
+		modelRegistry:   backend.(backends.JobModelRegistry),
+		listDataSources: listDataSources,
+		registered:      make(map[string]bool),
+	}
+}
+
+func TestModelRegistrarRegisterJob(t *testing.T) {
+	job := newTestJob(outputModelAnnotation(`{"name":"mnist","dataSource":"models","path":"mnist"}`))
+	listModels := func(registrar *ModelRegistrar) []*dmo.Model {
+		models, err := registrar.storageBackend.ListModels(&backends.ModelsQuery{ModelName: "mnist"})
+		if err != nil {
+			t.Fatalf("fail to list models: %v", err)
+		}
+		return models
+	}
+
+	t.Run("register once", func(t *testing.T) {
+		registrar := newTestModelRegistrar(t, func(string) (model.DataSourceMap, error) { return testDataSources, nil })
+		if err := registrar.registerJob(job); err != nil {
+			t.Fatalf("fail to register job: %v", err)
+		}
+		models := listModels(registrar)
+		if len(models) != 1 || models[0].Version != "1" || models[0].JobID != job.UID {
+			t.Fatalf("models = %v, want version 1 of job %s", models, job.UID)
+		}
+		if artifacts := models[0].Artifacts; len(artifacts) != 1 || artifacts[0].URI != "pvc-models:/mnist" {
+			t.Errorf("artifacts = %v, want pvc-models:/mnist", artifacts)
+		}
+
+		// A registrar of another replica skips the registered job.
+		another := &ModelRegistrar{
+			storageBackend:  registrar.storageBackend,
+			modelRegistry:   registrar.modelRegistry,
+			listDataSources: registrar.listDataSources,
+			registered:      make(map[string]bool),
+		}
+		if err := another.registerJob(job); err != nil {
+			t.Fatalf("fail to register job again: %v", err)
+		}
+		if models = listModels(registrar); len(models) != 1 {
+			t.Errorf("models = %v after registering the job again, want version 1 only", models)
+		}
+		if !another.registered[job.UID] {
+			t.Errorf("registered job is not recorded")
+		}
+	})
+
+	t.Run("data source absent", func(t *testing.T) {
+		registrar := newTestModelRegistrar(t, func(string) (model.DataSourceMap, error) { return model.DataSourceMap{}, nil })
+		if err := registrar.registerJob(job); err == nil {
+			t.Fatalf("register job of an absent data source, want error")
+		}
+		// The failure is permanent and not retried.
+		if err := registrar.registerJob(job); err != nil {
+			t.Errorf("register job again, got %v, want it skipped", err)
+		}
+		if models := listModels(registrar); len(models) != 0 {
+			t.Errorf("models = %v, want none", models)
+		}
+	})
+
+	t.Run("data sources unavailable", func(t *testing.T) {
+		available := false
+		registrar := newTestModelRegistrar(t, func(string) (model.DataSourceMap, error) {
+			if !available {
+				return nil, fmt.Errorf("configmap unavailable")
+			}
+			return testDataSources, nil
+		})
+		if err := registrar.registerJob(job); err == nil {
+			t.Fatalf("register job while data sources are unavailable, want error")
+		}
+		// The failure is transient and retried.
+		available = true
+		if err := registrar.registerJob(job); err != nil {
+			t.Fatalf("fail to register job: %v", err)
+		}
+		if models := listModels(registrar); len(models) != 1 {
+			t.Errorf("models = %v, want version 1", models)
+		}
+	})
+}
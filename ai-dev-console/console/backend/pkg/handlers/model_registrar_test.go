@@ -0,0 +1,230 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package handlers
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/model"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
+)
+
+var testDataSources = model.DataSourceMap{
+	"models": {Name: "models", PvcName: "pvc-models"},
+	"oss":    {Name: "oss", LocalPath: "/mnt/oss"},
+}
+
+// newTestJob returns a succeeded job persisted with the given annotations.
+func newTestJob(annotations string) *dmo.Job {
+	return &dmo.Job{
+		Name:      "mnist-train",
+		Namespace: "default",
+		UID:       "6f06d2fd-22c6-11e9-96bb-0242ac1d5327",
+		Kind:      "TFJob",
+		Status:    apiv1.JobSucceeded,
+		JobJson:   fmt.Sprintf(`{"metadata":{"name":"mnist-train","namespace":"default","annotations":%s}}`, annotations),
+	}
+}
+
+func outputModelAnnotation(value string) string {
+	return fmt.Sprintf(`{%q:%q}`, apiv1.AnnotationOutputModel, value)
+}
+
+func TestOutputModelOfJob(t *testing.T) {
+	testCases := []struct {
+		testName string
+		job      *dmo.Job
+		expected *dmo.OutputModel
+		wantErr  bool
+	}{
+		{
+			testName: "job not persisted as json",
+			job:      &dmo.Job{},
+		},
+		{
+			testName: "no annotation",
+			job:      newTestJob(`{"foo":"bar"}`),
+		},
+		{
+			testName: "output model",
+			job:      newTestJob(outputModelAnnotation(`{"name":"mnist","dataSource":"models","path":"mnist","metricsPath":"mnist/metrics.json"}`)),
+			expected: &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "mnist", MetricsPath: "mnist/metrics.json"},
+		},
+		{
+			testName: "annotation not json",
+			job:      newTestJob(outputModelAnnotation(`mnist`)),
+			wantErr:  true,
+		},
+		{
+			testName: "path absent",
+			job:      newTestJob(outputModelAnnotation(`{"name":"mnist","dataSource":"models"}`)),
+			wantErr:  true,
+		},
+		{
+			testName: "job json broken",
+			job:      &dmo.Job{JobJson: `{"metadata":`},
+			wantErr:  true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			output, err := outputModelOfJob(testCase.job)
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("outputModelOfJob() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if !reflect.DeepEqual(output, testCase.expected) {
+				t.Errorf("outputModelOfJob() = %+v, want %+v", output, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestOutputDataSource(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		dataSource string
+		expected   string
+		wantErr    bool
+	}{
+		{
+			testName:   "pvc data source",
+			dataSource: "models",
+			expected:   "pvc-models",
+		},
+		{
+			testName:   "data source absent",
+			dataSource: "removed",
+			wantErr:    true,
+		},
+		{
+			testName:   "data source without pvc",
+			dataSource: "oss",
+			wantErr:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			dataSource, err := outputDataSource(testDataSources, &dmo.OutputModel{DataSource: testCase.dataSource})
+			if (err != nil) != testCase.wantErr {
+				t.Fatalf("outputDataSource() error = %v, want error %v", err, testCase.wantErr)
+			}
+			if dataSource.PvcName != testCase.expected {
+				t.Errorf("outputDataSource() pvc = %s, want %s", dataSource.PvcName, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestNewModelOfJob(t *testing.T) {
+	finished := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	user := "alice"
+
+	testCases := []struct {
+		testName string
+		job      *dmo.Job
+		output   *dmo.OutputModel
+		metadata dmo.ModelMetadata
+		artifact string
+	}{
+		{
+			testName: "unfinished job without metrics",
+			job:      newTestJob(`{}`),
+			output:   &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "mnist"},
+			metadata: dmo.ModelMetadata{"job_kind": "TFJob", "job_status": string(apiv1.JobSucceeded)},
+			artifact: "pvc-models:/mnist",
+		},
+		{
+			testName: "finished job with metrics",
+			job: func() *dmo.Job {
+				job := newTestJob(`{}`)
+				job.GmtJobFinished = &finished
+				job.User = &user
+				return job
+			}(),
+			output: &dmo.OutputModel{Name: "mnist", DataSource: "models", Path: "/mnist/", MetricsPath: "mnist/metrics.json", CodeSource: "mnist-code"},
+			metadata: dmo.ModelMetadata{
+				"job_kind":     "TFJob",
+				"job_status":   string(apiv1.JobSucceeded),
+				"job_finished": "2021-06-01T08:00:00Z",
+				"metrics_file": "pvc-models:/mnist/metrics.json",
+			},
+			artifact: "pvc-models:/mnist",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			registered := newModelOfJob(testCase.job, testCase.output, testDataSources["models"])
+			if registered.Name != testCase.output.Name || registered.Version != "" || registered.Stage != dmo.ModelStageNone {
+				t.Errorf("newModelOfJob() = %+v, want an unversioned %s in stage None", registered, testCase.output.Name)
+			}
+			if registered.JobID != testCase.job.UID || registered.JobName != testCase.job.Name || registered.JobNamespace != testCase.job.Namespace ||
+				registered.DataSource != testCase.output.DataSource || registered.CodeSource != testCase.output.CodeSource || registered.User != testCase.job.User {
+				t.Errorf("newModelOfJob() = %+v, want the lineage of job %s", registered, testCase.job.Name)
+			}
+			if !reflect.DeepEqual(registered.Metadata, testCase.metadata) {
+				t.Errorf("newModelOfJob() metadata = %v, want %v", registered.Metadata, testCase.metadata)
+			}
+			expected := []dmo.ModelArtifact{{Type: dmo.ModelArtifactPVC, URI: testCase.artifact}}
+			if !reflect.DeepEqual(registered.Artifacts, expected) {
+				t.Errorf("newModelOfJob() artifacts = %v, want %v", registered.Artifacts, expected)
+			}
+		})
+	}
+}
+
+func TestPvcURI(t *testing.T) {
+	testCases := []struct {
+		testName string
+		subPath  string
+		expected string
+	}{
+		{
+			testName: "relative path",
+			subPath:  "models/mnist",
+			expected: "pvc:/models/mnist",
+		},
+		{
+			testName: "absolute path with trailing slash",
+			subPath:  "/models/mnist/",
+			expected: "pvc:/models/mnist",
+		},
+		{
+			testName: "path escaping the root",
+			subPath:  "../../etc",
+			expected: "pvc:/etc",
+		},
+		{
+			testName: "blank path",
+			subPath:  " ",
+			expected: "pvc:/",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.testName, func(t *testing.T) {
+			if uri := pvcURI("pvc", testCase.subPath); uri != testCase.expected {
+				t.Errorf("pvcURI() = %s, want %s", uri, testCase.expected)
+			}
+		})
+	}
+}
@@ -74,19 +74,42 @@ func (mh *ModelsHandler) GetModelsList(query *backends.ModelsQuery) ([]dmo.Model
 	return result, err
 }
 
-func (mh *ModelsHandler) CreateModel(data []byte) error {
+// CreateModel registers a new version of model, the version is assigned by
+// backend if it is not specified.
+func (mh *ModelsHandler) CreateModel(data []byte) (*dmo.Model, error) {
 	model := &dmo.Model{}
 	err := json.Unmarshal(data, model)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	model.OSSPath = ""
+	model.ID = 0
 	model.GmtCreated = time.Now()
 	err = mh.storageBackend.WriteModel(model)
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// UpdateModel updates the description, tags and metadata of a model version.
+func (mh *ModelsHandler) UpdateModel(data []byte) error {
+	model := &dmo.Model{}
+	err := json.Unmarshal(data, model)
 	if err != nil {
 		return err
 	}
-	return nil
+	if model.ID == 0 {
+		return fmt.Errorf("model id should not be empty")
+	}
+	return mh.storageBackend.UpdateModel(model)
+}
+
+func (mh *ModelsHandler) TransitionModelStage(modelID, stage, operator, comment string) (*dmo.Model, error) {
+	return mh.storageBackend.TransitionModelStage(modelID, stage, operator, comment)
+}
+
+func (mh *ModelsHandler) ListModelStageTransitions(modelID string) ([]*dmo.ModelStageTransition, error) {
+	return mh.storageBackend.ListModelStageTransitions(modelID)
 }
 
 //func (mh *ModelsHandler) GetTFJobMessage(name, namespace string) (string, error) {
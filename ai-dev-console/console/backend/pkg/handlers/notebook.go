@@ -71,6 +71,10 @@ const (
 	Notebook_Type_VSCOde  = "VSCode"
 
 	Notebook_Type_Label = "notebook-type"
+
+	// Notebook_Stop_Annotation stops a notebook, the notebook controller scales
+	// it down while it is set and back up once it is removed.
+	Notebook_Stop_Annotation = "kubeflow-resource-stopped"
 )
 
 func NewNotebookHandler(objStorage string) (*NotebookHandler, error) {
@@ -420,14 +424,15 @@ func (nh *NotebookHandler) ListNotebook(namespace string) ([]NotebookMessage, er
 		event := "Unknown state."
 
 		status := Stopped
-		if len(item.Status.Conditions) < 1 {
+		state := item.Status.StateCondition()
+		if state == "" {
 			status = Starting
 			if value, ok := eventsMap[item.Name]; ok {
 				event = value
 			}
-		} else if item.Status.Conditions[0].Type == "Running" {
+		} else if state == "Running" {
 			status = Running
-		} else if item.Status.Conditions[0].Type == "Waiting" {
+		} else if state == "Waiting" || state == "Starting" {
 			status = Starting
 		}
 
@@ -494,6 +499,43 @@ func (nh *NotebookHandler) DeleteNotebook(name, namespace string) error {
 	return err
 }
 
+// StopNotebook stops a notebook without deleting it, its volumes are kept.
+func (nh *NotebookHandler) StopNotebook(name, namespace string) error {
+	return nh.patchStopAnnotation(name, namespace, time.Now().Format(time.RFC3339))
+}
+
+// StartNotebook resumes a stopped notebook.
+func (nh *NotebookHandler) StartNotebook(name, namespace string) error {
+	return nh.patchStopAnnotation(name, namespace, nil)
+}
+
+func (nh *NotebookHandler) patchStopAnnotation(name, namespace string, value interface{}) error {
+	if namespace == "" || name == "" {
+		return errors.New("patch notebook with name or namespace empty")
+	}
+	notebook := &v1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				Notebook_Stop_Annotation: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	nh.routeCache.Delete(utils2.GetCacheKey(namespace, name, utils2.NotebookPod))
+
+	klog.Infof("Patch notebook stop annotation name:%s namespace:%s value:%v", name, namespace, value)
+	return nh.client.Patch(context.Background(), notebook, client.RawPatch(types.MergePatchType, patch))
+}
+
 type VolumeData struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
@@ -16,6 +16,7 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/auth"
@@ -25,6 +26,7 @@ import (
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"k8s.io/klog"
+	"net/http"
 	"strconv"
 	"time"
 )
@@ -162,6 +164,9 @@ func (dc *EvaluateAPIsController) deleteEvaluateJob(c *gin.Context) {
 	}
 }
 
+// compareEvaluateJob compares metrics of evaluate jobs posted, jobs are ranked
+// by the metric rank_by in order asc or desc (by default) if it is specified,
+// and the comparison is exported as a csv or json file by format.
 func (dc *EvaluateAPIsController) compareEvaluateJob(c *gin.Context) {
 	data, err := c.GetRawData()
 	if err != nil {
@@ -173,14 +178,54 @@ func (dc *EvaluateAPIsController) compareEvaluateJob(c *gin.Context) {
 	err = json.Unmarshal(data, &array)
 	if err != nil {
 		handleErr(c, fmt.Sprintf("failed to unmarshal evaluateJob, error: %s", err))
+		return
 	}
 	if len(array) < 2 {
-		handleErr(c, fmt.Sprintf("Invalid evaluateJob data"))
+		handleErr(c, fmt.Sprintf("at least 2 evaluateJobs should be compared"))
+		return
+	}
+
+	rankBy, order, format := c.Query("rank_by"), c.Query("order"), c.Query("format")
+	if order != "" && order != "asc" && order != "desc" {
+		handleErr(c, fmt.Sprintf("invalid url parameter[order=%s], should be asc or desc", order))
+		return
 	}
-	metricsArray, err := dc.evaluateHandler.CompareEvaluateJobs(array)
+	if format != "" && format != "csv" && format != "json" {
+		handleErr(c, fmt.Sprintf("invalid url parameter[format=%s], should be csv or json", format))
+		return
+	}
+	klog.Infof("post /evaluate/compare with parameters: rank_by=%s, order=%s, format=%s", rankBy, order, format)
+
+	comparison, err := dc.evaluateHandler.CompareEvaluateJobs(array, rankBy, order == "asc")
 	if err != nil {
 		handleErr(c, fmt.Sprintf("failed to compare evaluateJobs, error: %s", err))
 		return
 	}
-	utils.Succeed(c, metricsArray)
+
+	var (
+		content     []byte
+		contentType string
+	)
+	switch format {
+	case "csv":
+		buf := &bytes.Buffer{}
+		if err = comparison.WriteCSV(buf); err != nil {
+			handleErr(c, fmt.Sprintf("failed to export comparison as csv, error: %s", err))
+			return
+		}
+		content, contentType = buf.Bytes(), "text/csv"
+	case "json":
+		if content, err = json.MarshalIndent(comparison, "", "  "); err != nil {
+			handleErr(c, fmt.Sprintf("failed to export comparison as json, error: %s", err))
+			return
+		}
+		contentType = "application/json"
+	default:
+		utils.Succeed(c, comparison)
+		return
+	}
+	extraHeaders := map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="evaluate_comparison_%d.%s"`, time.Now().Unix(), format),
+	}
+	c.DataFromReader(http.StatusOK, int64(len(content)), contentType, bytes.NewReader(content), extraHeaders)
 }
@@ -16,10 +16,13 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/auth"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/handlers"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/utils"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"k8s.io/klog"
 	"strconv"
@@ -41,6 +44,9 @@ func (mc *ModelsAPIsController) RegisterRoutes(routes *gin.RouterGroup) {
 	overview.GET("/list", mc.listModels)
 	overview.GET("/get", mc.getModel)
 	overview.DELETE("/delete", mc.deleteModel)
+	overview.POST("/update", mc.updateModel)
+	overview.POST("/transition", mc.transitionModelStage)
+	overview.GET("/transitions", mc.listModelStageTransitions)
 }
 
 func (mc *ModelsAPIsController) createModel(c *gin.Context) {
@@ -49,17 +55,17 @@ func (mc *ModelsAPIsController) createModel(c *gin.Context) {
 		handleErr(c, fmt.Sprintf("failed to get data, error: %s", err.Error()))
 		return
 	}
-	err = mc.modelsHandler.CreateModel(data)
+	model, err := mc.modelsHandler.CreateModel(data)
 	if err != nil {
-		handleErr(c, fmt.Sprintf("failed to get model, error: %s", err.Error()))
+		handleErr(c, fmt.Sprintf("failed to create model, error: %s", err.Error()))
 		return
 	}
-	utils.Succeed(c, nil)
+	utils.Succeed(c, model)
 }
 
 func (mc *ModelsAPIsController) listModels(c *gin.Context) {
 	var (
-		curPageNum, curPageSize, curName, curVersion string
+		curPageNum, curPageSize, curName, curVersion, curStage, curJobID string
 	)
 	query := backends.ModelsQuery{}
 	if curPageNum = c.Query("current_page"); curPageNum != "" {
@@ -90,7 +96,14 @@ func (mc *ModelsAPIsController) listModels(c *gin.Context) {
 	if curVersion = c.Query("model_version"); curVersion != "" {
 		query.ModelVersion = curVersion
 	}
-	klog.Infof("get /model/list with parameters: pageNum = %s, pageSize = %s, modelName = %s, modelVersion = %s", curPageNum, curPageSize, curName, curVersion)
+	if curStage = c.Query("stage"); curStage != "" {
+		query.Stage = curStage
+	}
+	if curJobID = c.Query("job_id"); curJobID != "" {
+		query.JobID = curJobID
+	}
+	klog.Infof("get /model/list with parameters: pageNum = %s, pageSize = %s, modelName = %s, modelVersion = %s, stage = %s, jobID = %s",
+		curPageNum, curPageSize, curName, curVersion, curStage, curJobID)
 	models, err := mc.modelsHandler.GetModelsList(&query)
 	if err != nil {
 		handleErr(c, fmt.Sprintf("failed to list models from backend, error: %v", err))
@@ -125,3 +138,56 @@ func (mc *ModelsAPIsController) deleteModel(c *gin.Context) {
 		utils.Succeed(c, nil)
 	}
 }
+
+func (mc *ModelsAPIsController) updateModel(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		handleErr(c, fmt.Sprintf("failed to get data, error: %s", err.Error()))
+		return
+	}
+	err = mc.modelsHandler.UpdateModel(data)
+	if err != nil {
+		handleErr(c, fmt.Sprintf("failed to update model, error: %v", err))
+		return
+	}
+	utils.Succeed(c, nil)
+}
+
+type modelStageTransitionRequest struct {
+	ModelID string `json:"model_id"`
+	Stage   string `json:"stage"`
+	Comment string `json:"comment"`
+}
+
+func (mc *ModelsAPIsController) transitionModelStage(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		handleErr(c, fmt.Sprintf("failed to get data, error: %s", err.Error()))
+		return
+	}
+	request := modelStageTransitionRequest{}
+	if err = json.Unmarshal(data, &request); err != nil {
+		handleErr(c, fmt.Sprintf("failed to parse transition request, error: %v", err))
+		return
+	}
+	session := sessions.Default(c)
+	loginUserName, _ := session.Get(auth.SessionKeyLoginName).(string)
+	klog.Infof("post /model/transition with parameters: model_id=%s, stage=%s, operator=%s", request.ModelID, request.Stage, loginUserName)
+	model, err := mc.modelsHandler.TransitionModelStage(request.ModelID, request.Stage, loginUserName, request.Comment)
+	if err != nil {
+		handleErr(c, fmt.Sprintf("failed to transition model stage, error: %v", err))
+		return
+	}
+	utils.Succeed(c, model)
+}
+
+func (mc *ModelsAPIsController) listModelStageTransitions(c *gin.Context) {
+	modelID := c.Query("model_id")
+	klog.Infof("get /model/transitions with parameters: model_id=%s", modelID)
+	transitions, err := mc.modelsHandler.ListModelStageTransitions(modelID)
+	if err != nil {
+		handleErr(c, fmt.Sprintf("failed to list model stage transitions from backend, error: %v", err))
+		return
+	}
+	utils.Succeed(c, transitions)
+}
@@ -47,8 +47,8 @@ type NotebookAPIsController struct {
 func (nc *NotebookAPIsController) RegisterRoutes(routes *gin.RouterGroup) {
 	routes.POST("/notebook/create", nc.SubmitNotebook)
 	routes.GET("/notebook/list", nc.GetNotebookList)
-	//routes.GET("/notebook/stop", nc.DeleteNotebookByName)
-	//routes.GET("/notebook/start", nc.DeleteNotebookByName)
+	routes.GET("/notebook/stop", nc.StopNotebookByName)
+	routes.GET("/notebook/start", nc.StartNotebookByName)
 	routes.GET("/notebook/delete", nc.DeleteNotebookByName)
 	routes.GET("/notebook/maxGpu", nc.GetAvailableGpu)
 	routes.GET("/notebook/listPVC", nc.GetAvailablePVCList)
@@ -181,10 +181,7 @@ func (nc *NotebookAPIsController) DeleteNotebookByName(c *gin.Context) {
 		return
 	}
 
-	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.JupyterProxy))
-	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.VSCodeProxy))
-	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.StableDiffusionProxy))
-	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.CommonPortProxy))
+	nc.deleteNotebookProxies(namespace, name)
 	log.Infof("delete notebook name: %s namespace: %s", name, namespace)
 	err := nc.notebookHandler.DeleteNotebook(name, namespace)
 	if err != nil {
@@ -195,6 +192,63 @@ func (nc *NotebookAPIsController) DeleteNotebookByName(c *gin.Context) {
 	utils.Succeed(c, "Delete success!")
 }
 
+func (nc *NotebookAPIsController) StopNotebookByName(c *gin.Context) {
+	namespace := c.Query("namespace")
+	name := c.Query("name")
+	if namespace == "" {
+		log.Error("Namespace is Empty.")
+		utils.Failed(c, "Namespace is Empty.")
+		return
+	}
+	if name == "" {
+		log.Error("Name is Empty.")
+		utils.Failed(c, "Name is Empty.")
+		return
+	}
+
+	nc.deleteNotebookProxies(namespace, name)
+	log.Infof("stop notebook name: %s namespace: %s", name, namespace)
+	if err := nc.notebookHandler.StopNotebook(name, namespace); err != nil {
+		log.Errorf("StopNotebook err : %s", err.Error())
+		utils.Failed(c, err.Error())
+		return
+	}
+	utils.Succeed(c, "Stop success!")
+}
+
+func (nc *NotebookAPIsController) StartNotebookByName(c *gin.Context) {
+	namespace := c.Query("namespace")
+	name := c.Query("name")
+	if namespace == "" {
+		log.Error("Namespace is Empty.")
+		utils.Failed(c, "Namespace is Empty.")
+		return
+	}
+	if name == "" {
+		log.Error("Name is Empty.")
+		utils.Failed(c, "Name is Empty.")
+		return
+	}
+
+	nc.deleteNotebookProxies(namespace, name)
+	log.Infof("start notebook name: %s namespace: %s", name, namespace)
+	if err := nc.notebookHandler.StartNotebook(name, namespace); err != nil {
+		log.Errorf("StartNotebook err : %s", err.Error())
+		utils.Failed(c, err.Error())
+		return
+	}
+	utils.Succeed(c, "Start success!")
+}
+
+// deleteNotebookProxies drops the cached proxies of a notebook, the ones to its
+// pod are stale once it is stopped, deleted or restarted.
+func (nc *NotebookAPIsController) deleteNotebookProxies(namespace, name string) {
+	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.JupyterProxy))
+	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.VSCodeProxy))
+	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.StableDiffusionProxy))
+	nc.proxyCache.Delete(utils.GetProxyCacheKey(namespace, name, utils.CommonPortProxy))
+}
+
 func (nc *NotebookAPIsController) GetNotebookList(c *gin.Context) {
 	namespacesStr := c.Query("namespaces")
 	if namespacesStr == "" {
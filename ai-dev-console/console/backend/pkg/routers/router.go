@@ -20,6 +20,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/console/backend/pkg/routers/api"
 	"github.com/spf13/pflag"
@@ -34,19 +35,22 @@ import (
 	"github.com/gin-contrib/static"
 	"github.com/gin-gonic/gin"
 
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 )
 
 func init() {
 	pflag.StringVar(&eventStorage, "event-storage", "arena", "event storage backend plugin name, persist events into backend if it's specified")
-	pflag.StringVar(&objectStorage, "object-storage", "arena", "object storage backend plugin name, persist jobs and pods into backend if it's specified")
+	pflag.StringVar(&objectStorage, "object-storage", "arena", "object storage backend plugin name, e.g. mysql, postgres or sqlite, persist jobs and pods into backend if it's specified")
 	pflag.StringVar(&clientType, "client-type", "arena", "client type name, support apiserver and arena")
+	pflag.DurationVar(&modelRegistrationInterval, "model-registration-interval", time.Minute, "interval to register output models of succeeded jobs into mysql, postgres or sqlite object storage, 0 disables the registration")
 }
 
 var (
-	eventStorage  string
-	objectStorage string
-	clientType    string
+	eventStorage              string
+	objectStorage             string
+	clientType                string
+	modelRegistrationInterval time.Duration
 )
 
 type APIController interface {
@@ -167,6 +171,13 @@ func InitRouter() *gin.Engine {
 		panic(err)
 	}
 
+	modelRegistrar, err := handlers.NewModelRegistrar(objectStorage, modelRegistrationInterval)
+	if err != nil {
+		klog.Error("Fail to NewModelRegistrar:" + err.Error())
+		panic(err)
+	}
+	modelRegistrar.Start(wait.NeverStop)
+
 	mlMetadataController := api.NewMLMetadataController()
 	mlMetadataController.RegisterRoutes(r)
 
@@ -17,6 +17,8 @@ limitations under the License.
 package backends
 
 import (
+	"errors"
+
 	appsv1alpha1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/apis/apps/v1alpha1"
 	notebookv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/apis/notebook/v1"
 	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
@@ -102,11 +104,27 @@ type EvaluateJobStorageBackend interface {
 	WriteEvaluateJob(evaluateJob *batch.Job, PV_OSMap map[string]string) error
 }
 
+// ErrModelOfJobExists is returned by RegisterModelOfJob when a model version
+// of the same job has been registered, a job outputs a single model version.
+var ErrModelOfJobExists = errors.New("model of the job already exists")
+
+// JobModelRegistry is implemented by object backends persisting models, the
+// output models of jobs are registered through it.
+type JobModelRegistry interface {
+	// RegisterModelOfJob writes a model version output by the job of
+	// model.JobID, unlike WriteModel it refuses a second version of the job by
+	// ErrModelOfJobExists.
+	RegisterModelOfJob(model *dmo.Model) error
+}
+
 type ModelsStorageBackend interface {
 	ListModels(query *ModelsQuery) ([]*dmo.Model, error)
 	GetModel(modelID string) (*dmo.Model, error)
 	DeleteModel(modelID string) error
 	WriteModel(model *dmo.Model) error
+	UpdateModel(model *dmo.Model) error
+	TransitionModelStage(modelID, stage, operator, comment string) (*dmo.Model, error)
+	ListModelStageTransitions(modelID string) ([]*dmo.ModelStageTransition, error)
 }
 
 type NotebookStorageBackend interface {
@@ -75,6 +75,18 @@ func (a *apiServerBackend) WriteModel(model *dmo.Model) error {
 	return nil
 }
 
+func (a *apiServerBackend) UpdateModel(model *dmo.Model) error {
+	return nil
+}
+
+func (a *apiServerBackend) TransitionModelStage(modelID, stage, operator, comment string) (*dmo.Model, error) {
+	return nil, nil
+}
+
+func (a *apiServerBackend) ListModelStageTransitions(modelID string) ([]*dmo.ModelStageTransition, error) {
+	return nil, nil
+}
+
 func (a *apiServerBackend) ListEvaluateJobs(query *backends.EvaluateJobQuery) ([]*dmo.EvaluateJob, error) {
 	return nil, nil
 }
@@ -45,6 +45,9 @@ const (
 	EnvDBUser     = "MYSQL_USER"
 	EnvDBPassword = "MYSQL_PASSWORD"
 	EnvLogMode    = "MYSQL_LOGMODE"
+	// EnvAutoMigrate disables migrating database schema on startup when set
+	// to false, run `backend-server migrate` to migrate it instead.
+	EnvAutoMigrate = "MYSQL_AUTO_MIGRATE"
 )
 
 func GetMysqlDBSource() (dbSource, logMode string, err error) {
@@ -62,3 +65,8 @@ func GetMysqlDBSource() (dbSource, logMode string, err error) {
 	logMode = utils.GetEnvOrDefault(EnvLogMode, "error")
 	return dbSource, logMode, nil
 }
+
+func GetMysqlAutoMigrate() bool {
+	autoMigrate, err := strconv.ParseBool(utils.GetEnvOrDefault(EnvAutoMigrate, "true"))
+	return err != nil || autoMigrate
+}
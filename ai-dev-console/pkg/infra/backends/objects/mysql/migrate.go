@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mysql
+
+import (
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/objects/mysql/migrations"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"k8s.io/klog"
+)
+
+// Open connects to the mysql database configured by environment variables.
+func Open() (*gorm.DB, error) {
+	dbSource, logMode, err := GetMysqlDBSource()
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open("mysql", dbSource)
+	if err != nil {
+		return nil, err
+	}
+	db.LogMode(logMode == "debug")
+	return db, nil
+}
+
+// NewMigrator returns the migrator of mysql object backend schema. Databases
+// created before schema versions were recorded are adopted by adding columns
+// which used to be added on every startup, so that they match the initial
+// migration.
+func NewMigrator(db *gorm.DB) (*migrations.Migrator, error) {
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 && db.HasTable(&dmo.Job{}) {
+		klog.Infof("adopt database created before schema versions were recorded")
+		// Columns may have existed already, errors are ignored as before.
+		db.Exec("ALTER TABLE cron ADD user_id VARCHAR(128)")
+		db.Exec("ALTER TABLE model ADD user_id VARCHAR(128)")
+		db.Exec("ALTER TABLE evaluate ADD user_id VARCHAR(128)")
+		db.Exec("ALTER TABLE notebook ADD user_id VARCHAR(128)")
+	}
+	return migrator, nil
+}
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS `notebook`;
+DROP TABLE IF EXISTS `evaluate`;
+DROP TABLE IF EXISTS `model`;
+DROP TABLE IF EXISTS `cron`;
+DROP TABLE IF EXISTS `job`;
+DROP TABLE IF EXISTS `pod`;
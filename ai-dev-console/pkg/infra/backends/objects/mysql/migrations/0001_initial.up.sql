@@ -0,0 +1,133 @@
+-- Tables of the object backend as they were created implicitly before schema
+-- versions were recorded, existing tables are kept untouched.
+CREATE TABLE IF NOT EXISTS `pod` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(256),
+  `namespace` varchar(256),
+  `uid` varchar(256),
+  `etcd_version` varchar(64),
+  `status` varchar(32),
+  `image` varchar(256),
+  `gpu` tinyint(4),
+  `job_uid` varchar(256),
+  `job_name` varchar(256),
+  `replica_type` varchar(32),
+  `pod_json` text,
+  `host_ip` varchar(64),
+  `pod_ip` varchar(64),
+  `extended` varchar(4096),
+  `gmt_created` datetime,
+  `gmt_modified` datetime,
+  `gmt_pod_running` datetime,
+  `gmt_pod_finished` datetime,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `job` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(256),
+  `namespace` varchar(256),
+  `display_name` varchar(256),
+  `uid` varchar(256),
+  `status` varchar(32),
+  `kind` varchar(32),
+  `job_json` text,
+  `region_id` varchar(256),
+  `cluster_id` varchar(256),
+  `tenant_id` varchar(128),
+  `group_id` varchar(128),
+  `user_id` varchar(128),
+  `created_by` varchar(64),
+  `reason_code` varchar(128),
+  `reason` varchar(1024),
+  `etcd_version` varchar(64),
+  `is_in_k8s` tinyint(4),
+  `is_deleted` tinyint(4) DEFAULT 0,
+  `is_enable_gpu_topo_aware` tinyint(4),
+  `extended` text,
+  `gmt_created` datetime,
+  `gmt_modified` datetime,
+  `gmt_job_submitted` datetime,
+  `gmt_job_stopped` datetime,
+  `gmt_job_running` datetime,
+  `gmt_job_finished` datetime,
+  `resources` text,
+  `job_config` text,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `cron` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(256),
+  `namespace` varchar(256),
+  `uid` varchar(256),
+  `kind` varchar(32),
+  `status` varchar(32),
+  `region_id` varchar(256),
+  `cluster_id` varchar(256),
+  `schedule` varchar(32),
+  `concurrency_policy` varchar(32),
+  `active` text,
+  `history` text,
+  `history_limit` integer(32),
+  `is_in_k8s` tinyint(4),
+  `is_deleted` tinyint(4) DEFAULT 0,
+  `suspend` tinyint(4),
+  `deadline` datetime,
+  `user_id` varchar(128),
+  `last_schedule_time` datetime,
+  `gmt_created` datetime,
+  `gmt_modified` datetime,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `model` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `model_name` varchar(256),
+  `model_version` varchar(256),
+  `oss_path` varchar(256),
+  `job_id` varchar(256),
+  `user_id` varchar(128),
+  `gmt_created` datetime,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `evaluate` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `job_id` varchar(50) NOT NULL,
+  `name` varchar(256),
+  `namespace` varchar(256),
+  `uid` varchar(256),
+  `user_id` varchar(128),
+  `model_name` varchar(256),
+  `model_version` varchar(256),
+  `status` varchar(32),
+  `image` varchar(256),
+  `dataset_path` varchar(256),
+  `code` text,
+  `command` varchar(256),
+  `metrics` text,
+  `is_deleted` tinyint(4) DEFAULT 0,
+  `report_path` varchar(256),
+  `gmt_created` datetime,
+  `gmt_modified` datetime,
+  PRIMARY KEY (`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `notebook` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `name` varchar(256),
+  `namespace` varchar(256),
+  `image` varchar(256),
+  `volumes` text,
+  `cpu` varchar(256),
+  `gpu` varchar(256),
+  `memory` varchar(256),
+  `user_name` varchar(256),
+  `user_id` varchar(128),
+  `token` varchar(256),
+  `status` varchar(256),
+  `image_pull_secrets` text,
+  `gmt_created` datetime,
+  PRIMARY KEY (`id`)
+);
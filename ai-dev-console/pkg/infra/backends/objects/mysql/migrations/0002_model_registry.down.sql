@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS `model_stage_transition`;
+DROP TABLE IF EXISTS `model_artifact`;
+
+ALTER TABLE `model`
+  DROP INDEX `uk_model_name_version`;
+
+ALTER TABLE `model`
+  DROP COLUMN `gmt_modified`,
+  DROP COLUMN `metadata`,
+  DROP COLUMN `tags`,
+  DROP COLUMN `description`,
+  DROP COLUMN `stage`,
+  DROP COLUMN `code_source`,
+  DROP COLUMN `data_source`,
+  DROP COLUMN `job_namespace`,
+  DROP COLUMN `job_name`;
@@ -0,0 +1,55 @@
+-- Model registry: lineage, stages, tags and metadata of model versions,
+-- artifacts of a version and the history of stage transitions.
+ALTER TABLE `model`
+  ADD COLUMN `job_name` varchar(256),
+  ADD COLUMN `job_namespace` varchar(256),
+  ADD COLUMN `data_source` varchar(256),
+  ADD COLUMN `code_source` varchar(256),
+  ADD COLUMN `stage` varchar(32) DEFAULT 'None',
+  ADD COLUMN `description` varchar(1024),
+  ADD COLUMN `tags` text,
+  ADD COLUMN `metadata` text,
+  ADD COLUMN `gmt_modified` datetime;
+
+-- Versions used to be free text, duplicated versions of a model are renamed
+-- after their ids, except the first one, before versions are made unique.
+UPDATE `model` m
+  JOIN (
+    SELECT `model_name`, `model_version`, MIN(`id`) AS `keep_id`
+    FROM `model`
+    GROUP BY `model_name`, `model_version`
+    HAVING COUNT(*) > 1
+  ) d ON m.`model_name` = d.`model_name` AND m.`model_version` = d.`model_version`
+  SET m.`model_version` = CONCAT(m.`model_version`, '-', m.`id`)
+  WHERE m.`id` <> d.`keep_id`;
+
+ALTER TABLE `model`
+  ADD UNIQUE KEY `uk_model_name_version` (`model_name`, `model_version`);
+
+-- Artifacts of versions created before the registry.
+CREATE TABLE IF NOT EXISTS `model_artifact` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `model_id` bigint(20) NOT NULL,
+  `type` varchar(32),
+  `uri` varchar(1024),
+  `gmt_created` datetime,
+  PRIMARY KEY (`id`),
+  KEY `idx_model_artifact_model_id` (`model_id`)
+);
+
+INSERT INTO `model_artifact` (`model_id`, `type`, `uri`, `gmt_created`)
+  SELECT `id`, 'OSS', `oss_path`, `gmt_created`
+  FROM `model`
+  WHERE `oss_path` IS NOT NULL AND `oss_path` <> '';
+
+CREATE TABLE IF NOT EXISTS `model_stage_transition` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `model_id` bigint(20) NOT NULL,
+  `from_stage` varchar(32),
+  `to_stage` varchar(32),
+  `operator` varchar(128),
+  `comment` varchar(1024),
+  `gmt_created` datetime,
+  PRIMARY KEY (`id`),
+  KEY `idx_model_stage_transition_model_id` (`model_id`)
+);
@@ -0,0 +1,317 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"k8s.io/klog"
+)
+
+// Scripts are named as <version>_<name>.up.sql and <version>_<name>.down.sql,
+// versions start from 1 and increase one by one. A script is applied once and
+// must never be edited after released, change schema by adding new ones.
+//
+//go:embed *.sql
+var scripts embed.FS
+
+var scriptName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ErrSchemaTooNew is returned when the database has been migrated by a newer
+// backend-server, whose schema is unknown to this one.
+var ErrSchemaTooNew = errors.New("database schema is newer than supported")
+
+// SchemaVersionTable records migrations applied to database, one row for each.
+const SchemaVersionTable = "schema_version"
+
+type schemaVersion struct {
+	Version    int64     `gorm:"type:bigint(20) NOT NULL;column:version;primary_key"`
+	Name       string    `gorm:"type:varchar(256);column:name"`
+	GmtApplied time.Time `gorm:"type:datetime;column:gmt_applied"`
+}
+
+func (schemaVersion) TableName() string {
+	return SchemaVersionTable
+}
+
+// Migration is a numbered schema change, Up applies it and Down reverts it.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns migrations embedded in backend-server ordered by version.
+func Migrations() ([]Migration, error) {
+	return loadMigrations(scripts)
+}
+
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := scriptName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration script name %s", entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			return nil, fmt.Errorf("migration %d is missing", i+1)
+		}
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down scripts", migration.Version, migration.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// statements splits a script into statements, which are terminated by a
+// semicolon at the end of line, lines starting with -- are comments.
+func statements(script string) []string {
+	var (
+		result  []string
+		current []string
+	)
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current = append(current, line)
+		if strings.HasSuffix(trimmed, ";") {
+			statement := strings.TrimSpace(strings.Join(current, "\n"))
+			result = append(result, strings.TrimSuffix(statement, ";"))
+			current = current[:0]
+		}
+	}
+	if len(current) > 0 {
+		result = append(result, strings.TrimSpace(strings.Join(current, "\n")))
+	}
+	return result
+}
+
+// MigrationLock names the mysql lock held while migrating, so replicas of
+// backend-server starting together apply each migration once.
+const MigrationLock = "schema_migration"
+
+// migrationLockTimeout bounds the wait for a replica migrating ahead.
+const migrationLockTimeout = 5 * time.Minute
+
+// Migrator migrates database schema up and down between versions, and
+// records applied migrations in SchemaVersionTable. DDL statements of mysql
+// commit implicitly, a failed migration has to be fixed by hand before
+// retrying it.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Latest returns the version of the newest migration known.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version database has been migrated to, 0 if no
+// migration has been applied.
+func (m *Migrator) Version() (int64, error) {
+	if !m.db.HasTable(&schemaVersion{}) {
+		return 0, nil
+	}
+	current := schemaVersion{}
+	if err := m.db.Order("version DESC").First(&current).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return current.Version, nil
+}
+
+// Check refuses database migrated by a newer backend-server and returns the
+// version of database.
+func (m *Migrator) Check() (int64, error) {
+	version, err := m.Version()
+	if err != nil {
+		return 0, err
+	}
+	if version > m.Latest() {
+		return version, fmt.Errorf("%w: database is at version %d, while backend-server supports up to %d",
+			ErrSchemaTooNew, version, m.Latest())
+	}
+	return version, nil
+}
+
+// Up applies migrations newer than database until target version, the
+// version is checked again once MigrationLock is held.
+func (m *Migrator) Up(target int64) error {
+	return m.withLock(func() error { return m.up(target) })
+}
+
+func (m *Migrator) up(target int64) error {
+	if target > m.Latest() {
+		return fmt.Errorf("unknown schema version %d, latest is %d", target, m.Latest())
+	}
+	if !m.db.HasTable(&schemaVersion{}) {
+		klog.Infof("database has not table %s, try to create it", SchemaVersionTable)
+		if err := m.db.CreateTable(&schemaVersion{}).Error; err != nil {
+			return err
+		}
+	}
+	version, err := m.Check()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range m.migrations {
+		if migration.Version <= version || migration.Version > target {
+			continue
+		}
+		klog.Infof("apply schema migration %d_%s", migration.Version, migration.Name)
+		if err := m.exec(migration.Up); err != nil {
+			return fmt.Errorf("fail to apply migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		applied := &schemaVersion{Version: migration.Version, Name: migration.Name, GmtApplied: time.Now().UTC()}
+		if err := m.db.Create(applied).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts migrations newer than target version.
+func (m *Migrator) Down(target int64) error {
+	return m.withLock(func() error { return m.down(target) })
+}
+
+func (m *Migrator) down(target int64) error {
+	if target < 0 {
+		return fmt.Errorf("invalid schema version %d", target)
+	}
+	version, err := m.Check()
+	if err != nil {
+		return err
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version > version || migration.Version <= target {
+			continue
+		}
+		klog.Infof("revert schema migration %d_%s", migration.Version, migration.Name)
+		if err := m.exec(migration.Down); err != nil {
+			return fmt.Errorf("fail to revert migration %d_%s: %v", migration.Version, migration.Name, err)
+		}
+		if err := m.db.Where(&schemaVersion{Version: migration.Version}).Delete(&schemaVersion{}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLock runs migrate holding MigrationLock. The lock belongs to a mysql
+// session, it is taken on a connection of its own which is kept out of the
+// pool until the lock is released.
+func (m *Migrator) withLock(migrate func() error) error {
+	ctx := context.Background()
+	conn, err := m.db.DB().Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", MigrationLock,
+		int64(migrationLockTimeout.Seconds())).Scan(&acquired); err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("timeout to acquire lock %s, another backend-server may be migrating", MigrationLock)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", MigrationLock); err != nil {
+			klog.Errorf("fail to release lock %s: %v", MigrationLock, err)
+		}
+	}()
+	return migrate()
+}
+
+func (m *Migrator) exec(script string) error {
+	for _, statement := range statements(script) {
+		if err := m.db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
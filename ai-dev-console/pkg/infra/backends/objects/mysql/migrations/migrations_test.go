@@ -0,0 +1,133 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrations
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatalf("fail to load embedded migrations: %v", err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "initial" {
+		t.Errorf("Migrations() = %v, want initial migration first", migrations)
+	}
+	for _, migration := range migrations {
+		if len(statements(migration.Up)) == 0 || len(statements(migration.Down)) == 0 {
+			t.Errorf("migration %d_%s has empty scripts", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []Migration
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			fsys: fstest.MapFS{
+				"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX;")},
+				"0002_add_index.down.sql": {Data: []byte("DROP INDEX;")},
+				"0001_initial.up.sql":     {Data: []byte("CREATE TABLE;")},
+				"0001_initial.down.sql":   {Data: []byte("DROP TABLE;")},
+			},
+			want: []Migration{
+				{Version: 1, Name: "initial", Up: "CREATE TABLE;", Down: "DROP TABLE;"},
+				{Version: 2, Name: "add_index", Up: "CREATE INDEX;", Down: "DROP INDEX;"},
+			},
+		},
+		{
+			name: "missing down script",
+			fsys: fstest.MapFS{
+				"0001_initial.up.sql": {Data: []byte("CREATE TABLE;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing version",
+			fsys: fstest.MapFS{
+				"0002_add_index.up.sql":   {Data: []byte("CREATE INDEX;")},
+				"0002_add_index.down.sql": {Data: []byte("DROP INDEX;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scripts of a version named differently",
+			fsys: fstest.MapFS{
+				"0001_initial.up.sql":  {Data: []byte("CREATE TABLE;")},
+				"0001_tables.down.sql": {Data: []byte("DROP TABLE;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid script name",
+			fsys: fstest.MapFS{
+				"initial.sql": {Data: []byte("CREATE TABLE;")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.fsys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadMigrations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "statements over several lines",
+			script: "-- comment\nCREATE TABLE `a` (\n  `id` bigint(20)\n);\n\nDROP TABLE `b`;\n",
+			want:   []string{"CREATE TABLE `a` (\n  `id` bigint(20)\n)", "DROP TABLE `b`"},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "DROP TABLE `a`;\nDROP TABLE `b`",
+			want:   []string{"DROP TABLE `a`", "DROP TABLE `b`"},
+		},
+		{
+			name:   "comments only",
+			script: "-- nothing to do\n",
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/utils"
+)
+
+// Constants down below defines configurations to initialize a postgres backend
+// storage service, user should set environment variables in Dockerfile or
+// deployment manifests files, for security user should better init environment
+// variables by referencing Secret key as down below:
+// spec:
+//
+//	containers:
+//	- name: xxx-container
+//	  image: xxx
+//	  env:
+//	    - name: POSTGRES_PASSWORD
+//	      valueFrom:
+//	        secretKeyRef:
+//	          name: my-postgres-secret
+//	          key: password
+const (
+	EnvPostgresHost     = "POSTGRES_HOST"
+	EnvPostgresPort     = "POSTGRES_PORT"
+	EnvPostgresDatabase = "POSTGRES_DB_NAME"
+	EnvPostgresUser     = "POSTGRES_USER"
+	EnvPostgresPassword = "POSTGRES_PASSWORD"
+	EnvPostgresSSLMode  = "POSTGRES_SSLMODE"
+	EnvPostgresLogMode  = "POSTGRES_LOGMODE"
+)
+
+// Constants down below defines configurations to initialize an embedded sqlite
+// backend storage service, the database file should be placed in a persistent
+// volume, or objects are lost when the console restarts.
+const (
+	EnvSQLitePath    = "SQLITE_PATH"
+	EnvSQLiteLogMode = "SQLITE_LOGMODE"
+)
+
+func GetPostgresDBSource() (dbSource, logMode string, err error) {
+	host := utils.GetEnvOrDefault(EnvPostgresHost, "ack-postgres.kube-ai.svc.cluster.local")
+	port, err := strconv.Atoi(utils.GetEnvOrDefault(EnvPostgresPort, "5432"))
+	if err != nil {
+		return "", "", err
+	}
+	db := utils.GetEnvOrDefault(EnvPostgresDatabase, "kubeai")
+	user := utils.GetEnvOrDefault(EnvPostgresUser, "kubeai")
+	password := utils.GetEnvOrDefault(EnvPostgresPassword, "kubeai@ACK")
+	sslMode := utils.GetEnvOrDefault(EnvPostgresSSLMode, "disable")
+
+	dbSource = (&url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, strconv.Itoa(port)),
+		Path:     "/" + db,
+		RawQuery: url.Values{"sslmode": []string{sslMode}}.Encode(),
+	}).String()
+	logMode = utils.GetEnvOrDefault(EnvPostgresLogMode, "error")
+	return dbSource, logMode, nil
+}
+
+func GetSQLiteDBSource() (dbSource, logMode string) {
+	dbSource = utils.GetEnvOrDefault(EnvSQLitePath, "/var/lib/kubeai/kubeai.db")
+	logMode = utils.GetEnvOrDefault(EnvSQLiteLogMode, "error")
+	return dbSource, logMode
+}
@@ -0,0 +1,376 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+
+	"github.com/jinzhu/gorm"
+	"k8s.io/klog"
+)
+
+var modelStages = map[string]bool{
+	dmo.ModelStageNone:       true,
+	dmo.ModelStageStaging:    true,
+	dmo.ModelStageProduction: true,
+	dmo.ModelStageArchived:   true,
+}
+
+var modelArtifactTypes = map[string]bool{
+	dmo.ModelArtifactOSS:   true,
+	dmo.ModelArtifactPVC:   true,
+	dmo.ModelArtifactImage: true,
+}
+
+func (b *sqlBackend) ListModels(query *backends.ModelsQuery) ([]*dmo.Model, error) {
+	klog.V(3).Infof("[sql.ListModels] list models, query: %v", query)
+	models := make([]*dmo.Model, 0, initListSize)
+	db := b.db.Model(&dmo.Model{})
+
+	if query.ModelName != "" {
+		db = db.Where("model_name LIKE ?", "%"+query.ModelName+"%")
+	}
+	if query.ModelVersion != "" {
+		db = db.Where("model_version = ?", query.ModelVersion)
+	}
+	if query.Stage != "" {
+		db = db.Where("stage = ?", query.Stage)
+	}
+	if query.JobID != "" {
+		db = db.Where("job_id = ?", query.JobID)
+	}
+
+	if query.Pagination != nil {
+		db = db.Count(&query.Pagination.Count).
+			Limit(query.Pagination.PageSize).
+			Offset((query.Pagination.PageNum - 1) * query.Pagination.PageSize)
+	}
+	db = db.Order("id DESC")
+
+	db = db.Find(&models)
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	if err := b.loadModelArtifacts(b.db, models...); err != nil {
+		return nil, err
+	}
+	return models, nil
+}
+
+func (b *sqlBackend) GetModel(modelID string) (*dmo.Model, error) {
+	id, err := parseModelID(modelID)
+	if err != nil {
+		return nil, err
+	}
+	model, err := b.getModel(b.db, id)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.loadModelArtifacts(b.db, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (b *sqlBackend) getModel(db *gorm.DB, id uint64) (*dmo.Model, error) {
+	model := dmo.Model{}
+	result := db.Where(&dmo.Model{ID: id}).First(&model)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &model, nil
+}
+
+// loadModelArtifacts fills artifacts of models from the model_artifact table.
+func (b *sqlBackend) loadModelArtifacts(db *gorm.DB, models ...*dmo.Model) error {
+	if len(models) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(models))
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+	artifacts := make([]dmo.ModelArtifact, 0, len(models))
+	if err := db.Where("model_id IN (?)", ids).Order("id").Find(&artifacts).Error; err != nil {
+		return err
+	}
+	byModel := make(map[uint64][]dmo.ModelArtifact, len(models))
+	for _, artifact := range artifacts {
+		byModel[artifact.ModelID] = append(byModel[artifact.ModelID], artifact)
+	}
+	for _, model := range models {
+		model.Artifacts = byModel[model.ID]
+	}
+	return nil
+}
+
+func (b *sqlBackend) DeleteModel(modelID string) error {
+	id, err := parseModelID(modelID)
+	if err != nil {
+		return err
+	}
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("model_id = ?", id).Delete(&dmo.ModelArtifact{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("model_id = ?", id).Delete(&dmo.ModelStageTransition{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(&dmo.Model{ID: id}).Delete(&dmo.Model{}).Error
+	})
+	if err != nil {
+		klog.Errorf("fail to delete model: %s, %s", modelID, err.Error())
+		return err
+	}
+	return nil
+}
+
+// WriteModel registers a new version of model. Versions are unique under a
+// model name, a version is assigned next to the largest numeric version of
+// the model if it is not specified.
+func (b *sqlBackend) WriteModel(model *dmo.Model) error {
+	return b.writeModel(model, false)
+}
+
+// RegisterModelOfJob registers the model version output by a job, a job
+// registers a single version.
+func (b *sqlBackend) RegisterModelOfJob(model *dmo.Model) error {
+	if model.JobID == "" {
+		return fmt.Errorf("job id of model should not be empty")
+	}
+	return b.writeModel(model, true)
+}
+
+// writeModel writes a model version, it refuses a second version of the same
+// job if uniqueJob.
+func (b *sqlBackend) writeModel(model *dmo.Model, uniqueJob bool) error {
+	klog.V(3).Infof("[sql.WriteModel] create model: %s, version: %s", model.Name, model.Version)
+	if model.Name == "" {
+		return fmt.Errorf("model name should not be empty")
+	}
+	if model.Stage == "" {
+		model.Stage = dmo.ModelStageNone
+	}
+	if !modelStages[model.Stage] {
+		return fmt.Errorf("unknown model stage %s", model.Stage)
+	}
+	// Models used to be located only by OSS paths.
+	if model.OSSPath != "" && !hasModelArtifact(model, dmo.ModelArtifactOSS, model.OSSPath) {
+		model.Artifacts = append(model.Artifacts, dmo.ModelArtifact{Type: dmo.ModelArtifactOSS, URI: model.OSSPath})
+	}
+	for _, artifact := range model.Artifacts {
+		if !modelArtifactTypes[artifact.Type] {
+			return fmt.Errorf("unknown model artifact type %s", artifact.Type)
+		}
+		if artifact.URI == "" {
+			return fmt.Errorf("uri of %s artifact should not be empty", artifact.Type)
+		}
+	}
+	now := time.Now()
+	if model.GmtCreated.IsZero() {
+		model.GmtCreated = now
+	}
+	model.GmtModified = now
+
+	// Queries go through tx only, sqlite holds a single connection which is
+	// occupied by the transaction.
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if model.Version == "" {
+			version, err := nextModelVersion(tx, model.Name)
+			if err != nil {
+				return err
+			}
+			model.Version = version
+		} else {
+			count := 0
+			if err := tx.Model(&dmo.Model{}).Where("model_name = ? AND model_version = ?", model.Name, model.Version).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("version %s of model %s already exists", model.Version, model.Name)
+			}
+		}
+		// The job is checked after the version is allocated, writers of the same
+		// job racing across replicas either see each other here or collide on
+		// uk_model_name_version.
+		if uniqueJob {
+			count := 0
+			if err := tx.Model(&dmo.Model{}).Where("job_id = ?", model.JobID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: job %s", backends.ErrModelOfJobExists, model.JobID)
+			}
+		}
+
+		if err := tx.Create(model).Error; err != nil {
+			return err
+		}
+		for i := range model.Artifacts {
+			artifact := &model.Artifacts[i]
+			artifact.ID = 0
+			artifact.ModelID = model.ID
+			artifact.GmtCreated = model.GmtCreated
+			if err := tx.Create(artifact).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		klog.Errorf("fail to create model, %s, %s", model.Name, err.Error())
+		return err
+	}
+	return nil
+}
+
+// nextModelVersion returns the version next to the largest numeric version of
+// a model, versions not numeric are skipped.
+func nextModelVersion(db *gorm.DB, name string) (string, error) {
+	var versions []string
+	if err := db.Model(&dmo.Model{}).Where("model_name = ?", name).Pluck("model_version", &versions).Error; err != nil {
+		return "", err
+	}
+	var latest uint64
+	for _, version := range versions {
+		if v, err := strconv.ParseUint(version, 10, 64); err == nil && v > latest {
+			latest = v
+		}
+	}
+	return strconv.FormatUint(latest+1, 10), nil
+}
+
+func hasModelArtifact(model *dmo.Model, artifactType, uri string) bool {
+	for _, artifact := range model.Artifacts {
+		if artifact.Type == artifactType && artifact.URI == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateModel updates the description, tags and metadata of a model version,
+// name, version, lineage and artifacts of a registered version are immutable,
+// and stage is changed by TransitionModelStage only.
+func (b *sqlBackend) UpdateModel(model *dmo.Model) error {
+	klog.V(3).Infof("[sql.UpdateModel] update model: %d", model.ID)
+	if _, err := b.getModel(b.db, model.ID); err != nil {
+		return err
+	}
+	return b.db.Model(&dmo.Model{ID: model.ID}).Updates(map[string]interface{}{
+		"description":  model.Description,
+		"tags":         model.Tags,
+		"metadata":     model.Metadata,
+		"gmt_modified": time.Now(),
+	}).Error
+}
+
+// TransitionModelStage moves a model version to stage and records the
+// transition. A model has at most one version in Production, the version
+// previously in Production is archived when another one is promoted.
+func (b *sqlBackend) TransitionModelStage(modelID, stage, operator, comment string) (*dmo.Model, error) {
+	klog.V(3).Infof("[sql.TransitionModelStage] transition model %s to stage %s", modelID, stage)
+	id, err := parseModelID(modelID)
+	if err != nil {
+		return nil, err
+	}
+	if !modelStages[stage] {
+		return nil, fmt.Errorf("unknown model stage %s", stage)
+	}
+
+	var model *dmo.Model
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		model, err = b.getModel(tx, id)
+		if err != nil {
+			return err
+		}
+		if model.Stage == stage {
+			return nil
+		}
+
+		if stage == dmo.ModelStageProduction {
+			var production []*dmo.Model
+			if err = tx.Where("model_name = ? AND stage = ? AND id <> ?", model.Name, dmo.ModelStageProduction, model.ID).
+				Find(&production).Error; err != nil {
+				return err
+			}
+			for _, previous := range production {
+				archiveComment := fmt.Sprintf("superseded by version %s", model.Version)
+				if err = transitModelStage(tx, previous, dmo.ModelStageArchived, operator, archiveComment); err != nil {
+					return err
+				}
+			}
+		}
+		return transitModelStage(tx, model, stage, operator, comment)
+	})
+	if err != nil {
+		klog.Errorf("fail to transition model %s to stage %s, %s", modelID, stage, err.Error())
+		return nil, err
+	}
+	if err = b.loadModelArtifacts(b.db, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func transitModelStage(tx *gorm.DB, model *dmo.Model, stage, operator, comment string) error {
+	now := time.Now()
+	transition := &dmo.ModelStageTransition{
+		ModelID:    model.ID,
+		FromStage:  model.Stage,
+		ToStage:    stage,
+		Operator:   operator,
+		Comment:    comment,
+		GmtCreated: now,
+	}
+	if err := tx.Create(transition).Error; err != nil {
+		return err
+	}
+	model.Stage = stage
+	model.GmtModified = now
+	return tx.Model(&dmo.Model{ID: model.ID}).Updates(map[string]interface{}{
+		"stage":        stage,
+		"gmt_modified": now,
+	}).Error
+}
+
+func (b *sqlBackend) ListModelStageTransitions(modelID string) ([]*dmo.ModelStageTransition, error) {
+	id, err := parseModelID(modelID)
+	if err != nil {
+		return nil, err
+	}
+	transitions := make([]*dmo.ModelStageTransition, 0, initListSize)
+	if err = b.db.Where("model_id = ?", id).Order("id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
+}
+
+func parseModelID(modelID string) (uint64, error) {
+	id, err := strconv.ParseUint(modelID, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid model id %s", modelID)
+	}
+	return id, nil
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+)
+
+func NewPostgresBackendService() backends.ObjectStorageBackend {
+	return NewBackendService(&postgresDialect{})
+}
+
+var _ Dialect = &postgresDialect{}
+
+type postgresDialect struct{}
+
+var postgresColumnTypes = &columnTypes{
+	autoIncrement: "BIGSERIAL PRIMARY KEY",
+	types: map[string]string{
+		"tinyint":  "SMALLINT",
+		"integer":  "INTEGER",
+		"bigint":   "BIGINT",
+		"datetime": "TIMESTAMP",
+	},
+}
+
+func (d *postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (d *postgresDialect) Open() (*gorm.DB, error) {
+	dbSource, logMode, err := GetPostgresDBSource()
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open("postgres", dbSource)
+	if err != nil {
+		return nil, err
+	}
+	db.LogMode(logMode == "debug")
+	return db, nil
+}
+
+func (d *postgresDialect) CreateTables(db *gorm.DB) error {
+	return createTables(db, postgresColumnTypes)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+
+	"github.com/jinzhu/gorm"
+	"k8s.io/klog"
+)
+
+// tables lists dmo objects persisted by the sql object backend.
+var tables = []interface{}{
+	&dmo.Pod{},
+	&dmo.Job{},
+	&dmo.Cron{},
+	&dmo.Model{},
+	&dmo.ModelArtifact{},
+	&dmo.ModelStageTransition{},
+	&dmo.EvaluateJob{},
+	&dmo.Notebook{},
+}
+
+// columnTypes translates the MySQL column types declared in dmo tags, e.g.
+// bigint(20) NOT NULL AUTO_INCREMENT or tinyint(4), into types of a dialect.
+type columnTypes struct {
+	// autoIncrement is the column definition of auto increment primary keys.
+	autoIncrement string
+	// types maps MySQL type names to type names of the dialect, the display
+	// width of mapped types is dropped, types not in the map are kept as is.
+	types map[string]string
+}
+
+var mysqlColumnType = regexp.MustCompile(`^(\w+)(\(\d+\))?(.*)$`)
+
+// columnType returns the column definition translated from a MySQL one.
+func (c *columnTypes) columnType(mysqlType string) string {
+	match := mysqlColumnType.FindStringSubmatch(strings.TrimSpace(mysqlType))
+	if match == nil {
+		return mysqlType
+	}
+	name, size, options := strings.ToLower(match[1]), match[2], strings.ToUpper(match[3])
+	if strings.Contains(options, "AUTO_INCREMENT") {
+		return c.autoIncrement
+	}
+
+	columnType := name + size
+	if typ, ok := c.types[name]; ok {
+		columnType = typ
+	}
+	if strings.Contains(options, "NOT NULL") {
+		columnType += " NOT NULL"
+	}
+	return columnType
+}
+
+// columnDefinition returns the definition of a dmo field in CREATE TABLE or
+// ALTER TABLE statements.
+func (c *columnTypes) columnDefinition(scope *gorm.Scope, field *gorm.StructField) string {
+	var columnType string
+	if mysqlType, ok := field.TagSettingsGet("TYPE"); ok {
+		columnType = c.columnType(mysqlType)
+	} else {
+		columnType = scope.Dialect().DataTypeOf(field)
+	}
+	if value, ok := field.TagSettingsGet("DEFAULT"); ok {
+		columnType += " DEFAULT " + value
+	}
+	return scope.Quote(field.DBName) + " " + columnType
+}
+
+// createTables creates tables of dmo objects which have not been created in
+// database, and adds the columns and indexes missing in existing tables, so
+// that fields appended to dmo objects will not break an existing database.
+func createTables(db *gorm.DB, types *columnTypes) error {
+	for _, table := range tables {
+		scope := db.NewScope(table)
+		tableName := scope.TableName()
+
+		if !db.HasTable(table) {
+			klog.Infof("database has not table %s, try to create it", tableName)
+			columns := make([]string, 0, len(scope.GetModelStruct().StructFields))
+			for _, field := range scope.GetModelStruct().StructFields {
+				if field.IsIgnored || !field.IsNormal {
+					continue
+				}
+				columns = append(columns, types.columnDefinition(scope, field))
+			}
+			sql := fmt.Sprintf("CREATE TABLE %s (%s)", scope.QuotedTableName(), strings.Join(columns, ", "))
+			if err := db.Exec(sql).Error; err != nil {
+				return err
+			}
+		} else if err := addColumns(db, scope, types); err != nil {
+			return err
+		}
+
+		if err := addIndexes(db, scope); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumns adds the columns of a dmo object missing in its table.
+func addColumns(db *gorm.DB, scope *gorm.Scope, types *columnTypes) error {
+	tableName := scope.TableName()
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsIgnored || !field.IsNormal || field.IsPrimaryKey {
+			continue
+		}
+		if scope.Dialect().HasColumn(tableName, field.DBName) {
+			continue
+		}
+		klog.Infof("table %s has not column %s, try to add it", tableName, field.DBName)
+		sql := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", scope.QuotedTableName(), types.columnDefinition(scope, field))
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tableIndex is an index declared by index or unique_index tags of dmo fields,
+// fields tagged with the same index name make up a composite index.
+type tableIndex struct {
+	name    string
+	unique  bool
+	columns []string
+}
+
+// tableIndexes returns indexes declared by dmo fields of a table, in the
+// order they are first declared.
+func tableIndexes(scope *gorm.Scope) []*tableIndex {
+	var indexes []*tableIndex
+	byName := make(map[string]*tableIndex)
+	for _, field := range scope.GetModelStruct().StructFields {
+		if field.IsIgnored || !field.IsNormal {
+			continue
+		}
+		for _, key := range []string{"INDEX", "UNIQUE_INDEX"} {
+			value, ok := field.TagSettingsGet(key)
+			if !ok {
+				continue
+			}
+			for _, name := range strings.Split(value, ",") {
+				// A bare tag, e.g. `gorm:"index"`, is named after the column.
+				if name == "" || name == key {
+					name = fmt.Sprintf("idx_%s_%s", scope.TableName(), field.DBName)
+				}
+				index, ok := byName[name]
+				if !ok {
+					index = &tableIndex{name: name, unique: key == "UNIQUE_INDEX"}
+					byName[name] = index
+					indexes = append(indexes, index)
+				}
+				index.columns = append(index.columns, field.DBName)
+			}
+		}
+	}
+	return indexes
+}
+
+// addIndexes creates the indexes of a dmo object missing in its table.
+func addIndexes(db *gorm.DB, scope *gorm.Scope) error {
+	tableName := scope.TableName()
+	for _, index := range tableIndexes(scope) {
+		if scope.Dialect().HasIndex(tableName, index.name) {
+			continue
+		}
+		klog.Infof("table %s has not index %s, try to add it", tableName, index.name)
+		var result *gorm.DB
+		if index.unique {
+			result = db.Model(scope.Value).AddUniqueIndex(index.name, index.columns...)
+		} else {
+			result = db.Model(scope.Value).AddIndex(index.name, index.columns...)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
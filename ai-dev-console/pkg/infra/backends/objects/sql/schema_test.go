@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"testing"
+)
+
+func TestColumnType(t *testing.T) {
+	tests := []struct {
+		name      string
+		types     *columnTypes
+		mysqlType string
+		want      string
+	}{
+		{
+			name:      "postgres auto increment primary key",
+			types:     postgresColumnTypes,
+			mysqlType: "bigint(20) NOT NULL AUTO_INCREMENT",
+			want:      "BIGSERIAL PRIMARY KEY",
+		},
+		{
+			name:      "postgres tinyint",
+			types:     postgresColumnTypes,
+			mysqlType: "tinyint(4)",
+			want:      "SMALLINT",
+		},
+		{
+			name:      "postgres integer with display width",
+			types:     postgresColumnTypes,
+			mysqlType: "integer(32)",
+			want:      "INTEGER",
+		},
+		{
+			name:      "postgres datetime",
+			types:     postgresColumnTypes,
+			mysqlType: "datetime",
+			want:      "TIMESTAMP",
+		},
+		{
+			name:      "postgres varchar keeps its length",
+			types:     postgresColumnTypes,
+			mysqlType: "varchar(256)",
+			want:      "varchar(256)",
+		},
+		{
+			name:      "postgres not null",
+			types:     postgresColumnTypes,
+			mysqlType: "varchar(50) NOT NULL",
+			want:      "varchar(50) NOT NULL",
+		},
+		{
+			name:      "postgres text",
+			types:     postgresColumnTypes,
+			mysqlType: "text",
+			want:      "text",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.types.columnType(tt.mysqlType); got != tt.want {
+				t.Errorf("columnType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+)
+
+func TestSortPodsByIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		pods []string
+		want []string
+	}{
+		{
+			name: "numeric order of indexes",
+			pods: []string{"job-worker-10", "job-worker-2", "job-worker-1"},
+			want: []string{"job-worker-1", "job-worker-2", "job-worker-10"},
+		},
+		{
+			name: "pods with the same index keep their order",
+			pods: []string{"job-worker-1", "job-ps-0", "job-worker-0"},
+			want: []string{"job-ps-0", "job-worker-0", "job-worker-1"},
+		},
+		{
+			name: "pods without index go first",
+			pods: []string{"job-worker-1", "job-launcher", "launcher"},
+			want: []string{"job-launcher", "launcher", "job-worker-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := make([]*dmo.Pod, 0, len(tt.pods))
+			for _, name := range tt.pods {
+				pods = append(pods, &dmo.Pod{Name: name})
+			}
+			sortPodsByIndex(pods)
+			got := make([]string, 0, len(pods))
+			for _, pod := range pods {
+				got = append(got, pod.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sortPodsByIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
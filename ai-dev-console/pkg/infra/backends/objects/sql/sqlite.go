@@ -0,0 +1,87 @@
+//go:build cgo
+
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// memoryDBSource opens a sqlite database living in memory, which is dropped
+// once the backend is closed.
+const memoryDBSource = ":memory:"
+
+// NewSQLiteBackendService returns an object storage backend embedding a sqlite
+// database, which suits small teams and tests without a database server. The
+// sqlite driver requires cgo, so the backend is absent from CGO_ENABLED=0
+// builds.
+func NewSQLiteBackendService() backends.ObjectStorageBackend {
+	return NewBackendService(&sqliteDialect{})
+}
+
+var _ Dialect = &sqliteDialect{}
+
+type sqliteDialect struct {
+	// dbSource overrides the database file read from environment variables.
+	dbSource string
+}
+
+var sqliteColumnTypes = &columnTypes{
+	autoIncrement: "INTEGER PRIMARY KEY AUTOINCREMENT",
+	types: map[string]string{
+		"tinyint": "INTEGER",
+		"integer": "INTEGER",
+		"bigint":  "INTEGER",
+	},
+}
+
+func (d *sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (d *sqliteDialect) Open() (*gorm.DB, error) {
+	dbSource, logMode := GetSQLiteDBSource()
+	if d.dbSource != "" {
+		dbSource = d.dbSource
+	}
+	if dbSource != memoryDBSource {
+		if err := os.MkdirAll(filepath.Dir(dbSource), 0755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := gorm.Open("sqlite3", dbSource)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows only one writer at a time, serialize all statements through
+	// a single connection rather than failing with 'database is locked', it
+	// also keeps an in-memory database alive across statements.
+	db.DB().SetMaxOpenConns(1)
+	db.LogMode(logMode == "debug")
+	return db, nil
+}
+
+func (d *sqliteDialect) CreateTables(db *gorm.DB) error {
+	return createTables(db, sqliteColumnTypes)
+}
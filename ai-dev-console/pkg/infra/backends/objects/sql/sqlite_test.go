@@ -0,0 +1,225 @@
+//go:build cgo
+
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sql
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	training "github.com/AliyunContainerService/data-on-ack/ai-dev-console/apis/training/v1alpha1"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/utils"
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/dmo"
+	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestSQLiteBackend(t *testing.T) backends.ObjectStorageBackend {
+	backend := NewBackendService(&sqliteDialect{dbSource: memoryDBSource})
+	if err := backend.Initialize(); err != nil {
+		t.Fatalf("fail to initialize sqlite backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+func newTestTFJob(resourceVersion string, conditions ...apiv1.JobConditionType) *training.TFJob {
+	job := &training.TFJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "tfjob-test",
+			Namespace:         "kubedl-test",
+			UID:               "6f06d2fd-22c6-11e9-96bb-0242ac1d5327",
+			ResourceVersion:   resourceVersion,
+			CreationTimestamp: metav1.Time{Time: time.Date(2019, 2, 10, 12, 27, 0, 0, time.UTC)},
+			Labels:            map[string]string{"createdBy": "User"},
+		},
+	}
+	for _, cond := range conditions {
+		job.Status.Conditions = append(job.Status.Conditions, apiv1.JobCondition{Type: cond})
+	}
+	return job
+}
+
+func TestSQLiteJobStorageBackend(t *testing.T) {
+	backend := newTestSQLiteBackend(t)
+	kind := training.TFJobKind
+
+	writeJob := func(job *training.TFJob) {
+		if err := backend.WriteJob(job, kind, job.Spec.TFReplicaSpecs, &job.Spec.RunPolicy, &job.Status, ""); err != nil {
+			t.Fatalf("fail to write job: %v", err)
+		}
+	}
+	readJob := func() apiv1.JobConditionType {
+		job, err := backend.ReadJob("kubedl-test", "tfjob-test", "6f06d2fd-22c6-11e9-96bb-0242ac1d5327", kind, "")
+		if err != nil {
+			t.Fatalf("fail to read job: %v", err)
+		}
+		return job.Status
+	}
+
+	writeJob(newTestTFJob("1", apiv1.JobCreated))
+	writeJob(newTestTFJob("2", apiv1.JobCreated, apiv1.JobRunning))
+	if status := readJob(); status != apiv1.JobRunning {
+		t.Errorf("status after update = %v, want %v", status, apiv1.JobRunning)
+	}
+	// A stale version must not overwrite the newer record.
+	writeJob(newTestTFJob("1", apiv1.JobCreated))
+	if status := readJob(); status != apiv1.JobRunning {
+		t.Errorf("status after stale update = %v, want %v", status, apiv1.JobRunning)
+	}
+
+	notDeleted := 0
+	query := &backends.Query{
+		Namespace: "kubedl-test",
+		StartTime: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndTime:   time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		Deleted:   &notDeleted,
+	}
+	jobs, err := backend.ListJobs(query)
+	if err != nil {
+		t.Fatalf("fail to list jobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].Name != "tfjob-test" {
+		t.Errorf("ListJobs() = %v, want tfjob-test", jobs)
+	}
+
+	if err := backend.UpdateJobRecordStopped("kubedl-test", "tfjob-test", "6f06d2fd-22c6-11e9-96bb-0242ac1d5327", kind, ""); err != nil {
+		t.Fatalf("fail to stop job: %v", err)
+	}
+	if status := readJob(); status != utils.JobStopped {
+		t.Errorf("status after stopped = %v, want %v", status, utils.JobStopped)
+	}
+
+	if err := backend.RemoveJobRecord("kubedl-test", "tfjob-test", "6f06d2fd-22c6-11e9-96bb-0242ac1d5327", kind, ""); err != nil {
+		t.Fatalf("fail to remove job: %v", err)
+	}
+	jobs, err = backend.ListJobs(query)
+	if err != nil {
+		t.Fatalf("fail to list jobs: %v", err)
+	}
+	if len(jobs) != 0 {
+		t.Errorf("ListJobs() after removed = %v, want none", jobs)
+	}
+}
+
+func TestSQLiteModelRegistry(t *testing.T) {
+	backend := newTestSQLiteBackend(t)
+
+	writeModel := func(model *dmo.Model) *dmo.Model {
+		if err := backend.WriteModel(model); err != nil {
+			t.Fatalf("fail to write model: %v", err)
+		}
+		return model
+	}
+	stageOf := func(model *dmo.Model) string {
+		got, err := backend.GetModel(strconv.FormatUint(model.ID, 10))
+		if err != nil {
+			t.Fatalf("fail to get model: %v", err)
+		}
+		return got.Stage
+	}
+
+	v1 := writeModel(&dmo.Model{
+		Name:    "mnist",
+		JobID:   "6f06d2fd-22c6-11e9-96bb-0242ac1d5327",
+		OSSPath: "oss://bucket/mnist/1",
+		Tags:    dmo.ModelTags{"cnn"},
+	})
+	v2 := writeModel(&dmo.Model{
+		Name:      "mnist",
+		Artifacts: []dmo.ModelArtifact{{Type: dmo.ModelArtifactImage, URI: "registry/mnist:2"}},
+	})
+	if v1.Version != "1" || v2.Version != "2" {
+		t.Errorf("auto assigned versions = %s, %s, want 1, 2", v1.Version, v2.Version)
+	}
+	if err := backend.WriteModel(&dmo.Model{Name: "mnist", Version: "2"}); err == nil {
+		t.Errorf("write a duplicated version, want error")
+	}
+	registry := backend.(backends.JobModelRegistry)
+	if err := registry.RegisterModelOfJob(&dmo.Model{Name: "mnist", JobID: v1.JobID}); !errors.Is(err, backends.ErrModelOfJobExists) {
+		t.Errorf("register a second model of job, got %v, want %v", err, backends.ErrModelOfJobExists)
+	}
+	// Models written by users are not restricted to a version per job.
+	if resnet := writeModel(&dmo.Model{Name: "resnet", JobID: v1.JobID}); resnet.Version != "1" {
+		t.Errorf("auto assigned version = %s, want 1", resnet.Version)
+	}
+
+	got, err := backend.GetModel(strconv.FormatUint(v1.ID, 10))
+	if err != nil {
+		t.Fatalf("fail to get model: %v", err)
+	}
+	if got.Stage != dmo.ModelStageNone || len(got.Tags) != 1 || got.Tags[0] != "cnn" {
+		t.Errorf("GetModel() = %+v, want stage None and tags [cnn]", got)
+	}
+	if len(got.Artifacts) != 1 || got.Artifacts[0].Type != dmo.ModelArtifactOSS || got.Artifacts[0].URI != "oss://bucket/mnist/1" {
+		t.Errorf("artifacts of version 1 = %v, want the oss path", got.Artifacts)
+	}
+
+	models, err := backend.ListModels(&backends.ModelsQuery{ModelName: "mnist", JobID: v1.JobID})
+	if err != nil {
+		t.Fatalf("fail to list models: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != v1.ID {
+		t.Errorf("ListModels() by job = %v, want version 1", models)
+	}
+
+	if _, err = backend.TransitionModelStage(strconv.FormatUint(v1.ID, 10), dmo.ModelStageProduction, "admin", ""); err != nil {
+		t.Fatalf("fail to transition model stage: %v", err)
+	}
+	if _, err = backend.TransitionModelStage(strconv.FormatUint(v2.ID, 10), dmo.ModelStageProduction, "admin", ""); err != nil {
+		t.Fatalf("fail to transition model stage: %v", err)
+	}
+	// Promoting version 2 archives version 1.
+	if stage := stageOf(v1); stage != dmo.ModelStageArchived {
+		t.Errorf("stage of version 1 = %s, want %s", stage, dmo.ModelStageArchived)
+	}
+	if stage := stageOf(v2); stage != dmo.ModelStageProduction {
+		t.Errorf("stage of version 2 = %s, want %s", stage, dmo.ModelStageProduction)
+	}
+	if _, err = backend.TransitionModelStage(strconv.FormatUint(v2.ID, 10), "Unknown", "admin", ""); err == nil {
+		t.Errorf("transition to an unknown stage, want error")
+	}
+
+	transitions, err := backend.ListModelStageTransitions(strconv.FormatUint(v1.ID, 10))
+	if err != nil {
+		t.Fatalf("fail to list model stage transitions: %v", err)
+	}
+	if len(transitions) != 2 ||
+		transitions[0].FromStage != dmo.ModelStageNone || transitions[0].ToStage != dmo.ModelStageProduction ||
+		transitions[1].FromStage != dmo.ModelStageProduction || transitions[1].ToStage != dmo.ModelStageArchived {
+		t.Errorf("transitions of version 1 = %v, want None -> Production -> Archived", transitions)
+	}
+
+	if err = backend.DeleteModel(strconv.FormatUint(v1.ID, 10)); err != nil {
+		t.Fatalf("fail to delete model: %v", err)
+	}
+	transitions, err = backend.ListModelStageTransitions(strconv.FormatUint(v1.ID, 10))
+	if err != nil {
+		t.Fatalf("fail to list model stage transitions: %v", err)
+	}
+	if len(transitions) != 0 {
+		t.Errorf("transitions of deleted version = %v, want none", transitions)
+	}
+	if v3 := writeModel(&dmo.Model{Name: "mnist"}); v3.Version != "3" {
+		t.Errorf("version after deleted = %s, want 3", v3.Version)
+	}
+}
@@ -72,6 +72,8 @@ type ModelsQuery struct {
 	Pagination   *QueryPagination
 	ModelName    string
 	ModelVersion string
+	Stage        string
+	JobID        string
 }
 
 type NotebookQuery struct {
@@ -0,0 +1,29 @@
+//go:build cgo
+
+/*
+Copyright 2020 The Alibaba Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/infra/backends/objects/sql"
+)
+
+func init() {
+	// Register sqlite backend service to global storage backend registry, the
+	// sqlite driver works only in cgo enabled builds.
+	NewObjectBackends = append(NewObjectBackends, sql.NewSQLiteBackendService)
+}
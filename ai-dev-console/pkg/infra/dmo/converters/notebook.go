@@ -81,11 +81,12 @@ func ConvertNotebookToDMONotebook(notebook *v1.Notebook) (*Notebook, *dmo.Notebo
 	gpu := limits.String()
 
 	status := Stopped
-	if len(notebook.Status.Conditions) < 1 {
+	state := notebook.Status.StateCondition()
+	if state == "" {
 		status = Starting
-	} else if notebook.Status.Conditions[0].Type == "Running" {
+	} else if state == "Running" {
 		status = Running
-	} else if notebook.Status.Conditions[0].Type == "Waiting" {
+	} else if state == "Waiting" || state == "Starting" {
 		status = Starting
 	}
 
@@ -17,6 +17,9 @@ limitations under the License.
 package dmo
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
 
 	apiv1 "github.com/AliyunContainerService/data-on-ack/ai-dev-console/pkg/job_controller/api/v1"
@@ -159,7 +162,7 @@ type Event struct {
 	// Message(long, human understandable description) of this event.
 	Message string `gorm:"type:text;column:message" json:"message"`
 	// Number of times this event has occurred.
-	Count int32 `gorm:"type:integer(32);column:reason" json:"count"`
+	Count int32 `gorm:"type:integer(32);column:count" json:"count"`
 	// Region indicates the physical region(IDC) this job located in.
 	Region *string `gorm:"type:varchar(64);column:region" json:"region,omitempty"`
 	// The time at which the event was first recorded.
@@ -227,15 +230,121 @@ type EvaluateJob struct {
 	GmtModified  time.Time              `gorm:"type:datetime;column:gmt_modified" json:"gmt_modified"`
 }
 
+// Model is a registered version of a model, versions of a model share the
+// same name and are unique by version.
 type Model struct {
 	//ID         uint64    `gorm:"type:bigint(20) NOT NULL AUTO_INCREMENT;column:id;key" json:"id"`
 	ID      uint64 `gorm:"type:bigint(20) NOT NULL AUTO_INCREMENT;column:id;primaryKey" json:"id"`
-	Name    string `gorm:"type:varchar(256);column:model_name" json:"model_name"`
-	Version string `gorm:"type:varchar(256);column:model_version" json:"model_version"`
+	Name    string `gorm:"type:varchar(256);column:model_name;unique_index:uk_model_name_version" json:"model_name"`
+	Version string `gorm:"type:varchar(256);column:model_version;unique_index:uk_model_name_version" json:"model_version"`
 	OSSPath string `gorm:"type:varchar(256);column:oss_path" json:"oss_path"`
 	JobID   string `gorm:"type:varchar(256);column:job_id" json:"job_id"`
+	// JobName, JobNamespace, DataSource and CodeSource together with JobID are
+	// the lineage of this version, that is the training job produced it and the
+	// data and code the job consumed.
+	JobName      string `gorm:"type:varchar(256);column:job_name" json:"job_name"`
+	JobNamespace string `gorm:"type:varchar(256);column:job_namespace" json:"job_namespace"`
+	DataSource   string `gorm:"type:varchar(256);column:data_source" json:"data_source"`
+	CodeSource   string `gorm:"type:varchar(256);column:code_source" json:"code_source"`
+	// Stage of this version, one of None, Staging, Production and Archived.
+	Stage       string        `gorm:"type:varchar(32);column:stage;default:'None'" json:"stage"`
+	Description string        `gorm:"type:varchar(1024);column:description" json:"description"`
+	Tags        ModelTags     `gorm:"type:text;column:tags" json:"tags"`
+	Metadata    ModelMetadata `gorm:"type:text;column:metadata" json:"metadata"`
 	// if created by RAM account, user is aliyun accountid, else user is username
-	User       *string   `gorm:"type:varchar(128);column:user_id" json:"user_id,omitempty"`
+	User        *string   `gorm:"type:varchar(128);column:user_id" json:"user_id,omitempty"`
+	GmtCreated  time.Time `gorm:"type:datetime;column:gmt_created" json:"gmt_created"`
+	GmtModified time.Time `gorm:"type:datetime;column:gmt_modified" json:"gmt_modified"`
+
+	// Artifacts this version is stored as, they are persisted in their own table.
+	Artifacts []ModelArtifact `gorm:"-" json:"artifacts,omitempty"`
+}
+
+const (
+	ModelStageNone       = "None"
+	ModelStageStaging    = "Staging"
+	ModelStageProduction = "Production"
+	ModelStageArchived   = "Archived"
+)
+
+// ModelTags are labels attached to a model version, persisted as a JSON array.
+type ModelTags []string
+
+func (tags ModelTags) Value() (driver.Value, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(tags)
+	return string(data), err
+}
+
+func (tags *ModelTags) Scan(src interface{}) error {
+	return scanJSON(src, tags)
+}
+
+// ModelMetadata is free-form metadata of a model version, e.g. its framework
+// or evaluation metrics, persisted as a JSON object.
+type ModelMetadata map[string]interface{}
+
+func (metadata ModelMetadata) Value() (driver.Value, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(metadata)
+	return string(data), err
+}
+
+func (metadata *ModelMetadata) Scan(src interface{}) error {
+	return scanJSON(src, metadata)
+}
+
+func scanJSON(src interface{}, dest interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if len(data) == 0 {
+			return nil
+		}
+		return json.Unmarshal(data, dest)
+	case string:
+		if data == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(data), dest)
+	default:
+		return fmt.Errorf("unsupported type %T to scan JSON from", src)
+	}
+}
+
+// ModelArtifact is where a model version is stored, a version may be stored as
+// several artifacts, e.g. files in OSS and a serving image.
+type ModelArtifact struct {
+	ID      uint64 `gorm:"type:bigint(20) NOT NULL AUTO_INCREMENT;column:id;primaryKey" json:"id"`
+	ModelID uint64 `gorm:"type:bigint(20) NOT NULL;column:model_id;index:idx_model_artifact_model_id" json:"model_id"`
+	// Type of artifact, one of OSS, PVC and Image.
+	Type string `gorm:"type:varchar(32);column:type" json:"type"`
+	// URI locates the artifact, e.g. oss://bucket/path, <pvc name>:/path or an
+	// image reference.
+	URI        string    `gorm:"type:varchar(1024);column:uri" json:"uri"`
+	GmtCreated time.Time `gorm:"type:datetime;column:gmt_created" json:"gmt_created"`
+}
+
+const (
+	ModelArtifactOSS   = "OSS"
+	ModelArtifactPVC   = "PVC"
+	ModelArtifactImage = "Image"
+)
+
+// ModelStageTransition records a model version moved from one stage to another.
+type ModelStageTransition struct {
+	ID        uint64 `gorm:"type:bigint(20) NOT NULL AUTO_INCREMENT;column:id;primaryKey" json:"id"`
+	ModelID   uint64 `gorm:"type:bigint(20) NOT NULL;column:model_id;index:idx_model_stage_transition_model_id" json:"model_id"`
+	FromStage string `gorm:"type:varchar(32);column:from_stage" json:"from_stage"`
+	ToStage   string `gorm:"type:varchar(32);column:to_stage" json:"to_stage"`
+	// Operator is the user who transitioned the stage.
+	Operator   string    `gorm:"type:varchar(128);column:operator" json:"operator"`
+	Comment    string    `gorm:"type:varchar(1024);column:comment" json:"comment"`
 	GmtCreated time.Time `gorm:"type:datetime;column:gmt_created" json:"gmt_created"`
 }
 
@@ -271,6 +380,14 @@ type Notebook struct {
 	GmtCreated       time.Time `gorm:"type:datetime;column:gmt_created" json:"gmt_created"`
 }
 
+func (artifact ModelArtifact) TableName() string {
+	return "model_artifact"
+}
+
+func (transition ModelStageTransition) TableName() string {
+	return "model_stage_transition"
+}
+
 func (notebook Notebook) TableName() string {
 	return "notebook"
 }
@@ -397,6 +514,23 @@ type SubmitJobInfo struct {
 	Deadline                string `json:"deadline"`
 	HistoryLimit            int    `json:"historyLimit"`
 	TTLSecondsAfterFinished int32  `json:"ttlSecondsAfterFinished"`
+
+	// OutputModel is registered as a new model version once the job succeeded.
+	OutputModel *OutputModel `json:"outputModel,omitempty"`
+}
+
+// OutputModel declares the model a training job outputs, it is annotated on
+// the job by AnnotationOutputModel.
+type OutputModel struct {
+	// Name of the model, the job registers a new version of it.
+	Name string `json:"name"`
+	// DataSource the job saves the model to, and Path of the model under it.
+	DataSource string `json:"dataSource"`
+	Path       string `json:"path"`
+	// MetricsPath is the metrics file written by the job under DataSource.
+	MetricsPath string `json:"metricsPath,omitempty"`
+	// CodeSource the job trains the model with.
+	CodeSource string `json:"codeSource,omitempty"`
 }
 
 type SubmitEvaluateJobInfo struct {
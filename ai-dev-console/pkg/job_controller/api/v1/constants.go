@@ -71,6 +71,10 @@ const (
 	// AnnotationSkipDAGScheduling skips dag scheduling scheme for special workloads.
 	AnnotationSkipDAGScheduling = KubeDLPrefix + "/skip-dag-scheduling"
 
+	// AnnotationOutputModel annotate the model a job outputs, which is
+	// registered as a new model version once the job succeeded.
+	AnnotationOutputModel = KubeDLPrefix + "/output-model"
+
 	// AnnotationTensorBoardConfig annotate tensorboard configurations.
 	AnnotationTensorBoardConfig = KubeDLPrefix + "/tensorboard-config"
 	// ReplicaTypeTensorBoard is the type for TensorBoard.
@@ -22,16 +22,60 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/audit"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/auth"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/runtimes"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/server"
 )
 
 var (
 	socketAddress = flag.String("socket-address", "/host/run/commit-agent/commit-agent.sock", "the socket address which was listened by commit-agent server")
+	dockerConfig  = flag.String("docker-config", "", "the docker config.json used for registries not covered by the referenced secrets, defaults to $DOCKER_CONFIG/config.json")
+	imageGCDays   = flag.Int("image-gc-days", 0, "remove committed images older than this many days once they have been pushed, 0 disables the garbage collection")
+	imageGCPeriod = flag.Duration("image-gc-interval", time.Hour, "how often committed images are garbage collected")
+
+	exportDir = flag.String("export-dir", "", "directory images are exported to as OCI image layout tarballs, e.g. a host path or a volume, empty disables exports")
+
+	metricsAddress      = flag.String("metrics-address", ":9474", "address /metrics and /healthz are served on, empty disables them")
+	healthCheckInterval = flag.Duration("health-check-interval", 30*time.Second, "how often the container runtime connectivity is checked")
+
+	runtimeConfig       = flag.String("runtime-config", "", "YAML file with the runtime settings below, the flags and the COMMIT_AGENT_* environment variables take precedence")
+	runtime             = flag.String("runtime", runtimes.Auto, "container runtime of the node: auto, docker, containerd or cri-o")
+	dockerSocket        = flag.String("docker-socket", "", "docker socket, defaults to /host/run/docker.sock")
+	containerdSocket    = flag.String("containerd-socket", "", "containerd socket, defaults to /host/run/containerd/containerd.sock")
+	containerdNamespace = flag.String("containerd-namespace", "", "containerd namespace of the kubernetes containers, defaults to k8s.io")
+	crioSocket          = flag.String("crio-socket", "", "cri-o socket, defaults to /host/run/crio/crio.sock")
+
+	authorize = flag.Bool("authorize", true, "identify the socket callers and only let notebook pods commit their own containers, needs hostPID")
+	allow     stringList
+	auditLog  = flag.String("audit-log", "", "file the denied calls, commits and pushes are written to as JSON lines, defaults to stdout")
+	procRoot  = flag.String("proc-root", "/proc", "proc filesystem the caller processes are looked up in")
 )
 
+func init() {
+	flag.Var(&allow, "allow", "allowed callers, uid:<uid> for node processes or pod:<namespace>/<name-pattern> for pods, may be repeated or comma separated, every pod is allowed when no pod is listed")
+}
+
+// stringList is a flag which may be repeated or given a comma separated list.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -39,10 +83,35 @@ func main() {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	p, err := server.New(*socketAddress)
+	config, err := loadRuntimeConfig()
+	if err != nil {
+		log.Fatalf("invalid runtime config, %v", err)
+	}
+	auditLogger, err := audit.New(*auditLog)
+	if err != nil {
+		log.Fatalf("failed to open audit log, %v", err)
+	}
+	authConfig := auth.Config{Enabled: *authorize, Allow: allow, ProcRoot: *procRoot}
+	if !authConfig.Enabled {
+		log.Warnf("authorization is disabled, every process with access to the socket may commit any container")
+	}
+	p, err := server.New(*socketAddress, *dockerConfig, config, authConfig, auditLogger)
 	if err != nil {
 		log.Fatalf("failed to init commit-agent, %v", err)
 	}
+	if *imageGCDays > 0 {
+		p.StartImageGC(time.Duration(*imageGCDays)*24*time.Hour, *imageGCPeriod)
+	}
+	if *exportDir != "" {
+		if err := p.EnableExport(*exportDir); err != nil {
+			log.Fatalf("invalid export directory, %v", err)
+		}
+	}
+	p.StartHealthCheck(*healthCheckInterval)
+	var httpErrChan chan error
+	if *metricsAddress != "" {
+		httpErrChan = p.StartHTTPServer(*metricsAddress)
+	}
 	svr, errChan := p.StartRPCServer()
 	defer svr.GracefulStop()
 
@@ -52,10 +121,36 @@ func main() {
 			log.Fatalf("captured %v, shutting down", sig)
 		case err := <-errChan:
 			log.Fatal(err)
+		case err := <-httpErrChan:
+			log.Fatalf("metrics server failed, %v", err)
 		}
 	}
 }
 
+// loadRuntimeConfig applies the runtime flags set on the command line on top
+// of the config file and the environment.
+func loadRuntimeConfig() (runtimes.Config, error) {
+	config, err := runtimes.LoadConfig(*runtimeConfig)
+	if err != nil {
+		return config, err
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "runtime":
+			config.Runtime = *runtime
+		case "docker-socket":
+			config.DockerSocket = *dockerSocket
+		case "containerd-socket":
+			config.ContainerdSocket = *containerdSocket
+		case "containerd-namespace":
+			config.ContainerdNamespace = *containerdNamespace
+		case "crio-socket":
+			config.CRIOSocket = *crioSocket
+		}
+	})
+	return config, config.Validate()
+}
+
 func mustValidateFlags(pathToUnixSocket string) {
 	// Using an actual socket file instead of in-memory Linux socket namespace object.
 	log.Infof("Checking socket path %s", pathToUnixSocket)
@@ -22,6 +22,7 @@ import (
 	"google.golang.org/grpc"
 	"net"
 
+	"github.com/docker/go-units"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/credentials/insecure"
 
@@ -31,6 +32,21 @@ import (
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
 )
 
+var (
+	commitDetach bool
+	commitPush   bool
+
+	commitAuthor  string
+	commitMessage string
+	commitLabels  map[string]string
+	commitChanges []string
+	commitPause   bool
+
+	commitExcludes []string
+	commitMaxSize  string
+	commitDryRun   bool
+)
+
 // commitCmd represents the commit command
 var commitCmd = &cobra.Command{
 	Use:   "commit NAME[:TAG]",
@@ -64,11 +80,46 @@ var commitCmd = &cobra.Command{
 
 		log.Infof(fmt.Sprintf("container id: %s", containerID))
 
-		// get version
+		var maxSize int64
+		if commitMaxSize != "" {
+			maxSize, err = units.FromHumanSize(commitMaxSize)
+			if err != nil {
+				return fmt.Errorf("invalid --max-size: %w", err)
+			}
+		}
+
+		options := &v1beta1.CommitOptions{
+			Author:   commitAuthor,
+			Message:  commitMessage,
+			Labels:   commitLabels,
+			Changes:  commitChanges,
+			Pause:    commitPause,
+			Excludes: commitExcludes,
+			MaxSize:  maxSize,
+			DryRun:   commitDryRun,
+		}
+
+		if commitPush {
+			client.CommitAndPush(c, &v1beta1.CommitAndPushRequest{
+				Image:       args[0],
+				ContainerID: containerID,
+				Username:    username,
+				Password:    password,
+				Auth: &v1beta1.RegistryAuth{
+					Secrets:             secrets,
+					PodImagePullSecrets: podPullSecrets,
+				},
+				Options: options,
+				Targets: targets,
+			}, commitDetach)
+			return nil
+		}
+
 		client.CommitImage(c, &v1beta1.CommitRequest{
 			Image:       args[0],
 			ContainerID: containerID,
-		})
+			Options:     options,
+		}, commitDetach)
 
 		return nil
 	},
@@ -76,4 +127,22 @@ var commitCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(commitCmd)
+
+	commitCmd.Flags().StringVarP(&commitAuthor, "author", "a", "", "author of the image")
+	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "", "commit message")
+	commitCmd.Flags().StringToStringVarP(&commitLabels, "label", "l", nil, "labels added to the image, e.g. --label team=nlp")
+	commitCmd.Flags().StringArrayVarP(&commitChanges, "change", "c", nil, "apply a Dockerfile instruction to the image, CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, USER and WORKDIR are supported")
+	commitCmd.Flags().BoolVarP(&commitPause, "pause", "p", false, "pause the notebook while it is committed")
+	commitCmd.Flags().StringArrayVarP(&commitExcludes, "exclude", "e", nil, "leave paths out of the image, .dockerignore syntax, e.g. --exclude /root/.cache --exclude '**/*.ckpt'")
+	commitCmd.Flags().StringVar(&commitMaxSize, "max-size", "", "reject the commit when the notebook changes are larger, e.g. 10GB")
+	commitCmd.Flags().BoolVar(&commitDryRun, "dry-run", false, "only report the size of the notebook changes")
+	commitCmd.Flags().BoolVar(&commitPush, "push", false, "push the image after the commit, the image is removed again if the push fails")
+	commitCmd.Flags().StringVar(&username, "username", "", "username, used with --push")
+	commitCmd.Flags().StringVar(&password, "password", "", "password, used with --push")
+	_ = commitCmd.Flags().MarkDeprecated("username", "use --secret or the imagePullSecrets of the notebook instead")
+	_ = commitCmd.Flags().MarkDeprecated("password", "use --secret or the imagePullSecrets of the notebook instead")
+	commitCmd.Flags().StringSliceVar(&secrets, "secret", nil, "dockerconfigjson secrets in the notebook namespace used with --push")
+	commitCmd.Flags().BoolVar(&podPullSecrets, "pod-pull-secrets", true, "use the imagePullSecrets of the notebook pod with --push")
+	commitCmd.Flags().StringArrayVarP(&targets, "target", "t", nil, "also push the image as this reference with --push, may be repeated")
+	commitCmd.Flags().BoolVarP(&commitDetach, "detach", "d", false, "return the operation id without waiting for the commit to finish")
 }
@@ -0,0 +1,115 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/client"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// imageCmd represents the image command
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage the images committed on the node",
+}
+
+var imageListCmd = &cobra.Command{
+	Use:     "ls",
+	Aliases: []string{"list"},
+	Short:   "List the images committed on the node",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withImageService(func(c v1beta1.ImageServiceClient) {
+			client.ListImages(c)
+		})
+	},
+}
+
+var imageInspectCmd = &cobra.Command{
+	Use:   "inspect IMAGE",
+	Short: "Show the layers, size and labels of an image",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withImageService(func(c v1beta1.ImageServiceClient) {
+			client.InspectImage(c, args[0])
+		})
+	},
+}
+
+var imageRemoveCmd = &cobra.Command{
+	Use:     "rm IMAGE [IMAGE...]",
+	Aliases: []string{"remove"},
+	Short:   "Remove images committed on the node",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withImageService(func(c v1beta1.ImageServiceClient) {
+			for _, image := range args {
+				client.RemoveImage(c, image)
+			}
+		})
+	},
+}
+
+var exportDetach bool
+
+var imageExportCmd = &cobra.Command{
+	Use:   "export IMAGE PATH",
+	Short: "Export an image as an OCI image layout tarball",
+	Long: `Export an image as an OCI image layout tarball to the export directory of the
+agent. PATH is relative to the export directory, the exports of notebooks are
+kept in a directory named after their namespace.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withImageService(func(c v1beta1.ImageServiceClient) {
+			client.ExportImage(c, &v1beta1.ExportImageRequest{Image: args[0], Path: args[1]}, exportDetach)
+		})
+	},
+}
+
+func withImageService(run func(c v1beta1.ImageServiceClient)) error {
+	var opts []grpc.DialOption
+	var dialer = func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", addr)
+	}
+	opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	opts = append(opts, grpc.WithContextDialer(dialer))
+
+	conn, err := grpc.Dial(serverSocket, opts...)
+	if err != nil {
+		log.Errorf("did not connect: %v", err)
+		return err
+	}
+	defer conn.Close()
+
+	run(v1beta1.NewImageServiceClient(conn))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+	imageCmd.AddCommand(imageListCmd, imageInspectCmd, imageRemoveCmd, imageExportCmd)
+
+	imageExportCmd.Flags().BoolVarP(&exportDetach, "detach", "d", false, "return the operation id without waiting for the export to finish")
+}
@@ -0,0 +1,39 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/client"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// infoCmd represents the info command
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the container runtime the agent detected on the node",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withImageService(func(c v1beta1.ImageServiceClient) {
+			client.GetInfo(c)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(infoCmd)
+}
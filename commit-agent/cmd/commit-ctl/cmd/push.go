@@ -24,13 +24,22 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/credentials/insecure"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/client"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/utils"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
 )
 
 var (
 	username string
 	password string
+
+	secrets        []string
+	podPullSecrets bool
+
+	pushDetach bool
+
+	targets []string
 )
 
 // pushCmd represents the push command
@@ -56,12 +65,26 @@ var pushCmd = &cobra.Command{
 
 		c := v1beta1.NewImageServiceClient(conn)
 
-		// get version
+		// the container id scopes the secret lookup to the namespace of the notebook
+		var containerID string
+		cgroupMessage, err := utils.ReadSystemdLine(pkg.CgroupPath)
+		if err != nil {
+			log.Warnf("get container information failed: %v", err)
+		} else {
+			containerID = utils.GetContainerID(cgroupMessage)
+		}
+
 		client.PushImage(c, &v1beta1.PushRequest{
-			Image:    args[0],
-			Username: username,
-			Password: password,
-		})
+			Image:       args[0],
+			Username:    username,
+			Password:    password,
+			ContainerID: containerID,
+			Targets:     targets,
+			Auth: &v1beta1.RegistryAuth{
+				Secrets:             secrets,
+				PodImagePullSecrets: podPullSecrets,
+			},
+		}, pushDetach)
 
 		return nil
 	},
@@ -72,4 +95,10 @@ func init() {
 
 	pushCmd.Flags().StringVar(&username, "username", "", "username")
 	pushCmd.Flags().StringVar(&password, "password", "", "password")
+	_ = pushCmd.Flags().MarkDeprecated("username", "use --secret or the imagePullSecrets of the notebook instead")
+	_ = pushCmd.Flags().MarkDeprecated("password", "use --secret or the imagePullSecrets of the notebook instead")
+	pushCmd.Flags().StringSliceVar(&secrets, "secret", nil, "dockerconfigjson secrets in the notebook namespace used to authenticate to the registry")
+	pushCmd.Flags().BoolVar(&podPullSecrets, "pod-pull-secrets", true, "use the imagePullSecrets of the notebook pod to authenticate to the registry")
+	pushCmd.Flags().StringArrayVarP(&targets, "target", "t", nil, "also push the image as this reference, e.g. to a backup registry, may be repeated")
+	pushCmd.Flags().BoolVarP(&pushDetach, "detach", "d", false, "return the operation id without waiting for the push to finish")
 }
@@ -0,0 +1,98 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+// Package audit writes one JSON line per security relevant event of the
+// agent: denied calls and every commit and push.
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Event is a line of the audit log.
+type Event struct {
+	Time time.Time `json:"time"`
+	// Action is the gRPC method or operation the event is about.
+	Action string `json:"action"`
+	// Decision is allowed or denied for authorization events.
+	Decision string `json:"decision,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+
+	CallerPID       int32  `json:"callerPID,omitempty"`
+	CallerUID       uint32 `json:"callerUID"`
+	CallerContainer string `json:"callerContainer,omitempty"`
+	CallerNamespace string `json:"callerNamespace,omitempty"`
+	CallerPod       string `json:"callerPod,omitempty"`
+
+	ContainerID string `json:"containerID,omitempty"`
+	Image       string `json:"image,omitempty"`
+
+	// OperationID, Result and Error describe finished commits and pushes,
+	// Result is success or failure.
+	OperationID string `json:"operationID,omitempty"`
+	Result      string `json:"result,omitempty"`
+	Error       string `json:"error,omitempty"`
+	// Duration of the operation in seconds.
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// Logger appends events to a file, or to stdout when no file is set.
+type Logger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// New opens the audit log file, an empty path logs to stdout.
+func New(path string) (*Logger, error) {
+	if path == "" {
+		return NewWriter(os.Stdout), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return NewWriter(f), nil
+}
+
+// NewWriter logs to w.
+func NewWriter(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+func (l *Logger) Log(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Errorf("failed to encode audit event: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(data, '\n')); err != nil {
+		log.Errorf("failed to write audit event: %v", err)
+	}
+}
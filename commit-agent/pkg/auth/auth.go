@@ -0,0 +1,417 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+// Package auth identifies the processes calling the agent socket and decides
+// what they may do. Notebook pods may only commit their own containers and
+// manage the images committed from their notebook.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/audit"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/kube"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/utils"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+const (
+	uidPrefix = "uid:"
+	podPrefix = "pod:"
+
+	// healthPrefix is the prefix of the gRPC health methods, they are open to
+	// every caller.
+	healthPrefix = "/grpc.health.v1.Health/"
+)
+
+type callerKey struct{}
+
+// CallerFromContext returns the caller of a call that passed the
+// interceptors, nil when it is not known.
+func CallerFromContext(ctx context.Context) *Caller {
+	caller, _ := ctx.Value(callerKey{}).(*Caller)
+	return caller
+}
+
+// Config of the authorization of the socket callers.
+type Config struct {
+	// Enabled turns the checks on, without them every caller may do everything.
+	Enabled bool
+	// Allow lists the callers of the socket. "uid:<uid>" allows processes of
+	// the node running outside containers, they may act on every container
+	// and image. "pod:<namespace>/<name>" allows notebook pods, the name may
+	// be a glob pattern. Every pod is allowed when no pod entry is given.
+	Allow []string
+	// ProcRoot is the proc filesystem of the host, the agent needs hostPID
+	// to see the processes of the callers.
+	ProcRoot string
+}
+
+// Validate checks the allow-list entries.
+func (c Config) Validate() error {
+	_, _, err := parseAllow(c.Allow)
+	return err
+}
+
+func parseAllow(allow []string) (map[uint32]bool, []string, error) {
+	uids := map[uint32]bool{}
+	var pods []string
+	for _, entry := range allow {
+		switch {
+		case strings.HasPrefix(entry, uidPrefix):
+			uid, err := strconv.ParseUint(strings.TrimPrefix(entry, uidPrefix), 10, 32)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid allow entry %q: %v", entry, err)
+			}
+			uids[uint32(uid)] = true
+		case strings.HasPrefix(entry, podPrefix):
+			pattern := strings.TrimPrefix(entry, podPrefix)
+			if strings.Count(pattern, "/") != 1 {
+				return nil, nil, fmt.Errorf("invalid allow entry %q: expected pod:<namespace>/<name>", entry)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, nil, fmt.Errorf("invalid allow entry %q: %v", entry, err)
+			}
+			pods = append(pods, pattern)
+		default:
+			return nil, nil, fmt.Errorf("invalid allow entry %q: expected uid:<uid> or pod:<namespace>/<name>", entry)
+		}
+	}
+	return uids, pods, nil
+}
+
+// Resolver looks up the containers and images the checks are about.
+type Resolver interface {
+	ContainerInfo(containerID string) (*_type.ContainerInfo, error)
+	NotebookLabels(containerID string) (map[string]string, error)
+	InspectImage(image string) (*_type.Image, error)
+}
+
+// Caller is the process on the other end of a connection.
+type Caller struct {
+	PID int32
+	UID uint32
+	// ContainerID is empty for processes outside containers.
+	ContainerID string
+	// Labels are the labels of the caller container.
+	Labels map[string]string
+	// Admin callers are allow-listed node processes, they are not limited to
+	// their own pod.
+	Admin bool
+}
+
+func (c *Caller) Namespace() string {
+	return c.Labels[kube.PodNamespaceLabel]
+}
+
+func (c *Caller) Pod() string {
+	return c.Labels[kube.PodNameLabel]
+}
+
+// AuditEvent returns an audit event of the caller, c may be nil.
+func (c *Caller) AuditEvent(action string) audit.Event {
+	event := audit.Event{Action: action}
+	if c != nil {
+		event.CallerPID = c.PID
+		event.CallerUID = c.UID
+		event.CallerContainer = c.ContainerID
+		event.CallerNamespace = c.Namespace()
+		event.CallerPod = c.Pod()
+	}
+	return event
+}
+
+// Authorizer checks the gRPC calls against the Config.
+type Authorizer struct {
+	config   Config
+	uids     map[uint32]bool
+	pods     []string
+	resolver Resolver
+	audit    *audit.Logger
+}
+
+func NewAuthorizer(config Config, resolver Resolver, auditLog *audit.Logger) (*Authorizer, error) {
+	uids, pods, err := parseAllow(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	if config.ProcRoot == "" {
+		config.ProcRoot = "/proc"
+	}
+	return &Authorizer{
+		config:   config,
+		uids:     uids,
+		pods:     pods,
+		resolver: resolver,
+		audit:    auditLog,
+	}, nil
+}
+
+// UnaryInterceptor authorizes the unary calls, image lists are reduced to
+// the images of the caller notebook.
+func (a *Authorizer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(ctx, req)
+		}
+		if !a.config.Enabled {
+			return handler(context.WithValue(ctx, callerKey{}, peerCaller(ctx)), req)
+		}
+		caller, err := a.authorize(ctx, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(context.WithValue(ctx, callerKey{}, caller), req)
+		if list, ok := resp.(*v1beta1.ListImagesResponse); ok && err == nil && !caller.Admin {
+			list.Images = a.ownImages(caller, list.Images)
+		}
+		return resp, err
+	}
+}
+
+// StreamInterceptor only checks that the caller is allowed, operation ids
+// are random and only known to whoever started the operation.
+func (a *Authorizer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !a.config.Enabled || strings.HasPrefix(info.FullMethod, healthPrefix) {
+			return handler(srv, ss)
+		}
+		if _, err := a.authorize(ss.Context(), info.FullMethod, nil); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func (a *Authorizer) authorize(ctx context.Context, method string, req interface{}) (*Caller, error) {
+	caller, err := a.identify(ctx)
+	event := caller.AuditEvent(method)
+	if err == nil {
+		err = a.check(caller, req, &event)
+	}
+	if err != nil {
+		event.Decision = "denied"
+		event.Reason = err.Error()
+		a.audit.Log(event)
+		log.Warnf("denied %s: %v", method, err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return caller, nil
+}
+
+// peerCaller returns the caller with the credentials of the connection only,
+// nil without credentials.
+func peerCaller(ctx context.Context) *Caller {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(PeerInfo)
+	if !ok {
+		return nil
+	}
+	return &Caller{PID: info.PID, UID: info.UID}
+}
+
+// identify resolves the caller from the SO_PEERCRED credentials of the
+// connection and the cgroup of its process.
+func (a *Authorizer) identify(ctx context.Context) (*Caller, error) {
+	caller := peerCaller(ctx)
+	if caller == nil {
+		return nil, errors.New("no peer credentials on the connection")
+	}
+	if caller.PID == 0 {
+		return caller, errors.New("the caller process is not visible to the agent, run the agent with hostPID")
+	}
+
+	cgroup, err := os.ReadFile(filepath.Join(a.config.ProcRoot, strconv.Itoa(int(caller.PID)), "cgroup"))
+	if err != nil {
+		return caller, fmt.Errorf("failed to read the cgroup of the caller, run the agent with hostPID: %v", err)
+	}
+	containerID, inContainer := utils.ContainerIDFromCgroup(string(cgroup))
+	if !inContainer {
+		if !a.uids[caller.UID] {
+			return caller, fmt.Errorf("uid %d is not allowed, node processes need an uid:%d allow entry", caller.UID, caller.UID)
+		}
+		caller.Admin = true
+		return caller, nil
+	}
+
+	caller.ContainerID = containerID
+	container, err := a.resolver.ContainerInfo(containerID)
+	if err != nil {
+		return caller, fmt.Errorf("failed to look up the caller container %s: %v", containerID, err)
+	}
+	caller.Labels = container.Labels
+	if caller.Namespace() == "" || caller.Pod() == "" {
+		return caller, fmt.Errorf("the caller container %s does not belong to a pod", containerID)
+	}
+	if !a.podAllowed(caller.Namespace(), caller.Pod()) {
+		return caller, fmt.Errorf("pod %s/%s is not allowed", caller.Namespace(), caller.Pod())
+	}
+	return caller, nil
+}
+
+func (a *Authorizer) podAllowed(namespace, name string) bool {
+	if len(a.pods) == 0 {
+		return true
+	}
+	for _, pattern := range a.pods {
+		if ok, _ := path.Match(pattern, namespace+"/"+name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// check limits notebook callers to the containers of their own pod and to
+// the images committed from their notebook.
+func (a *Authorizer) check(caller *Caller, req interface{}, event *audit.Event) error {
+	if caller.Admin {
+		return nil
+	}
+	switch r := req.(type) {
+	case *v1beta1.CommitRequest:
+		event.ContainerID, event.Image = r.ContainerID, r.Image
+		if err := a.ownContainer(caller, r.ContainerID); err != nil {
+			return err
+		}
+		return a.ownDestinations(caller, r.Image)
+	case *v1beta1.CommitAndPushRequest:
+		event.ContainerID, event.Image = r.ContainerID, r.Image
+		if err := a.ownContainer(caller, r.ContainerID); err != nil {
+			return err
+		}
+		return a.ownDestinations(caller, append([]string{r.Image}, r.Targets...)...)
+	case *v1beta1.PushRequest:
+		event.ContainerID, event.Image = r.ContainerID, r.Image
+		// The container selects the namespace the registry secrets are read
+		// from, without it the agent would push with the node credentials.
+		if r.ContainerID == "" {
+			return errors.New("notebook callers must name their container to push, it selects the registry secrets")
+		}
+		if err := a.ownContainer(caller, r.ContainerID); err != nil {
+			return err
+		}
+		if err := a.ownImage(caller, r.Image); err != nil {
+			return err
+		}
+		return a.ownDestinations(caller, r.Targets...)
+	case *v1beta1.InspectImageRequest:
+		event.Image = r.Image
+		return a.ownImage(caller, r.Image)
+	case *v1beta1.RemoveImageRequest:
+		event.Image = r.Image
+		return a.ownImage(caller, r.Image)
+	case *v1beta1.ExportImageRequest:
+		event.Image = r.Image
+		return a.ownImage(caller, r.Image)
+	}
+	return nil
+}
+
+func (a *Authorizer) ownContainer(caller *Caller, containerID string) error {
+	target, err := a.resolver.ContainerInfo(containerID)
+	if errors.Is(err, errdefs.ErrContainerNotFound) {
+		return fmt.Errorf("container %s is not in the pod of the caller", containerID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up container %s: %v", containerID, err)
+	}
+	if !samePod(caller.Labels, target.Labels) {
+		return fmt.Errorf("container %s is not in the pod of the caller", containerID)
+	}
+	return nil
+}
+
+func samePod(a, b map[string]string) bool {
+	if uid := a[kube.PodUIDLabel]; uid != "" && b[kube.PodUIDLabel] != "" {
+		return uid == b[kube.PodUIDLabel]
+	}
+	return a[kube.PodNamespaceLabel] != "" && a[kube.PodNameLabel] != "" &&
+		a[kube.PodNamespaceLabel] == b[kube.PodNamespaceLabel] && a[kube.PodNameLabel] == b[kube.PodNameLabel]
+}
+
+func (a *Authorizer) ownImage(caller *Caller, image string) error {
+	img, err := a.resolver.InspectImage(image)
+	if errors.Is(err, errdefs.ErrImageNotFound) {
+		// the call fails with not found anyway
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up image %s: %v", image, err)
+	}
+	notebook, err := a.resolver.NotebookLabels(caller.ContainerID)
+	if err != nil {
+		return fmt.Errorf("failed to look up the notebook of the caller: %v", err)
+	}
+	if !sameNotebook(notebook, img.Labels) {
+		return fmt.Errorf("image %s was not committed from the notebook of the caller", image)
+	}
+	return nil
+}
+
+// ownDestinations checks the names an image is committed or tagged as. The
+// runtimes replace an existing name, so a name already on the node, e.g. the
+// sandbox image or an image other pods run, must have been committed from the
+// notebook of the caller too.
+func (a *Authorizer) ownDestinations(caller *Caller, images ...string) error {
+	for _, image := range images {
+		if image == "" {
+			continue
+		}
+		if err := a.ownImage(caller, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sameNotebook(notebook, image map[string]string) bool {
+	return image[pkg.NotebookNamespaceLabel] != "" &&
+		image[pkg.NotebookNamespaceLabel] == notebook[pkg.NotebookNamespaceLabel] &&
+		image[pkg.NotebookNameLabel] == notebook[pkg.NotebookNameLabel]
+}
+
+func (a *Authorizer) ownImages(caller *Caller, images []*v1beta1.Image) []*v1beta1.Image {
+	notebook, err := a.resolver.NotebookLabels(caller.ContainerID)
+	if err != nil {
+		log.Warnf("failed to look up the notebook of container %s: %v", caller.ContainerID, err)
+		return nil
+	}
+	var result []*v1beta1.Image
+	for _, image := range images {
+		if sameNotebook(notebook, image.Labels) {
+			result = append(result, image)
+		}
+	}
+	return result
+}
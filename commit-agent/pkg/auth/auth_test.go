@@ -0,0 +1,272 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/peer"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/audit"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/kube"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+var (
+	notebookContainer = strings.Repeat("a", 64)
+	sidecarContainer  = strings.Repeat("b", 64)
+	otherContainer    = strings.Repeat("c", 64)
+)
+
+type fakeResolver struct{}
+
+func podLabels(namespace, name, uid string) map[string]string {
+	return map[string]string{kube.PodNamespaceLabel: namespace, kube.PodNameLabel: name, kube.PodUIDLabel: uid}
+}
+
+func (fakeResolver) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	switch containerID {
+	case notebookContainer, sidecarContainer:
+		return &_type.ContainerInfo{ID: containerID, Labels: podLabels("team-a", "nb-0", "uid-a")}, nil
+	case otherContainer:
+		return &_type.ContainerInfo{ID: containerID, Labels: podLabels("team-b", "nb-0", "uid-b")}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
+}
+
+func (fakeResolver) NotebookLabels(containerID string) (map[string]string, error) {
+	info, err := fakeResolver{}.ContainerInfo(containerID)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{pkg.NotebookNamespaceLabel: info.Labels[kube.PodNamespaceLabel], pkg.NotebookNameLabel: "nb"}, nil
+}
+
+func (fakeResolver) InspectImage(image string) (*_type.Image, error) {
+	switch image {
+	case "own:v1":
+		return &_type.Image{Name: image, Labels: map[string]string{pkg.NotebookNamespaceLabel: "team-a", pkg.NotebookNameLabel: "nb"}}, nil
+	case "other:v1":
+		return &_type.Image{Name: image, Labels: map[string]string{pkg.NotebookNamespaceLabel: "team-b", pkg.NotebookNameLabel: "nb"}}, nil
+	case "registry.k8s.io/pause:3.9":
+		return &_type.Image{Name: image}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+}
+
+func TestAuthorize(t *testing.T) {
+	procRoot := t.TempDir()
+	cgroups := map[int32]string{
+		100: "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-poduid_a.slice/cri-containerd-" + notebookContainer + ".scope\n",
+		200: "0::/user.slice/user-0.slice/session-1.scope\n",
+		300: "12:pids:/kubepods/burstable/poduid-b/" + otherContainer + "\n",
+	}
+	for pid, content := range cgroups {
+		dir := filepath.Join(procRoot, fmt.Sprint(pid))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "cgroup"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testCases := []struct {
+		testName string
+		allow    []string
+		pid      int32
+		uid      uint32
+		req      interface{}
+		denied   bool
+	}{
+		{
+			testName: "commit own container",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: notebookContainer},
+		},
+		{
+			testName: "commit container of the same pod",
+			pid:      100,
+			req:      &v1beta1.CommitAndPushRequest{ContainerID: sidecarContainer},
+		},
+		{
+			testName: "commit container of another pod",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: otherContainer},
+			denied:   true,
+		},
+		{
+			testName: "commit unknown container",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: "missing"},
+			denied:   true,
+		},
+		{
+			testName: "push own image",
+			pid:      100,
+			req:      &v1beta1.PushRequest{Image: "own:v1", ContainerID: notebookContainer},
+		},
+		{
+			testName: "commit as a new image",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: notebookContainer, Image: "new:v1"},
+		},
+		{
+			testName: "commit over an own image",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: notebookContainer, Image: "own:v1"},
+		},
+		{
+			testName: "commit over the sandbox image",
+			pid:      100,
+			req:      &v1beta1.CommitRequest{ContainerID: notebookContainer, Image: "registry.k8s.io/pause:3.9"},
+			denied:   true,
+		},
+		{
+			testName: "commit over an image of another notebook",
+			pid:      100,
+			req:      &v1beta1.CommitAndPushRequest{ContainerID: notebookContainer, Image: "other:v1"},
+			denied:   true,
+		},
+		{
+			testName: "commit and push to new targets",
+			pid:      100,
+			req:      &v1beta1.CommitAndPushRequest{ContainerID: notebookContainer, Image: "new:v1", Targets: []string{"new:latest", "own:v1"}},
+		},
+		{
+			testName: "commit and push to the sandbox image",
+			pid:      100,
+			req:      &v1beta1.CommitAndPushRequest{ContainerID: notebookContainer, Image: "new:v1", Targets: []string{"registry.k8s.io/pause:3.9"}},
+			denied:   true,
+		},
+		{
+			testName: "push without a container",
+			pid:      100,
+			req:      &v1beta1.PushRequest{Image: "own:v1"},
+			denied:   true,
+		},
+		{
+			testName: "push from a container of another pod",
+			pid:      100,
+			req:      &v1beta1.PushRequest{Image: "own:v1", ContainerID: otherContainer},
+			denied:   true,
+		},
+		{
+			testName: "push own image to a new target",
+			pid:      100,
+			req:      &v1beta1.PushRequest{Image: "own:v1", ContainerID: notebookContainer, Targets: []string{"backup:v1"}},
+		},
+		{
+			testName: "push own image over an image of another notebook",
+			pid:      100,
+			req:      &v1beta1.PushRequest{Image: "own:v1", ContainerID: notebookContainer, Targets: []string{"backup:v1", "other:v1"}},
+			denied:   true,
+		},
+		{
+			testName: "node process commits over the sandbox image",
+			allow:    []string{"uid:0"},
+			pid:      200,
+			req:      &v1beta1.CommitRequest{ContainerID: otherContainer, Image: "registry.k8s.io/pause:3.9"},
+		},
+		{
+			testName: "remove image of another notebook",
+			pid:      100,
+			req:      &v1beta1.RemoveImageRequest{Image: "other:v1"},
+			denied:   true,
+		},
+		{
+			testName: "pod not in the allow-list",
+			allow:    []string{"pod:team-b/*"},
+			pid:      100,
+			req:      &v1beta1.VersionRequest{},
+			denied:   true,
+		},
+		{
+			testName: "pod in the allow-list",
+			allow:    []string{"pod:team-b/*"},
+			pid:      300,
+			req:      &v1beta1.CommitRequest{ContainerID: otherContainer},
+		},
+		{
+			testName: "node process without allow entry",
+			pid:      200,
+			req:      &v1beta1.VersionRequest{},
+			denied:   true,
+		},
+		{
+			testName: "allowed node process",
+			allow:    []string{"uid:0"},
+			pid:      200,
+			req:      &v1beta1.CommitRequest{ContainerID: otherContainer},
+		},
+		{
+			testName: "caller pid not visible",
+			allow:    []string{"uid:0"},
+			pid:      0,
+			req:      &v1beta1.VersionRequest{},
+			denied:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			a, err := NewAuthorizer(Config{Enabled: true, Allow: tc.allow, ProcRoot: procRoot}, fakeResolver{}, audit.NewWriter(io.Discard))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: PeerInfo{PID: tc.pid, UID: tc.uid}})
+			_, err = a.authorize(ctx, "/v1beta1.ImageService/Test", tc.req)
+			if tc.denied && err == nil {
+				t.Errorf("expected the call to be denied")
+			}
+			if !tc.denied && err != nil {
+				t.Errorf("unexpected denial: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	testCases := []struct {
+		testName string
+		allow    []string
+		invalid  bool
+	}{
+		{testName: "empty"},
+		{testName: "uid and pods", allow: []string{"uid:0", "pod:kubeai/*", "pod:*/notebook-?"}},
+		{testName: "bad uid", allow: []string{"uid:root"}, invalid: true},
+		{testName: "pod without namespace", allow: []string{"pod:notebook"}, invalid: true},
+		{testName: "bad pattern", allow: []string{"pod:ns/[a"}, invalid: true},
+		{testName: "unknown kind", allow: []string{"user:admin"}, invalid: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := Config{Allow: tc.allow}.Validate()
+			if tc.invalid != (err != nil) {
+				t.Errorf("expected invalid %t, got %v", tc.invalid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/credentials"
+)
+
+// PeerCredentials is the transport security of the unix socket: it does no
+// handshake but records the SO_PEERCRED credentials of every connection.
+type PeerCredentials struct{}
+
+// PeerInfo carries the credentials of the process that opened the connection.
+type PeerInfo struct {
+	credentials.CommonAuthInfo
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+func (PeerInfo) AuthType() string {
+	return "peercred"
+}
+
+func (PeerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, nil, errors.New("peer credentials need a unix socket")
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil, nil, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return nil, nil, err
+	}
+	if credErr != nil {
+		return nil, nil, credErr
+	}
+	return conn, PeerInfo{
+		CommonAuthInfo: credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity},
+		PID:            cred.Pid,
+		UID:            cred.Uid,
+		GID:            cred.Gid,
+	}, nil
+}
+
+func (PeerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, PeerInfo{}, nil
+}
+
+func (PeerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c PeerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (PeerCredentials) OverrideServerName(string) error {
+	return nil
+}
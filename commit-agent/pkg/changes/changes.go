@@ -0,0 +1,249 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package changes
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Supported Dockerfile instructions, all of them only touch the image config.
+const (
+	cmdDirective        = "CMD"
+	entrypointDirective = "ENTRYPOINT"
+	envDirective        = "ENV"
+	exposeDirective     = "EXPOSE"
+	labelDirective      = "LABEL"
+	userDirective       = "USER"
+	workdirDirective    = "WORKDIR"
+)
+
+// Validate checks that every change is a supported and well formed instruction.
+func Validate(changes []string) error {
+	return Apply(&ocispec.ImageConfig{}, changes)
+}
+
+// Apply applies Dockerfile style instructions such as `ENV KEY=value` or
+// `CMD ["python", "app.py"]` to config in the order they are given, with the
+// same semantics as `docker commit --change`.
+func Apply(config *ocispec.ImageConfig, changes []string) error {
+	for _, change := range changes {
+		directive, args, _ := strings.Cut(strings.TrimSpace(change), " ")
+		args = strings.TrimSpace(args)
+		if args == "" {
+			return fmt.Errorf("change %q has no arguments", change)
+		}
+
+		var err error
+		switch strings.ToUpper(directive) {
+		case cmdDirective:
+			config.Cmd, err = parseCommand(args)
+		case entrypointDirective:
+			config.Entrypoint, err = parseCommand(args)
+		case envDirective:
+			err = applyEnv(config, args)
+		case exposeDirective:
+			err = applyExpose(config, args)
+		case labelDirective:
+			err = applyLabels(config, args)
+		case userDirective:
+			config.User = args
+		case workdirDirective:
+			if !path.IsAbs(args) {
+				args = path.Join("/", config.WorkingDir, args)
+			}
+			config.WorkingDir = path.Clean(args)
+		default:
+			return fmt.Errorf("unsupported change directive %q, supported are CMD, ENTRYPOINT, ENV, EXPOSE, LABEL, USER and WORKDIR", directive)
+		}
+		if err != nil {
+			return fmt.Errorf("malformed change %q: %w", change, err)
+		}
+	}
+	return nil
+}
+
+// parseCommand parses the exec form `["a", "b"]` or the shell form `a b`.
+func parseCommand(args string) ([]string, error) {
+	if strings.HasPrefix(args, "[") {
+		var command []string
+		if err := json.Unmarshal([]byte(args), &command); err != nil {
+			return nil, err
+		}
+		return command, nil
+	}
+	return []string{"/bin/sh", "-c", args}, nil
+}
+
+func applyEnv(config *ocispec.ImageConfig, args string) error {
+	pairs, err := parsePairs(args)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		prefix := pair[0] + "="
+		replaced := false
+		for i, env := range config.Env {
+			if strings.HasPrefix(env, prefix) {
+				config.Env[i] = prefix + pair[1]
+				replaced = true
+			}
+		}
+		if !replaced {
+			config.Env = append(config.Env, prefix+pair[1])
+		}
+	}
+	return nil
+}
+
+func applyLabels(config *ocispec.ImageConfig, args string) error {
+	pairs, err := parsePairs(args)
+	if err != nil {
+		return err
+	}
+	if config.Labels == nil {
+		config.Labels = map[string]string{}
+	}
+	for _, pair := range pairs {
+		config.Labels[pair[0]] = pair[1]
+	}
+	return nil
+}
+
+func applyExpose(config *ocispec.ImageConfig, args string) error {
+	for _, port := range strings.Fields(args) {
+		number, proto, found := strings.Cut(port, "/")
+		if !found {
+			proto = "tcp"
+		}
+		proto = strings.ToLower(proto)
+		if proto != "tcp" && proto != "udp" && proto != "sctp" {
+			return fmt.Errorf("invalid protocol %q", proto)
+		}
+		start, end, isRange := strings.Cut(number, "-")
+		if !isRange {
+			end = start
+		}
+		first, err := parsePort(start)
+		if err != nil {
+			return err
+		}
+		last, err := parsePort(end)
+		if err != nil {
+			return err
+		}
+		if last < first {
+			return fmt.Errorf("invalid port range %q", number)
+		}
+		if config.ExposedPorts == nil {
+			config.ExposedPorts = map[string]struct{}{}
+		}
+		for p := first; p <= last; p++ {
+			config.ExposedPorts[fmt.Sprintf("%d/%s", p, proto)] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func parsePort(port string) (int, error) {
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return 0, fmt.Errorf("invalid port %q", port)
+	}
+	return p, nil
+}
+
+// parsePairs parses `k1=v1 k2="v 2"` and the legacy single pair form `k v`.
+func parsePairs(args string) ([][2]string, error) {
+	words, err := splitWords(args)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(words[0], "=") {
+		key, value, _ := strings.Cut(args, " ")
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		return [][2]string{{key, value}}, nil
+	}
+
+	pairs := make([][2]string, 0, len(words))
+	for _, word := range words {
+		key, value, found := strings.Cut(word, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", word)
+		}
+		pairs = append(pairs, [2]string{key, value})
+	}
+	return pairs, nil
+}
+
+// splitWords splits on unquoted whitespace and removes the quotes.
+func splitWords(args string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord, escaped := false, false
+	for _, r := range args {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func unquote(value string) (string, error) {
+	words, err := splitWords(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(words, " "), nil
+}
@@ -0,0 +1,102 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package changes
+
+import (
+	"reflect"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestApply(t *testing.T) {
+	config := &ocispec.ImageConfig{
+		Env:        []string{"PATH=/usr/bin", "LANG=C"},
+		WorkingDir: "/home",
+	}
+	err := Apply(config, []string{
+		`CMD ["jupyter", "lab"]`,
+		`ENTRYPOINT tini --`,
+		`ENV LANG=C.UTF-8 PIP_INDEX_URL="https://mirrors.aliyun.com/pypi/simple"`,
+		`env HF_HOME /data/hf cache`,
+		`EXPOSE 8888 6006-6007/udp`,
+		`LABEL "team"=nlp stage='dev env'`,
+		`WORKDIR jovyan`,
+		`USER 1000`,
+	})
+	if err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	expected := &ocispec.ImageConfig{
+		Cmd:        []string{"jupyter", "lab"},
+		Entrypoint: []string{"/bin/sh", "-c", "tini --"},
+		Env:        []string{"PATH=/usr/bin", "LANG=C.UTF-8", "PIP_INDEX_URL=https://mirrors.aliyun.com/pypi/simple", "HF_HOME=/data/hf cache"},
+		ExposedPorts: map[string]struct{}{
+			"8888/tcp": {},
+			"6006/udp": {},
+			"6007/udp": {},
+		},
+		Labels:     map[string]string{"team": "nlp", "stage": "dev env"},
+		WorkingDir: "/home/jovyan",
+		User:       "1000",
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("expected %+v, got %+v", expected, config)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		testName string
+		changes  []string
+		valid    bool
+	}{
+		{
+			testName: "no changes",
+			changes:  nil,
+			valid:    true,
+		},
+		{
+			testName: "unsupported directive",
+			changes:  []string{"RUN pip install torch"},
+			valid:    false,
+		},
+		{
+			testName: "malformed exec form",
+			changes:  []string{`CMD ["python"`},
+			valid:    false,
+		},
+		{
+			testName: "invalid port",
+			changes:  []string{"EXPOSE 70000"},
+			valid:    false,
+		},
+		{
+			testName: "missing arguments",
+			changes:  []string{"WORKDIR"},
+			valid:    false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if err := Validate(c.changes); (err == nil) != c.valid {
+				t.Errorf("expected valid %v, got error %v", c.valid, err)
+			}
+		})
+	}
+}
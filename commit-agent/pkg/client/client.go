@@ -17,12 +17,20 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"time"
 
+	"github.com/docker/go-units"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
 )
 
+const progressInterval = 2 * time.Second
+
 func GetVersion(client v1beta1.ImageServiceClient, request *v1beta1.VersionRequest) {
 	response, err := client.Version(context.TODO(), request)
 	if err != nil {
@@ -31,20 +39,153 @@ func GetVersion(client v1beta1.ImageServiceClient, request *v1beta1.VersionReque
 	log.Println(response.Version)
 }
 
-func CommitImage(client v1beta1.ImageServiceClient, request *v1beta1.CommitRequest) {
+func GetInfo(client v1beta1.ImageServiceClient) {
+	response, err := client.Info(context.TODO(), &v1beta1.InfoRequest{})
+	if err != nil {
+		log.Fatalf("get info failed: %v", err)
+	}
+	fmt.Printf("Agent version:   %s\n", response.Version)
+	fmt.Printf("Runtime:         %s\n", response.Runtime)
+	fmt.Printf("Runtime version: %s\n", response.RuntimeVersion)
+	fmt.Printf("Endpoint:        %s\n", response.Endpoint)
+	if response.Namespace != "" {
+		fmt.Printf("Namespace:       %s\n", response.Namespace)
+	}
+	fmt.Printf("Commit support:  %t\n", response.Commit)
+}
+
+func CommitImage(client v1beta1.ImageServiceClient, request *v1beta1.CommitRequest, detach bool) {
 	response, err := client.CommitImage(context.TODO(), request)
 	if err != nil {
 		log.Fatalf("commit image failed: %v", err)
 	}
 	log.Println(response.Result)
+	if detach {
+		return
+	}
+	WatchOperation(client, response.OperationID, true)
 }
 
-func PushImage(client v1beta1.ImageServiceClient, request *v1beta1.PushRequest) {
+func PushImage(client v1beta1.ImageServiceClient, request *v1beta1.PushRequest, detach bool) {
 	log.Println("Start pushing the image: ", request.Image)
-	log.Println("Waiting...")
 	response, err := client.PushImage(context.TODO(), request)
 	if err != nil {
 		log.Fatalf("Image push failed: %v", err)
 	}
 	log.Println(response.Result)
+	if detach {
+		return
+	}
+	log.Println("Waiting...")
+	WatchOperation(client, response.OperationID, true)
+}
+
+func CommitAndPush(client v1beta1.ImageServiceClient, request *v1beta1.CommitAndPushRequest, detach bool) {
+	log.Println("Start committing and pushing the image: ", request.Image)
+	response, err := client.CommitAndPush(context.TODO(), request)
+	if err != nil {
+		log.Fatalf("commit and push failed: %v", err)
+	}
+	log.Println(response.Result)
+	if detach {
+		return
+	}
+	log.Println("Waiting...")
+	WatchOperation(client, response.OperationID, true)
+}
+
+func ExportImage(client v1beta1.ImageServiceClient, request *v1beta1.ExportImageRequest, detach bool) {
+	log.Printf("Start exporting the image %s to %s", request.Image, request.Path)
+	response, err := client.ExportImage(context.TODO(), request)
+	if err != nil {
+		log.Fatalf("image export failed: %v", err)
+	}
+	log.Println(response.Result)
+	if detach {
+		return
+	}
+	WatchOperation(client, response.OperationID, true)
+}
+
+// WatchOperation prints the state of an operation. With follow it keeps
+// printing progress until the operation finishes and exits non-zero on failure.
+func WatchOperation(client v1beta1.ImageServiceClient, operationID string, follow bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.WatchOperation(ctx, &v1beta1.WatchOperationRequest{OperationID: operationID})
+	if err != nil {
+		log.Fatalf("watch operation failed: %v", err)
+	}
+
+	var lastPhase v1beta1.Phase
+	var lastPrint time.Time
+	for {
+		op, err := stream.Recv()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Fatalf("watch operation failed: %v", err)
+		}
+		if !follow {
+			printOperation(op)
+			return
+		}
+		switch op.Phase {
+		case v1beta1.Phase_DONE:
+			log.Println(op.Result)
+			return
+		case v1beta1.Phase_FAILED:
+			if hint := errdefs.Hint(codes.Code(op.Code)); hint != "" {
+				log.Errorf("operation %s failed: %s", op.Id, hint)
+			}
+			log.Fatalf("operation %s failed: %s: %s", op.Id, op.Result, op.Error)
+		}
+		if op.Phase != lastPhase {
+			log.Printf("operation %s: %s", op.Id, op.Phase)
+			lastPhase = op.Phase
+		}
+		if len(op.Layers) > 0 && time.Since(lastPrint) > progressInterval {
+			current, total := layerBytes(op.Layers)
+			log.Printf("operation %s: %s, %s / %s", op.Id, op.Phase, units.HumanSize(float64(current)), units.HumanSize(float64(total)))
+			lastPrint = time.Now()
+		}
+	}
+}
+
+func printOperation(op *v1beta1.Operation) {
+	log.Printf("operation: %s", op.Id)
+	log.Printf("type:      %s", op.Type)
+	log.Printf("image:     %s", op.Image)
+	log.Printf("phase:     %s", op.Phase)
+	log.Printf("started:   %s", time.Unix(op.StartTime, 0).Format(time.RFC3339))
+	log.Printf("updated:   %s", time.Unix(op.UpdateTime, 0).Format(time.RFC3339))
+	if op.DiffSize > 0 {
+		log.Printf("changes:   %s", units.HumanSize(float64(op.DiffSize)))
+	}
+	for _, layer := range op.Layers {
+		state := "pushing"
+		if layer.Done {
+			state = "done"
+		}
+		log.Printf("  %s: %s / %s (%s)", layer.Id, units.HumanSize(float64(layer.Current)), units.HumanSize(float64(layer.Total)), state)
+	}
+	if op.Result != "" {
+		log.Printf("result:    %s", op.Result)
+	}
+	if op.Error != "" {
+		log.Printf("error:     %s", op.Error)
+		if hint := errdefs.Hint(codes.Code(op.Code)); hint != "" {
+			log.Printf("hint:      %s", hint)
+		}
+	}
+}
+
+func layerBytes(layers []*v1beta1.LayerProgress) (current, total int64) {
+	for _, layer := range layers {
+		current += layer.Current
+		total += layer.Total
+	}
+	return current, total
 }
@@ -0,0 +1,102 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+func ListImages(client v1beta1.ImageServiceClient) {
+	response, err := client.ListImages(context.TODO(), &v1beta1.ListImagesRequest{})
+	if err != nil {
+		log.Fatalf("list images failed: %v", err)
+	}
+	sort.Slice(response.Images, func(i, j int) bool {
+		return response.Images[i].Created > response.Images[j].Created
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tID\tNOTEBOOK\tCREATED\tSIZE\tPUSHED")
+	for _, image := range response.Images {
+		notebook := image.Labels[pkg.NotebookNameLabel]
+		if ns := image.Labels[pkg.NotebookNamespaceLabel]; ns != "" {
+			notebook = ns + "/" + notebook
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\t%t\n", image.Name, shortID(image.Id), notebook,
+			units.HumanDuration(time.Since(time.Unix(image.Created, 0))), units.HumanSize(float64(image.Size)), image.Pushed)
+	}
+	w.Flush()
+}
+
+func InspectImage(client v1beta1.ImageServiceClient, image string) {
+	response, err := client.InspectImage(context.TODO(), &v1beta1.InspectImageRequest{Image: image})
+	if err != nil {
+		log.Fatalf("inspect image failed: %v", err)
+	}
+	img := response.Image
+	fmt.Printf("Name:     %s\n", img.Name)
+	fmt.Printf("ID:       %s\n", img.Id)
+	fmt.Printf("Created:  %s\n", time.Unix(img.Created, 0).Format(time.RFC3339))
+	fmt.Printf("Size:     %s\n", units.HumanSize(float64(img.Size)))
+	fmt.Printf("Pushed:   %t\n", img.Pushed)
+	fmt.Println("Labels:")
+	keys := make([]string, 0, len(img.Labels))
+	for k := range img.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s=%s\n", k, img.Labels[k])
+	}
+	fmt.Println("Layers:")
+	for _, layer := range img.Layers {
+		if layer.Size > 0 {
+			fmt.Printf("  %s %s\n", layer.Digest, units.HumanSize(float64(layer.Size)))
+		} else {
+			fmt.Printf("  %s\n", layer.Digest)
+		}
+	}
+}
+
+func RemoveImage(client v1beta1.ImageServiceClient, image string) {
+	response, err := client.RemoveImage(context.TODO(), &v1beta1.RemoveImageRequest{Image: image})
+	if err != nil {
+		log.Fatalf("remove image failed: %v", err)
+	}
+	log.Println(response.Result)
+}
+
+func shortID(id string) string {
+	if _, hex, ok := strings.Cut(id, ":"); ok {
+		id = hex
+	}
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
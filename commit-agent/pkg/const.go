@@ -20,3 +20,19 @@ const (
 	BufferSize    = 1024 * 10
 	CgroupPath    = "/proc/self/cgroup"
 )
+
+// Labels set on committed images to trace them back to the notebook.
+const (
+	NotebookNameLabel      = "kubeai.alibabacloud.com/notebook-name"
+	NotebookNamespaceLabel = "kubeai.alibabacloud.com/notebook-namespace"
+	NotebookOwnerLabel     = "kubeai.alibabacloud.com/notebook-owner"
+	SourceImageLabel       = "kubeai.alibabacloud.com/source-image"
+
+	// PushedAtLabel records on containerd images when they were pushed,
+	// docker keeps the repo digests of pushed images instead.
+	PushedAtLabel = "kubeai.alibabacloud.com/pushed-at"
+
+	// Labels the console and the notebook controller set on notebook pods.
+	NotebookNamePodLabel  = "notebook-name"
+	NotebookOwnerPodLabel = "User"
+)
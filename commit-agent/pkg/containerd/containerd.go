@@ -19,12 +19,19 @@ import (
 	"context"
 	"fmt"
 	"github.com/containerd/containerd"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	cerrdefs "github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/images/archive"
 	"github.com/containerd/containerd/images/converter"
 	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/containerd/platforms"
 	"github.com/containerd/containerd/reference"
 	refdocker "github.com/containerd/containerd/reference/docker"
 	"github.com/containerd/containerd/remotes"
@@ -40,84 +47,388 @@ import (
 	"github.com/containerd/nerdctl/pkg/platformutil"
 	"github.com/containerd/nerdctl/pkg/referenceutil"
 	"github.com/containerd/nerdctl/pkg/signutil"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/changes"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/layer"
 	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
 )
 
 type Client struct {
-	Ctx    context.Context
-	Client *containerd.Client
+	Ctx      context.Context
+	Client   *containerd.Client
+	Endpoint string
+	// Namespace holds the kubernetes containers and images, k8s.io for the CRI plugin.
+	Namespace string
 }
 
-func NewContainerdClient() (_type.ContainerClient, error) {
+// progressTracker forwards the upload status of every pushed blob to the
+// operation progress while keeping the locking behaviour of the wrapped tracker.
+type progressTracker struct {
+	docker.StatusTrackLocker
+	progress _type.Progress
+}
 
-	cli, err := containerd.New("/host/run/containerd/containerd.sock")
+func (t *progressTracker) SetStatus(ref string, status docker.Status) {
+	t.StatusTrackLocker.SetStatus(ref, status)
+	if status.Committed {
+		t.progress.CompleteLayer(ref)
+		return
+	}
+	t.progress.UpdateLayer(ref, status.Offset, status.Total)
+}
+
+func NewContainerdClient(socket, namespace string) (_type.ContainerClient, error) {
+	cli, err := containerd.New(socket, containerd.WithDefaultNamespace(namespace))
 	if err != nil {
 		log.Errorln(err.Error())
 		return nil, err
 	}
 	return &Client{
-		Ctx:    context.Background(),
-		Client: cli,
+		Ctx:       context.Background(),
+		Client:    cli,
+		Endpoint:  socket,
+		Namespace: namespace,
+	}, nil
+}
+
+func (c *Client) Info() (*_type.RuntimeInfo, error) {
+	version, err := c.Client.Version(namespaces.WithNamespace(c.Ctx, c.Namespace))
+	if err != nil {
+		return nil, err
+	}
+	return &_type.RuntimeInfo{
+		Name:      "containerd",
+		Version:   version.Version,
+		Endpoint:  c.Endpoint,
+		Namespace: c.Namespace,
+		Commit:    true,
 	}, nil
 }
 
-func (c *Client) CommitImageFromSelf(containerID string, image string) error {
+func (c *Client) CommitImageFromSelf(containerID string, image string, options _type.CommitOptions) error {
 
 	named, err := referenceutil.ParseDockerRef(image)
 	if err != nil {
 		return err
 	}
 
+	// CMD and ENTRYPOINT are the only changes nerdctl applies itself, the
+	// config is rewritten after the commit for all of them instead.
 	opts := &commit.Opts{
-		Pause:   false,
+		Pause:   options.Pause,
 		Ref:     named.String(),
-		Author:  "",
-		Message: "",
+		Author:  options.Author,
+		Message: options.Message,
 		Changes: commit.Changes{},
 	}
 
+	var matcher *layer.Matcher
+	if len(options.Excludes) > 0 {
+		matcher, err = layer.NewMatcher(options.Excludes)
+		if err != nil {
+			return err
+		}
+	}
+
+	var snapshotter string
 	walker := &containerwalker.ContainerWalker{
 		Client: c.Client,
 		OnFound: func(ctx context.Context, found containerwalker.Found) error {
 			if found.MatchCount > 1 {
-				return fmt.Errorf("ambiguous ID %q", found.Req)
+				return fmt.Errorf("%w %q", errdefs.ErrAmbiguousContainerID, found.Req)
 			}
-			_, err := commit.Commit(ctx, c.Client, found.Container, opts)
+			info, err := found.Container.Info(ctx)
 			if err != nil {
 				return err
 			}
+			snapshotter = info.Snapshotter
+			_, err = commit.Commit(ctx, c.Client, found.Container, opts)
 			return err
 		},
 	}
 
-	ctx := namespaces.WithNamespace(c.Ctx, "k8s.io")
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
 
 	n, err := walker.Walk(ctx, containerID)
 	if err != nil {
 		return err
 	} else if n == 0 {
-		return fmt.Errorf("no such container %s", containerID)
+		return fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
 	}
 
+	if len(options.Changes) == 0 && len(options.Labels) == 0 && matcher == nil {
+		return nil
+	}
+	err = rewriteImage(ctx, c.Client, named.String(), func(ctx context.Context, manifest *ocispec.Manifest, config *ocispec.Image) error {
+		if matcher != nil {
+			if err := excludePaths(ctx, c.Client.ContentStore(), manifest, config, matcher, options.MaxSize); err != nil {
+				return err
+			}
+		}
+		if err := changes.Apply(&config.Config, options.Changes); err != nil {
+			return err
+		}
+		if len(options.Labels) > 0 && config.Config.Labels == nil {
+			config.Config.Labels = map[string]string{}
+		}
+		for k, v := range options.Labels {
+			config.Config.Labels[k] = v
+		}
+		return nil
+	})
+	if err == nil && matcher != nil {
+		// the filtered layer has no snapshot yet
+		var img containerd.Image
+		if img, err = c.Client.GetImage(ctx, named.String()); err == nil {
+			err = img.Unpack(ctx, snapshotter)
+		}
+	}
+	if err != nil {
+		// do not leave an image without the requested changes behind
+		if rmErr := c.Client.ImageService().Delete(ctx, named.String(), images.SynchronousDelete()); rmErr != nil {
+			log.Warnf("failed to remove image %s: %v", named.String(), rmErr)
+		}
+		return err
+	}
 	return nil
 }
 
-func (c *Client) PushImageFromSelf(rawRef, username, password string) error {
+func (c *Client) PushImageFromSelf(rawRef string, credentials _type.Credentials, progress _type.Progress) error {
 	ctx := context.TODO()
-	ctx = namespaces.WithNamespace(ctx, "k8s.io")
+	ctx = namespaces.WithNamespace(ctx, c.Namespace)
 
-	err := Push(ctx, c.Client, rawRef, username, password, types.ImagePushOptions{
+	err := Push(ctx, c.Client, rawRef, credentials, progress, types.ImagePushOptions{
 		Stdout: os.Stdout,
 		GOptions: types.GlobalCommandOptions{
 			Debug: true,
 		},
 	})
+	if err != nil {
+		return errdefs.RegistryError(err)
+	}
+	if err := c.markPushed(ctx, rawRef); err != nil {
+		log.Warnf("failed to label image %s as pushed: %v", rawRef, err)
+	}
+	return nil
+}
+
+// markPushed records the push on the image so the garbage collection knows
+// it can be pulled again.
+func (c *Client) markPushed(ctx context.Context, rawRef string) error {
+	named, err := referenceutil.ParseDockerRef(rawRef)
+	if err != nil {
+		return err
+	}
+	img, err := c.Client.ImageService().Get(ctx, named.String())
+	if err != nil {
+		return err
+	}
+	if img.Labels == nil {
+		img.Labels = map[string]string{}
+	}
+	img.Labels[pkg.PushedAtLabel] = time.Now().UTC().Format(time.RFC3339)
+	_, err = c.Client.ImageService().Update(ctx, img, "labels."+pkg.PushedAtLabel)
+	return err
+}
+
+func (c *Client) TagImage(source, target string) error {
+	src, err := referenceutil.ParseDockerRef(source)
+	if err != nil {
+		return err
+	}
+	dst, err := referenceutil.ParseDockerRef(target)
+	if err != nil {
+		return err
+	}
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+	img, err := c.Client.ImageService().Get(ctx, src.String())
+	switch {
+	case cerrdefs.IsNotFound(err):
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, source)
+	case err != nil:
+		return err
+	}
+	img.Name = dst.String()
+	if _, err = c.Client.ImageService().Create(ctx, img); cerrdefs.IsAlreadyExists(err) {
+		_, err = c.Client.ImageService().Update(ctx, img)
+	}
+	return err
+}
+
+// ExportImage writes the image with the content for the platform of the node,
+// the base layers must still be in the content store.
+func (c *Client) ExportImage(image string, w io.Writer) error {
+	named, err := referenceutil.ParseDockerRef(image)
+	if err != nil {
+		return err
+	}
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+	if _, err := c.Client.ImageService().Get(ctx, named.String()); cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+	return c.Client.Export(ctx, w,
+		archive.WithImage(c.Client.ImageService(), named.String()),
+		archive.WithPlatform(platforms.DefaultStrict()),
+		archive.WithSkipDockerManifest(),
+	)
+}
+
+func (c *Client) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	var info *_type.ContainerInfo
+	walker := &containerwalker.ContainerWalker{
+		Client: c.Client,
+		OnFound: func(ctx context.Context, found containerwalker.Found) error {
+			if found.MatchCount > 1 {
+				return fmt.Errorf("%w %q", errdefs.ErrAmbiguousContainerID, found.Req)
+			}
+			container, err := found.Container.Info(ctx)
+			if err != nil {
+				return err
+			}
+			info = &_type.ContainerInfo{
+				ID:     container.ID,
+				Image:  container.Image,
+				Labels: container.Labels,
+			}
+			return nil
+		},
+	}
+
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+
+	n, err := walker.Walk(ctx, containerID)
+	if err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
+	}
+	return info, nil
+}
+
+func (c *Client) DiffSize(containerID string) (int64, error) {
+	var size int64
+	walker := &containerwalker.ContainerWalker{
+		Client: c.Client,
+		OnFound: func(ctx context.Context, found containerwalker.Found) error {
+			if found.MatchCount > 1 {
+				return fmt.Errorf("%w %q", errdefs.ErrAmbiguousContainerID, found.Req)
+			}
+			container, err := found.Container.Info(ctx)
+			if err != nil {
+				return err
+			}
+			usage, err := c.Client.SnapshotService(container.Snapshotter).Usage(ctx, container.SnapshotKey)
+			if err != nil {
+				return err
+			}
+			size = usage.Size
+			return nil
+		},
+	}
+
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+
+	n, err := walker.Walk(ctx, containerID)
+	if err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
+	}
+	return size, nil
+}
+
+func (c *Client) ListImages() ([]_type.Image, error) {
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+	imgs, err := c.Client.ImageService().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var result []_type.Image
+	for _, img := range imgs {
+		// the CRI keeps id and digest references next to the image name
+		if strings.HasPrefix(img.Name, "sha256:") || strings.Contains(img.Name, "@") {
+			continue
+		}
+		image, err := c.image(ctx, img, false)
+		if err != nil {
+			log.Warnf("skipping image %s: %v", img.Name, err)
+			continue
+		}
+		if _, ok := image.Labels[pkg.SourceImageLabel]; ok {
+			result = append(result, *image)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) InspectImage(image string) (*_type.Image, error) {
+	named, err := referenceutil.ParseDockerRef(image)
+	if err != nil {
+		return nil, err
+	}
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+	img, err := c.Client.ImageService().Get(ctx, named.String())
+	switch {
+	case cerrdefs.IsNotFound(err):
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	case err != nil:
+		return nil, err
+	}
+	return c.image(ctx, img, true)
+}
+
+// image reads the config and the size of the image for the default platform.
+func (c *Client) image(ctx context.Context, img images.Image, withLayers bool) (*_type.Image, error) {
+	cs := c.Client.ContentStore()
+	platform := platforms.Default()
+	manifest, err := images.Manifest(ctx, cs, img.Target, platform)
+	if err != nil {
+		return nil, err
+	}
+	config := ocispec.Image{}
+	if err := readJSON(ctx, cs, manifest.Config, &config); err != nil {
+		return nil, err
+	}
+	size, err := img.Size(ctx, cs, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &_type.Image{
+		Name:    img.Name,
+		ID:      manifest.Config.Digest.String(),
+		Created: img.CreatedAt,
+		Size:    size,
+		Pushed:  img.Labels[pkg.PushedAtLabel] != "",
+		Labels:  config.Config.Labels,
+	}
+	if withLayers {
+		for _, l := range manifest.Layers {
+			result.Layers = append(result.Layers, _type.ImageLayer{Digest: l.Digest.String(), Size: l.Size})
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) RemoveImage(image string) error {
+	named, err := referenceutil.ParseDockerRef(image)
+	if err != nil {
+		return err
+	}
+	ctx := namespaces.WithNamespace(c.Ctx, c.Namespace)
+	err = c.Client.ImageService().Delete(ctx, named.String(), images.SynchronousDelete())
+	if cerrdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
 	return err
 }
 
-func Push(ctx context.Context, client *containerd.Client, rawRef, username, password string, options types.ImagePushOptions) error {
+func Push(ctx context.Context, client *containerd.Client, rawRef string, credentials _type.Credentials, progress _type.Progress, options types.ImagePushOptions) error {
 	if scheme, ref, err := referenceutil.ParseIPFSRefWithScheme(rawRef); err == nil {
 		if scheme != "ipfs" {
 			return fmt.Errorf("ipfs scheme is only supported but got %q", scheme)
@@ -161,6 +472,7 @@ func Push(ctx context.Context, client *containerd.Client, rawRef, username, pass
 	pushRef := ref
 	if !options.AllPlatforms {
 		pushRef = ref + "-tmp-reduced-platform"
+		progress.SetPhase(v1beta1.Phase_CONVERTING)
 		platImg, err := converter.Convert(ctx, client, pushRef, ref, converter.WithPlatform(platMC))
 		if err != nil {
 			if len(options.Platforms) == 0 {
@@ -172,7 +484,10 @@ func Push(ctx context.Context, client *containerd.Client, rawRef, username, pass
 		log.Infof("pushing as a reduced-platform image (%s, %s)", platImg.Target.MediaType, platImg.Target.Digest)
 	}
 
-	pushTracker := docker.NewInMemoryTracker()
+	pushTracker := &progressTracker{
+		StatusTrackLocker: docker.NewInMemoryTracker(),
+		progress:          progress,
+	}
 
 	pushFunc := func(r remotes.Resolver) error {
 		return push.Push(ctx, client, r, pushTracker, options.Stdout, pushRef, ref, platMC, options.AllowNondistributableArtifacts, options.Quiet)
@@ -186,10 +501,15 @@ func Push(ctx context.Context, client *containerd.Client, rawRef, username, pass
 	dOpts = append(dOpts, dockerconfigresolver.WithHostsDirs(options.GOptions.HostsDir))
 
 	authCreds := func(acArg string) (string, string, error) {
-		if acArg == refDomain {
-			return username, password, nil
+		ac, err := credentials(acArg)
+		if err != nil {
+			return "", "", err
 		}
-		return "", "", fmt.Errorf("expected acArg to be %q, got %q", refDomain, acArg)
+		// an empty username makes the authorizer use the secret as refresh token
+		if ac.IdentityToken != "" {
+			return "", ac.IdentityToken, nil
+		}
+		return ac.Username, ac.Password, nil
 	}
 
 	dOpts = append(dOpts, dockerconfigresolver.WithAuthCreds(authCreds))
@@ -200,10 +520,11 @@ func Push(ctx context.Context, client *containerd.Client, rawRef, username, pass
 
 	resolverOpts := docker.ResolverOptions{
 		Tracker: pushTracker,
-		Hosts:   dockerconfig.ConfigureHosts(ctx, *ho),
+		Hosts:   withRegistryToken(dockerconfig.ConfigureHosts(ctx, *ho), credentials),
 	}
 
 	resolver := docker.NewResolver(resolverOpts)
+	progress.SetPhase(v1beta1.Phase_PUSHING)
 	if err = pushFunc(resolver); err != nil {
 		if !errutil.IsErrHTTPResponseToHTTPSClient(err) && !errutil.IsErrConnectionRefused(err) {
 			return err
@@ -241,3 +562,27 @@ func Push(ctx context.Context, client *containerd.Client, rawRef, username, pass
 	}
 	return nil
 }
+
+// withRegistryToken sends the bearer token of registries whose credentials
+// carry a registry token, the authorizer only handles basic and refresh tokens.
+func withRegistryToken(hosts docker.RegistryHosts, credentials _type.Credentials) docker.RegistryHosts {
+	return func(host string) ([]docker.RegistryHost, error) {
+		registryHosts, err := hosts(host)
+		if err != nil {
+			return nil, err
+		}
+		for i := range registryHosts {
+			ac, err := credentials(registryHosts[i].Host)
+			if err != nil || ac.RegistryToken == "" {
+				continue
+			}
+			header := http.Header{}
+			if registryHosts[i].Header != nil {
+				header = registryHosts[i].Header.Clone()
+			}
+			header.Set("Authorization", "Bearer "+ac.RegistryToken)
+			registryHosts[i].Header = header
+		}
+		return registryHosts, nil
+	}
+}
@@ -0,0 +1,201 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package containerd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/archive/compression"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/labels"
+	"github.com/containerd/containerd/leases"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/identity"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/layer"
+)
+
+const (
+	gcRefContentPrefix  = "containerd.io/gc.ref.content."
+	gcRefSnapshotPrefix = "containerd.io/gc.ref.snapshot."
+)
+
+// rewriteImage lets update modify the manifest and config of a committed
+// single-platform image and points the image at the rewritten manifest.
+// Content written by update must use the ctx it is given, which holds the
+// lease protecting it from garbage collection.
+func rewriteImage(ctx context.Context, client *containerd.Client, ref string, update func(ctx context.Context, manifest *ocispec.Manifest, config *ocispec.Image) error) error {
+	ctx, done, err := client.WithLease(ctx, leases.WithRandomID(), leases.WithExpiration(1*time.Hour))
+	if err != nil {
+		return fmt.Errorf("failed to create lease for rewriting image: %w", err)
+	}
+	defer done(ctx)
+
+	cs := client.ContentStore()
+	img, err := client.ImageService().Get(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if !images.IsManifestType(img.Target.MediaType) {
+		return fmt.Errorf("image %s is not a single-platform image: %s", ref, img.Target.MediaType)
+	}
+
+	manifest := ocispec.Manifest{}
+	if err := readJSON(ctx, cs, img.Target, &manifest); err != nil {
+		return err
+	}
+	config := ocispec.Image{}
+	if err := readJSON(ctx, cs, manifest.Config, &config); err != nil {
+		return err
+	}
+	configInfo, err := cs.Info(ctx, manifest.Config.Digest)
+	if err != nil {
+		return err
+	}
+
+	chainID := identity.ChainID(config.RootFS.DiffIDs).String()
+	if err := update(ctx, &manifest, &config); err != nil {
+		return err
+	}
+	// the snapshot of a replaced layer does not belong to the new config
+	if identity.ChainID(config.RootFS.DiffIDs).String() != chainID {
+		for k := range configInfo.Labels {
+			if strings.HasPrefix(k, gcRefSnapshotPrefix) {
+				delete(configInfo.Labels, k)
+			}
+		}
+	}
+
+	configDesc, err := writeJSON(ctx, cs, manifest.Config.MediaType, config, configInfo.Labels)
+	if err != nil {
+		return err
+	}
+	manifest.Config = configDesc
+
+	// the manifest keeps its config and layers from being garbage collected
+	gcLabels := map[string]string{
+		gcRefContentPrefix + "0": configDesc.Digest.String(),
+	}
+	for i, l := range manifest.Layers {
+		gcLabels[fmt.Sprintf("%s%d", gcRefContentPrefix, i+1)] = l.Digest.String()
+	}
+	manifestDesc, err := writeJSON(ctx, cs, img.Target.MediaType, manifest, gcLabels)
+	if err != nil {
+		return err
+	}
+
+	img.Target = manifestDesc
+	_, err = client.ImageService().Update(ctx, img, "target")
+	return err
+}
+
+// excludePaths replaces the top layer, the one holding the container changes,
+// with a copy that leaves out the paths excluded by m.
+func excludePaths(ctx context.Context, cs content.Store, manifest *ocispec.Manifest, config *ocispec.Image, m *layer.Matcher, maxSize int64) error {
+	n := len(manifest.Layers)
+	if n == 0 || len(config.RootFS.DiffIDs) != n {
+		return fmt.Errorf("image has %d layers and %d diff ids, cannot exclude paths", n, len(config.RootFS.DiffIDs))
+	}
+	desc := manifest.Layers[n-1]
+
+	ra, err := cs.ReaderAt(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer ra.Close()
+	r, err := compression.DecompressStream(content.NewReader(ra))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := content.OpenWriter(ctx, cs, content.WithRef(fmt.Sprintf("exclude-%s-%d", desc.Digest, time.Now().UnixNano())))
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	compressed := &countingWriter{w: w}
+	gz := gzip.NewWriter(compressed)
+	diffID := digest.SHA256.Digester()
+	if _, err := layer.Filter(io.MultiWriter(gz, diffID.Hash()), r, m, maxSize); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	uncompressed := map[string]string{labels.LabelUncompressed: diffID.Digest().String()}
+	if err := w.Commit(ctx, compressed.n, "", content.WithLabels(uncompressed)); err != nil {
+		return err
+	}
+
+	mediaType := desc.MediaType
+	if !strings.HasSuffix(mediaType, "gzip") {
+		mediaType = ocispec.MediaTypeImageLayerGzip
+	}
+	manifest.Layers[n-1] = ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    w.Digest(),
+		Size:      compressed.n,
+	}
+	config.RootFS.DiffIDs[n-1] = diffID.Digest()
+	return nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func readJSON(ctx context.Context, cs content.Store, desc ocispec.Descriptor, into interface{}) error {
+	data, err := content.ReadBlob(ctx, cs, desc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, into)
+}
+
+func writeJSON(ctx context.Context, cs content.Store, mediaType string, v interface{}, labels map[string]string) (ocispec.Descriptor, error) {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	if err := content.WriteBlob(ctx, cs, desc.Digest.String(), bytes.NewReader(data), desc, content.WithLabels(labels)); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
@@ -0,0 +1,198 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/cli/cli/config/configfile"
+	clicredentials "github.com/docker/cli/cli/config/credentials"
+	clitypes "github.com/docker/cli/cli/config/types"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/kube"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+)
+
+// Options selects where the credentials of a push are looked up.
+type Options struct {
+	// ContainerID is the notebook container, secrets are only read from the
+	// namespace of its pod.
+	ContainerID string
+	// Secrets are dockerconfigjson secrets in the pod namespace.
+	Secrets []string
+	// PodImagePullSecrets adds the imagePullSecrets of the pod.
+	PodImagePullSecrets bool
+
+	// Deprecated: Username and Password are sent in clear text, use Secrets.
+	Username string
+	Password string
+}
+
+// Resolver resolves registry credentials from Kubernetes secrets and the
+// docker config file of the agent.
+type Resolver struct {
+	kube         *kube.Client
+	dockerConfig string
+}
+
+// NewResolver creates a Resolver. kubeClient may be nil when the agent runs
+// outside of a cluster. dockerConfig is the path of a docker config.json,
+// $DOCKER_CONFIG/config.json or ~/.docker/config.json is used when it is empty.
+func NewResolver(kubeClient *kube.Client, dockerConfig string) *Resolver {
+	if dockerConfig == "" {
+		dir := os.Getenv("DOCKER_CONFIG")
+		if dir == "" {
+			home, _ := os.UserHomeDir()
+			dir = filepath.Join(home, ".docker")
+		}
+		dockerConfig = filepath.Join(dir, "config.json")
+	}
+
+	return &Resolver{
+		kube:         kubeClient,
+		dockerConfig: dockerConfig,
+	}
+}
+
+// Credentials loads every docker config referenced by opts. The returned
+// lookup tries them in order: the deprecated username and password, the
+// listed secrets, the pod imagePullSecrets and finally the agent docker config.
+func (r *Resolver) Credentials(ctx context.Context, client _type.ContainerClient, opts Options) (_type.Credentials, error) {
+	var configs []*configfile.ConfigFile
+
+	if len(opts.Secrets) > 0 || opts.PodImagePullSecrets {
+		secretConfigs, err := r.secretConfigs(ctx, client, opts)
+		switch {
+		case err == nil:
+			configs = append(configs, secretConfigs...)
+		case len(opts.Secrets) == 0:
+			// the pod imagePullSecrets are only a best effort default
+			log.Warnf("skip the imagePullSecrets of the pod: %v", err)
+		default:
+			return nil, err
+		}
+	}
+
+	if content, err := os.ReadFile(r.dockerConfig); err == nil {
+		config, err := load(r.dockerConfig, content)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return func(host string) (_type.AuthConfig, error) {
+		if opts.Username != "" || opts.Password != "" {
+			log.Warnf("using the deprecated username and password of the request for %s", host)
+			return _type.AuthConfig{Username: opts.Username, Password: opts.Password}, nil
+		}
+		for _, config := range configs {
+			if ac, ok := Lookup(config, host); ok {
+				log.Infof("using credentials from %s for %s", config.Filename, host)
+				return ac, nil
+			}
+		}
+		log.Infof("no credentials found for %s, pushing anonymously", host)
+		return _type.AuthConfig{}, nil
+	}, nil
+}
+
+func (r *Resolver) secretConfigs(ctx context.Context, client _type.ContainerClient, opts Options) ([]*configfile.ConfigFile, error) {
+	if r.kube == nil {
+		return nil, fmt.Errorf("registry secrets requested but the agent has no access to the kubernetes api: %w", kube.ErrNotInCluster)
+	}
+	if opts.ContainerID == "" {
+		return nil, fmt.Errorf("registry secrets requested without a container id")
+	}
+
+	info, err := client.ContainerInfo(opts.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	namespace, podName := info.Labels[kube.PodNamespaceLabel], info.Labels[kube.PodNameLabel]
+	if namespace == "" || podName == "" {
+		return nil, fmt.Errorf("container %s does not belong to a kubernetes pod", opts.ContainerID)
+	}
+
+	names := opts.Secrets
+	if opts.PodImagePullSecrets {
+		pod, err := r.kube.GetPod(ctx, namespace, podName)
+		if err != nil {
+			return nil, err
+		}
+		names = append(append([]string{}, names...), pod.ImagePullSecrets...)
+	}
+
+	configs := make([]*configfile.ConfigFile, 0, len(names))
+	for _, name := range names {
+		content, err := r.kube.DockerConfig(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		config, err := load(fmt.Sprintf("secret %s/%s", namespace, name), content)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+func load(name string, content []byte) (*configfile.ConfigFile, error) {
+	config := configfile.New(name)
+	if err := config.LoadFromReader(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("failed to parse docker config %s: %w", name, err)
+	}
+	return config, nil
+}
+
+// Lookup finds the auths entry of config that matches the registry host.
+// Entries may be written as a hostname or as a URL such as
+// https://index.docker.io/v1/.
+func Lookup(config *configfile.ConfigFile, host string) (_type.AuthConfig, bool) {
+	host = normalizeHost(host)
+	for key, ac := range config.GetAuthConfigs() {
+		if normalizeHost(clicredentials.ConvertToHostname(key)) == host {
+			return toAuthConfig(ac), true
+		}
+	}
+	return _type.AuthConfig{}, false
+}
+
+// normalizeHost maps the Docker Hub aliases to one name.
+func normalizeHost(host string) string {
+	switch host {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+	return host
+}
+
+func toAuthConfig(ac clitypes.AuthConfig) _type.AuthConfig {
+	return _type.AuthConfig{
+		Username:      ac.Username,
+		Password:      ac.Password,
+		IdentityToken: ac.IdentityToken,
+		RegistryToken: ac.RegistryToken,
+	}
+}
@@ -0,0 +1,88 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package credentials
+
+import (
+	"testing"
+
+	"github.com/docker/cli/cli/config/configfile"
+)
+
+func TestLookup(t *testing.T) {
+	config, err := load("test", []byte(`{"auths": {
+		"https://index.docker.io/v1/": {"auth": "aHViOnNlY3JldA=="},
+		"registry.cn-hangzhou.aliyuncs.com": {"username": "acr", "password": "pass"},
+		"https://registry.example.com:5000/v2/": {"identitytoken": "refresh-token"}
+	}}`))
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+
+	testCases := []struct {
+		testName string
+		config   *configfile.ConfigFile
+		host     string
+		found    bool
+		username string
+		secret   string
+	}{
+		{
+			testName: "docker hub alias",
+			config:   config,
+			host:     "registry-1.docker.io",
+			found:    true,
+			username: "hub",
+			secret:   "secret",
+		},
+		{
+			testName: "plain hostname",
+			config:   config,
+			host:     "registry.cn-hangzhou.aliyuncs.com",
+			found:    true,
+			username: "acr",
+			secret:   "pass",
+		},
+		{
+			testName: "url with port and identity token",
+			config:   config,
+			host:     "registry.example.com:5000",
+			found:    true,
+			secret:   "refresh-token",
+		},
+		{
+			testName: "unknown registry",
+			config:   config,
+			host:     "registry.cn-beijing.aliyuncs.com",
+			found:    false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			ac, found := Lookup(c.config, c.host)
+			if found != c.found {
+				t.Fatalf("expected found %v, got %v", c.found, found)
+			}
+			secret := ac.Password
+			if ac.IdentityToken != "" {
+				secret = ac.IdentityToken
+			}
+			if ac.Username != c.username || secret != c.secret {
+				t.Errorf("unexpected credentials %+v", ac)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+// Package crio talks to CRI-O through the CRI image and runtime services.
+// CRI-O has no API to commit or push containers, only the image management
+// and the container metadata are supported.
+package crio
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/docker/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/crio/criapi"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+)
+
+type Client struct {
+	Ctx      context.Context
+	Runtime  criapi.RuntimeServiceClient
+	Images   criapi.ImageServiceClient
+	Endpoint string
+}
+
+// imageInfo is the verbose image status of CRI-O.
+type imageInfo struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	ImageSpec *ocispec.Image    `json:"imageSpec"`
+}
+
+func NewCRIOClient(socket string) (_type.ContainerClient, error) {
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", addr)
+	}
+	conn, err := grpc.Dial(socket, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithContextDialer(dialer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to cri-o at %s: %w", socket, err)
+	}
+	return &Client{
+		Ctx:      context.Background(),
+		Runtime:  criapi.NewRuntimeServiceClient(conn),
+		Images:   criapi.NewImageServiceClient(conn),
+		Endpoint: socket,
+	}, nil
+}
+
+func (c *Client) Info() (*_type.RuntimeInfo, error) {
+	version, err := c.Runtime.Version(c.Ctx, &criapi.VersionRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return &_type.RuntimeInfo{
+		Name:     "cri-o",
+		Version:  version.RuntimeVersion,
+		Endpoint: c.Endpoint,
+	}, nil
+}
+
+func (c *Client) CommitImageFromSelf(containerID, image string, opts _type.CommitOptions) error {
+	return fmt.Errorf("%w: cri-o cannot commit containers", errdefs.ErrNotImplemented)
+}
+
+func (c *Client) PushImageFromSelf(image string, credentials _type.Credentials, progress _type.Progress) error {
+	return fmt.Errorf("%w: cri-o cannot push images", errdefs.ErrNotImplemented)
+}
+
+func (c *Client) TagImage(source, target string) error {
+	return fmt.Errorf("%w: cri-o cannot tag images", errdefs.ErrNotImplemented)
+}
+
+func (c *Client) ExportImage(image string, w io.Writer) error {
+	return fmt.Errorf("%w: cri-o cannot export images", errdefs.ErrNotImplemented)
+}
+
+func (c *Client) DiffSize(containerID string) (int64, error) {
+	return 0, fmt.Errorf("%w: cri-o does not report the size of container changes", errdefs.ErrNotImplemented)
+}
+
+func (c *Client) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	response, err := c.Runtime.ContainerStatus(c.Ctx, &criapi.ContainerStatusRequest{ContainerId: containerID})
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
+	case err != nil:
+		return nil, err
+	}
+	container := response.GetStatus()
+	image := container.GetImage().GetImage()
+	if image == "" {
+		image = container.GetImageRef()
+	}
+	return &_type.ContainerInfo{
+		ID:     container.GetId(),
+		Image:  image,
+		Labels: container.GetLabels(),
+	}, nil
+}
+
+func (c *Client) ListImages() ([]_type.Image, error) {
+	response, err := c.Images.ListImages(c.Ctx, &criapi.ListImagesRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var result []_type.Image
+	for _, img := range response.Images {
+		names := img.RepoTags
+		if len(names) == 0 {
+			names = []string{img.Id}
+		}
+		image, err := c.imageStatus(img.Id)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := image.Labels[pkg.SourceImageLabel]; !ok {
+			continue
+		}
+		for _, name := range names {
+			named := *image
+			named.Name = name
+			named.Layers = nil
+			named.Pushed = pushed(name, img.RepoDigests)
+			result = append(result, named)
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) InspectImage(image string) (*_type.Image, error) {
+	result, err := c.imageStatus(image)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = image
+	return result, nil
+}
+
+func (c *Client) imageStatus(image string) (*_type.Image, error) {
+	response, err := c.Images.ImageStatus(c.Ctx, &criapi.ImageStatusRequest{
+		Image:   &criapi.ImageSpec{Image: image},
+		Verbose: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.Image == nil {
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+
+	result := &_type.Image{
+		Name:   image,
+		ID:     response.Image.Id,
+		Size:   int64(response.Image.Size),
+		Pushed: pushed(image, response.Image.RepoDigests),
+	}
+	info := imageInfo{}
+	if raw, ok := response.Info["info"]; ok {
+		if err := json.Unmarshal([]byte(raw), &info); err != nil {
+			return nil, fmt.Errorf("malformed image info of %s: %w", image, err)
+		}
+	}
+	result.Labels = info.Labels
+	if spec := info.ImageSpec; spec != nil {
+		if spec.Created != nil {
+			result.Created = *spec.Created
+		}
+		if result.Labels == nil {
+			result.Labels = spec.Config.Labels
+		}
+		// cri-o does not report layer sizes
+		for _, diffID := range spec.RootFS.DiffIDs {
+			result.Layers = append(result.Layers, _type.ImageLayer{Digest: diffID.String()})
+		}
+	}
+	if result.Created.IsZero() {
+		result.Created = time.Unix(0, 0)
+	}
+	return result, nil
+}
+
+func (c *Client) RemoveImage(image string) error {
+	_, err := c.Images.RemoveImage(c.Ctx, &criapi.RemoveImageRequest{Image: &criapi.ImageSpec{Image: image}})
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+	return err
+}
+
+// pushed tells whether a digest of the repository of the image is known,
+// which is the case once it was pushed or pulled.
+func pushed(image string, repoDigests []string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	for _, repoDigest := range repoDigests {
+		if repo, _, _ := strings.Cut(repoDigest, "@"); repo == named.Name() {
+			return true
+		}
+	}
+	return false
+}
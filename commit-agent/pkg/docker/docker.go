@@ -20,21 +20,31 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
+	"time"
 
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
 	registrytypes "github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/layer"
 	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
 )
 
 type Client struct {
-	Ctx    context.Context
-	Client client.CommonAPIClient
+	Ctx      context.Context
+	Client   client.CommonAPIClient
+	Endpoint string
 }
 
 type ErrorLine struct {
@@ -46,38 +56,76 @@ type ErrorDetail struct {
 	Message string `json:"message"`
 }
 
-func NewDockerClient() (_type.ContainerClient, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+// NewDockerClient creates a client of the docker daemon listening on the
+// unix socket.
+func NewDockerClient(socket string) (_type.ContainerClient, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithHost("unix://"+socket), client.WithAPIVersionNegotiation())
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to create docker client for %s: %w", socket, err)
 	}
-	defer cli.Close()
 
 	return &Client{
-		Ctx:    context.Background(),
-		Client: cli,
+		Ctx:      context.Background(),
+		Client:   cli,
+		Endpoint: socket,
 	}, nil
 }
 
-func (c *Client) CommitImageFromSelf(containerID string, image string) error {
+func (c *Client) Info() (*_type.RuntimeInfo, error) {
+	version, err := c.Client.ServerVersion(c.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &_type.RuntimeInfo{
+		Name:     "docker",
+		Version:  version.Version,
+		Endpoint: c.Endpoint,
+		Commit:   true,
+	}, nil
+}
+
+func (c *Client) CommitImageFromSelf(containerID string, image string, opts _type.CommitOptions) error {
+	var matcher *layer.Matcher
+	if len(opts.Excludes) > 0 {
+		var err error
+		if matcher, err = layer.NewMatcher(opts.Excludes); err != nil {
+			return err
+		}
+	}
 
 	commitOps := types.ContainerCommitOptions{
 		Reference: image,
-		Comment:   "",
-		Author:    "",
-		Changes:   []string{},
-		Pause:     false,
-		Config:    &container.Config{},
+		Comment:   opts.Message,
+		Author:    opts.Author,
+		Changes:   opts.Changes,
+		Pause:     opts.Pause,
+		Config:    &container.Config{Labels: opts.Labels},
 	}
 
-	_, err := c.Client.ContainerCommit(c.Ctx, containerID, commitOps)
-	if err != nil {
+	committed, err := c.Client.ContainerCommit(c.Ctx, containerID, commitOps)
+	switch {
+	case err == nil:
+		if matcher == nil {
+			return nil
+		}
+		if err := c.excludePaths(c.Ctx, image, matcher, opts.MaxSize); err != nil {
+			// do not leave an image without the requested changes behind
+			if _, rmErr := c.Client.ImageRemove(c.Ctx, committed.ID, types.ImageRemoveOptions{Force: true, PruneChildren: true}); rmErr != nil {
+				log.Warnf("failed to remove image %s: %v", image, rmErr)
+			}
+			return err
+		}
+		return nil
+	case client.IsErrNotFound(err):
+		return fmt.Errorf("%w: %v", errdefs.ErrContainerNotFound, err)
+	case strings.Contains(err.Error(), "multiple IDs found"):
+		return fmt.Errorf("%w: %v", errdefs.ErrAmbiguousContainerID, err)
+	default:
 		return err
 	}
-	return nil
 }
 
-func (c *Client) PushImageFromSelf(imageName, username, password string) error {
+func (c *Client) PushImageFromSelf(imageName string, credentials _type.Credentials, progress _type.Progress) error {
 	ref, err := reference.ParseNormalizedNamed(imageName)
 	switch {
 	case err != nil:
@@ -89,9 +137,15 @@ func (c *Client) PushImageFromSelf(imageName, username, password string) error {
 		}
 	}
 
+	ac, err := credentials(reference.Domain(ref))
+	if err != nil {
+		return err
+	}
 	authConfig := registrytypes.AuthConfig{
-		Username:      username,
-		Password:      password,
+		Username:      ac.Username,
+		Password:      ac.Password,
+		IdentityToken: ac.IdentityToken,
+		RegistryToken: ac.RegistryToken,
 		ServerAddress: reference.Domain(ref),
 	}
 
@@ -105,26 +159,140 @@ func (c *Client) PushImageFromSelf(imageName, username, password string) error {
 		All:          false,
 	}
 
+	progress.SetPhase(v1beta1.Phase_PUSHING)
 	response, err := c.Client.ImagePush(c.Ctx, reference.FamiliarString(ref), pushOps)
 	if err != nil {
 		log.Infof("push image failed: %v", err)
 		return err
 	}
 
-	if err := checkResponse(response); err != nil {
-		return err
+	if err := checkResponse(response, progress); err != nil {
+		return errdefs.RegistryError(err)
 	}
 
 	return nil
 }
 
-func checkResponse(rd io.Reader) error {
+func (c *Client) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	info, err := c.Client.ContainerInspect(c.Ctx, containerID)
+	switch {
+	case client.IsErrNotFound(err):
+		return nil, fmt.Errorf("%w: %v", errdefs.ErrContainerNotFound, err)
+	case err != nil:
+		return nil, err
+	}
+	result := &_type.ContainerInfo{
+		ID:    info.ID,
+		Image: info.Image,
+	}
+	if info.Config != nil {
+		// Config.Image is the reference the container was created from, Image is its ID
+		result.Image = info.Config.Image
+		result.Labels = info.Config.Labels
+	}
+	return result, nil
+}
+
+func (c *Client) DiffSize(containerID string) (int64, error) {
+	info, _, err := c.Client.ContainerInspectWithRaw(c.Ctx, containerID, true)
+	switch {
+	case client.IsErrNotFound(err):
+		return 0, fmt.Errorf("%w: %v", errdefs.ErrContainerNotFound, err)
+	case err != nil:
+		return 0, err
+	case info.SizeRw == nil:
+		return 0, fmt.Errorf("docker did not report the size of container %s", containerID)
+	}
+	return *info.SizeRw, nil
+}
+
+func (c *Client) ListImages() ([]_type.Image, error) {
+	summaries, err := c.Client.ImageList(c.Ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", pkg.SourceImageLabel)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []_type.Image
+	for _, summary := range summaries {
+		names := summary.RepoTags
+		if len(names) == 0 {
+			names = []string{summary.ID}
+		}
+		for _, name := range names {
+			result = append(result, _type.Image{
+				Name:    name,
+				ID:      summary.ID,
+				Created: time.Unix(summary.Created, 0),
+				Size:    summary.Size,
+				Pushed:  pushed(name, summary.RepoDigests),
+				Labels:  summary.Labels,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (c *Client) InspectImage(image string) (*_type.Image, error) {
+	inspect, _, err := c.Client.ImageInspectWithRaw(c.Ctx, image)
+	switch {
+	case client.IsErrNotFound(err):
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	case err != nil:
+		return nil, err
+	}
+	created, err := time.Parse(time.RFC3339Nano, inspect.Created)
+	if err != nil {
+		return nil, err
+	}
+	result := &_type.Image{
+		Name:    image,
+		ID:      inspect.ID,
+		Created: created,
+		Size:    inspect.Size,
+		Pushed:  pushed(image, inspect.RepoDigests),
+	}
+	if inspect.Config != nil {
+		result.Labels = inspect.Config.Labels
+	}
+	// docker only reports the size of the whole image
+	for _, diffID := range inspect.RootFS.Layers {
+		result.Layers = append(result.Layers, _type.ImageLayer{Digest: diffID})
+	}
+	return result, nil
+}
+
+// pushed tells whether docker recorded a repo digest, which it does after a
+// push, for the repository of the image.
+func pushed(image string, repoDigests []string) bool {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return false
+	}
+	for _, repoDigest := range repoDigests {
+		if repo, _, _ := strings.Cut(repoDigest, "@"); repo == reference.FamiliarName(named) || repo == named.Name() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) RemoveImage(image string) error {
+	_, err := c.Client.ImageRemove(c.Ctx, image, types.ImageRemoveOptions{PruneChildren: true})
+	if client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+	return err
+}
+
+func checkResponse(rd io.Reader, progress _type.Progress) error {
 	var lastLine string
 
 	scanner := bufio.NewScanner(rd)
 	for scanner.Scan() {
 		lastLine = scanner.Text()
 		log.Println(scanner.Text())
+		reportProgress(lastLine, progress)
 	}
 
 	errLine := &ErrorLine{}
@@ -141,3 +309,17 @@ func checkResponse(rd io.Reader) error {
 	}
 	return nil
 }
+
+// reportProgress forwards the per-layer progress of a docker push message.
+func reportProgress(line string, progress _type.Progress) {
+	msg := &jsonmessage.JSONMessage{}
+	if err := json.Unmarshal([]byte(line), msg); err != nil || msg.ID == "" {
+		return
+	}
+	switch {
+	case msg.Status == "Pushed" || msg.Status == "Layer already exists":
+		progress.CompleteLayer(msg.ID)
+	case msg.Progress != nil:
+		progress.UpdateLayer(msg.ID, msg.Progress.Current, msg.Progress.Total)
+	}
+}
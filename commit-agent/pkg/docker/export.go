@@ -0,0 +1,271 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package docker
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/client"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+)
+
+// containerdImageNameAnnotation carries the full image name in the index, as
+// `ctr import` and `nerdctl load` expect it.
+const containerdImageNameAnnotation = "io.containerd.image.name"
+
+func (c *Client) TagImage(source, target string) error {
+	err := c.Client.ImageTag(c.Ctx, source, target)
+	if client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, source)
+	}
+	return err
+}
+
+// ExportImage converts the `docker save` archive of the image to an OCI image
+// layout. Daemons from docker 25 on save an OCI layout already, it is written
+// as is.
+func (c *Client) ExportImage(image string, w io.Writer) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+	named = reference.TagNameOnly(named)
+	if _, _, err := c.Client.ImageInspectWithRaw(c.Ctx, named.String()); client.IsErrNotFound(err) {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+
+	saved, err := c.Client.ImageSave(c.Ctx, []string{named.String()})
+	if err != nil {
+		return err
+	}
+	defer saved.Close()
+
+	// the layers are hashed before they are written, which needs two passes
+	spool, err := os.CreateTemp("", "commit-agent-export-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+	if _, err := io.Copy(spool, saved); err != nil {
+		return err
+	}
+	return toOCILayout(spool, w, named)
+}
+
+// savedEntry is a file of a `docker save` archive.
+type savedEntry struct {
+	digest digest.Digest
+	size   int64
+	// link is the target of layers shared by several layer directories
+	link string
+}
+
+// toOCILayout writes the legacy `docker save` archive as an OCI image layout
+// holding one manifest with uncompressed layers.
+func toOCILayout(archive io.ReadSeeker, w io.Writer, named reference.Named) error {
+	entries := map[string]*savedEntry{}
+	jsonFiles := map[string][]byte{}
+	isLayout := false
+
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == ocispec.ImageLayoutFile:
+			isLayout = true
+		case hdr.Typeflag == tar.TypeSymlink:
+			entries[name] = &savedEntry{link: path.Join(path.Dir(name), hdr.Linkname)}
+		case hdr.Typeflag != tar.TypeReg:
+		case !strings.Contains(name, "/") && strings.HasSuffix(name, ".json"):
+			if jsonFiles[name], err = io.ReadAll(tr); err != nil {
+				return err
+			}
+		case path.Base(name) == "layer.tar":
+			digester := digest.SHA256.Digester()
+			size, err := io.Copy(digester.Hash(), tr)
+			if err != nil {
+				return err
+			}
+			entries[name] = &savedEntry{digest: digester.Digest(), size: size}
+		}
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if isLayout {
+		_, err := io.Copy(w, archive)
+		return err
+	}
+
+	var saved []archiveManifest
+	if err := json.Unmarshal(jsonFiles["manifest.json"], &saved); err != nil {
+		return fmt.Errorf("malformed manifest.json in the saved image: %w", err)
+	}
+	if len(saved) != 1 {
+		return fmt.Errorf("expected one image in the saved archive, got %d", len(saved))
+	}
+	config, ok := jsonFiles[saved[0].Config]
+	if !ok {
+		return fmt.Errorf("image config %s not found in the saved image", saved[0].Config)
+	}
+
+	manifest := ocispec.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageConfig,
+			Digest:    digest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+	}
+	// layer paths by digest, several paths may share one
+	blobs := map[digest.Digest]string{}
+	for _, name := range saved[0].Layers {
+		source, entry, err := resolve(entries, path.Clean(name))
+		if err != nil {
+			return err
+		}
+		blobs[entry.digest] = source
+		manifest.Layers = append(manifest.Layers, ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageLayer,
+			Digest:    entry.digest,
+			Size:      entry.size,
+		})
+	}
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{containerdImageNameAnnotation: named.String()}
+	if tagged, ok := named.(reference.Tagged); ok {
+		annotations[ocispec.AnnotationRefName] = tagged.Tag()
+	}
+	index := ocispec.Index{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: ocispec.MediaTypeImageIndex,
+		Manifests: []ocispec.Descriptor{{
+			MediaType:   ocispec.MediaTypeImageManifest,
+			Digest:      digest.FromBytes(manifestData),
+			Size:        int64(len(manifestData)),
+			Annotations: annotations,
+		}},
+	}
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	layoutData, err := json.Marshal(ocispec.ImageLayout{Version: ocispec.ImageLayoutVersion})
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	for _, dir := range []string{"blobs/", "blobs/sha256/"} {
+		if err := tw.WriteHeader(&tar.Header{Name: dir, Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+			return err
+		}
+	}
+	if err := writeLayers(tw, tar.NewReader(archive), blobs); err != nil {
+		return err
+	}
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{blobPath(manifest.Config.Digest), config},
+		{blobPath(index.Manifests[0].Digest), manifestData},
+		{ocispec.ImageLayoutFile, layoutData},
+		{"index.json", indexData},
+	}
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data))}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// resolve follows the links between layer directories.
+func resolve(entries map[string]*savedEntry, name string) (string, *savedEntry, error) {
+	for i := 0; i < 10; i++ {
+		entry, ok := entries[name]
+		if !ok {
+			return "", nil, fmt.Errorf("layer %s not found in the saved image", name)
+		}
+		if entry.link == "" {
+			return name, entry, nil
+		}
+		name = entry.link
+	}
+	return "", nil, fmt.Errorf("too many links to layer %s in the saved image", name)
+}
+
+// writeLayers copies the layers of the archive to their blob, once each.
+func writeLayers(tw *tar.Writer, tr *tar.Reader, blobs map[digest.Digest]string) error {
+	sources := map[string]digest.Digest{}
+	for d, source := range blobs {
+		sources[source] = d
+	}
+	for len(sources) > 0 {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("%d layers missing in the saved image", len(sources))
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		d, ok := sources[name]
+		if !ok {
+			continue
+		}
+		delete(sources, name)
+		if err := tw.WriteHeader(&tar.Header{Name: blobPath(d), Mode: 0644, Size: hdr.Size}); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func blobPath(d digest.Digest) string {
+	return path.Join("blobs", d.Algorithm().String(), d.Encoded())
+}
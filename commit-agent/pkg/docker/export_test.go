@@ -0,0 +1,157 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/docker/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type archiveFile struct {
+	name string
+	data string
+	link string
+}
+
+func writeTar(t *testing.T, files []archiveFile) *bytes.Reader {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data))}
+		if f.link != "" {
+			hdr = &tar.Header{Name: f.name, Typeflag: tar.TypeSymlink, Linkname: f.link}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func readTar(t *testing.T, r io.Reader) map[string][]byte {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[hdr.Name] = data
+	}
+}
+
+func TestToOCILayout(t *testing.T) {
+	config := `{"architecture":"amd64","os":"linux","rootfs":{"type":"layers","diff_ids":[]}}`
+	testCases := []struct {
+		testName string
+		files    []archiveFile
+		layers   []string
+		copied   bool
+	}{
+		{
+			testName: "legacy archive",
+			files: []archiveFile{
+				{name: "aaa/layer.tar", data: "base"},
+				{name: "aaa/json", data: "{}"},
+				{name: "bbb/layer.tar", data: "changes"},
+				{name: "cfg.json", data: config},
+				{name: "manifest.json", data: `[{"Config":"cfg.json","RepoTags":["notebook:v1"],"Layers":["aaa/layer.tar","bbb/layer.tar"]}]`},
+			},
+			layers: []string{"base", "changes"},
+		},
+		{
+			testName: "legacy archive with a shared layer",
+			files: []archiveFile{
+				{name: "aaa/layer.tar", data: "base"},
+				{name: "bbb/layer.tar", link: "../aaa/layer.tar"},
+				{name: "cfg.json", data: config},
+				{name: "manifest.json", data: `[{"Config":"cfg.json","RepoTags":["notebook:v1"],"Layers":["aaa/layer.tar","bbb/layer.tar"]}]`},
+			},
+			layers: []string{"base", "base"},
+		},
+		{
+			testName: "oci archive",
+			files: []archiveFile{
+				{name: "oci-layout", data: `{"imageLayoutVersion":"1.0.0"}`},
+				{name: "index.json", data: `{"schemaVersion":2}`},
+			},
+			copied: true,
+		},
+	}
+
+	named, err := reference.ParseNormalizedNamed("notebook:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := toOCILayout(writeTar(t, tc.files), out, named); err != nil {
+				t.Fatal(err)
+			}
+			files := readTar(t, out)
+			if tc.copied {
+				if len(files) != len(tc.files) {
+					t.Errorf("expected the archive to be copied, got %d files", len(files))
+				}
+				return
+			}
+
+			index := ocispec.Index{}
+			if err := json.Unmarshal(files["index.json"], &index); err != nil {
+				t.Fatal(err)
+			}
+			if len(index.Manifests) != 1 || index.Manifests[0].Annotations[ocispec.AnnotationRefName] != "v1" {
+				t.Fatalf("unexpected index %s", files["index.json"])
+			}
+			manifest := ocispec.Manifest{}
+			if err := json.Unmarshal(files[blobPath(index.Manifests[0].Digest)], &manifest); err != nil {
+				t.Fatal(err)
+			}
+			if string(files[blobPath(manifest.Config.Digest)]) != config {
+				t.Errorf("config blob does not match")
+			}
+			if len(manifest.Layers) != len(tc.layers) {
+				t.Fatalf("expected %d layers, got %d", len(tc.layers), len(manifest.Layers))
+			}
+			for i, l := range manifest.Layers {
+				if l.Digest != digest.FromString(tc.layers[i]) || string(files[blobPath(l.Digest)]) != tc.layers[i] {
+					t.Errorf("layer %d does not match %q", i, tc.layers[i])
+				}
+			}
+		})
+	}
+}
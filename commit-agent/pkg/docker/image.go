@@ -0,0 +1,240 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/opencontainers/go-digest"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/layer"
+)
+
+// archiveManifest is an entry of the manifest.json of `docker save` archives.
+type archiveManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// excludePaths replaces the top layer of the committed image, the one holding
+// the container changes, with a copy that leaves out the paths excluded by m.
+// The image is streamed out of the daemon and loaded back with only the new
+// top layer, the daemon reuses the layers below it that it already has.
+func (c *Client) excludePaths(ctx context.Context, image string, m *layer.Matcher, maxSize int64) error {
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+	named = reference.TagNameOnly(named)
+
+	inspect, _, err := c.Client.ImageInspectWithRaw(ctx, named.String())
+	if err != nil {
+		return err
+	}
+	diffIDs := inspect.RootFS.Layers
+	if len(diffIDs) == 0 {
+		return fmt.Errorf("image %s has no layer to exclude paths from", image)
+	}
+	imageID, err := digest.Parse(inspect.ID)
+	if err != nil {
+		return err
+	}
+	topDiffID, err := digest.Parse(diffIDs[len(diffIDs)-1])
+	if err != nil {
+		return err
+	}
+
+	saved, err := c.Client.ImageSave(ctx, []string{named.String()})
+	if err != nil {
+		return err
+	}
+	defer saved.Close()
+
+	spool, err := os.CreateTemp("", "commit-agent-layer-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	config, newDiffID, err := filterArchive(saved, spool, imageID, topDiffID, m, maxSize)
+	if err != nil {
+		return err
+	}
+	config, err = replaceTopDiffID(config, newDiffID)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeArchive(writer, spool, config, reference.FamiliarString(named), len(diffIDs)))
+	}()
+	loaded, err := c.Client.ImageLoad(ctx, reader, true)
+	if err != nil {
+		reader.CloseWithError(err)
+		return err
+	}
+	defer loaded.Body.Close()
+	if err := jsonmessage.DisplayJSONMessagesStream(loaded.Body, io.Discard, 0, false, nil); err != nil {
+		return err
+	}
+
+	// the loaded image took over the tag, drop the unfiltered one
+	_, err = c.Client.ImageRemove(ctx, imageID.String(), types.ImageRemoveOptions{PruneChildren: true})
+	return err
+}
+
+// filterArchive reads a `docker save` archive and writes the top layer
+// without the excluded paths to spool. It returns the raw image config and
+// the diff id of the filtered layer.
+//
+// The legacy archive keeps every layer in a directory with a v1 json, of
+// which only the top one carries the container config, the OCI archive names
+// uncompressed layer blobs after their diff id.
+func filterArchive(r io.Reader, spool io.Writer, imageID, topDiffID digest.Digest, m *layer.Matcher, maxSize int64) ([]byte, digest.Digest, error) {
+	configNames := map[string]bool{
+		imageID.Encoded() + ".json":         true,
+		"blobs/sha256/" + imageID.Encoded(): true,
+	}
+	topLayerNames := map[string]bool{
+		"blobs/sha256/" + topDiffID.Encoded(): true,
+	}
+
+	var config []byte
+	var diffID digest.Digest
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case configNames[name]:
+			if config, err = io.ReadAll(tr); err != nil {
+				return nil, "", err
+			}
+		case path.Base(name) == "json":
+			v1 := struct {
+				Config json.RawMessage `json:"config"`
+			}{}
+			if err := json.NewDecoder(tr).Decode(&v1); err != nil {
+				return nil, "", fmt.Errorf("malformed layer json %s: %w", name, err)
+			}
+			if len(v1.Config) > 0 && string(v1.Config) != "null" {
+				topLayerNames[path.Join(path.Dir(name), "layer.tar")] = true
+			}
+		case topLayerNames[name] && diffID == "":
+			digester := digest.SHA256.Digester()
+			if _, err := layer.Filter(io.MultiWriter(spool, digester.Hash()), tr, m, maxSize); err != nil {
+				return nil, "", err
+			}
+			diffID = digester.Digest()
+		}
+	}
+
+	switch {
+	case config == nil:
+		return nil, "", fmt.Errorf("image config %s not found in the saved image", imageID)
+	case diffID == "":
+		return nil, "", fmt.Errorf("layer %s not found in the saved image", topDiffID)
+	}
+	return config, diffID, nil
+}
+
+// replaceTopDiffID points the top layer of the raw image config at diffID,
+// leaving every other field untouched.
+func replaceTopDiffID(config []byte, diffID digest.Digest) ([]byte, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(config, &fields); err != nil {
+		return nil, err
+	}
+	rootFS := map[string]interface{}{}
+	if err := json.Unmarshal(fields["rootfs"], &rootFS); err != nil {
+		return nil, err
+	}
+	ids, _ := rootFS["diff_ids"].([]interface{})
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("image config has no diff ids")
+	}
+	ids[len(ids)-1] = diffID.String()
+
+	var err error
+	if fields["rootfs"], err = json.Marshal(rootFS); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// writeArchive writes a legacy `docker save` archive of the image with the
+// filtered top layer. The layers below it are not included as the daemon
+// only reads layers it does not have yet.
+func writeArchive(w io.Writer, topLayer *os.File, config []byte, tag string, layers int) error {
+	stat, err := topLayer.Stat()
+	if err != nil {
+		return err
+	}
+	configName := digest.FromBytes(config).Encoded() + ".json"
+	manifest := []archiveManifest{{
+		Config:   configName,
+		RepoTags: []string{tag},
+	}}
+	for i := 0; i < layers-1; i++ {
+		manifest[0].Layers = append(manifest[0].Layers, fmt.Sprintf("base-%d/layer.tar", i))
+	}
+	manifest[0].Layers = append(manifest[0].Layers, "top/layer.tar")
+	manifestData, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: "top/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: "top/layer.tar", Mode: 0644, Size: stat.Size()}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, topLayer); err != nil {
+		return err
+	}
+	for name, data := range map[string][]byte{configName: config, "manifest.json": manifestData} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data))}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
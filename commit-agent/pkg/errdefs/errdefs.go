@@ -0,0 +1,135 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/containerd/containerd/remotes/docker"
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/containerd/nerdctl/pkg/errutil"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	ErrContainerNotFound    = errors.New("container not found")
+	ErrAmbiguousContainerID = errors.New("ambiguous container id")
+	ErrRegistryUnauthorized = errors.New("registry authentication failed")
+	ErrRegistryUnreachable  = errors.New("registry unreachable")
+	ErrImageTooLarge        = errors.New("image too large")
+	ErrImageNotFound        = errors.New("image not found")
+	ErrImageExists          = errors.New("image already exists")
+	ErrNotCommittedImage    = errors.New("image was not committed by the agent")
+	ErrNotImplemented       = errors.New("not supported by the container runtime")
+	ErrRuntimeNotFound      = errors.New("no container runtime found")
+)
+
+// Code maps err to the gRPC status code reported to clients.
+func Code(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, ErrContainerNotFound), errors.Is(err, ErrImageNotFound):
+		return codes.NotFound
+	case errors.Is(err, ErrAmbiguousContainerID):
+		return codes.FailedPrecondition
+	case errors.Is(err, ErrImageExists):
+		return codes.AlreadyExists
+	case errors.Is(err, ErrRegistryUnauthorized):
+		return codes.Unauthenticated
+	case errors.Is(err, ErrRegistryUnreachable):
+		return codes.Unavailable
+	case errors.Is(err, ErrImageTooLarge):
+		return codes.ResourceExhausted
+	case errors.Is(err, ErrNotCommittedImage):
+		return codes.PermissionDenied
+	case errors.Is(err, ErrNotImplemented):
+		return codes.Unimplemented
+	default:
+		return codes.Internal
+	}
+}
+
+// Hint returns an actionable message for the codes returned by Code.
+func Hint(code codes.Code) string {
+	switch code {
+	case codes.NotFound:
+		return "the notebook container was not found on this node, make sure the command runs inside the notebook"
+	case codes.FailedPrecondition:
+		return "the container id matches more than one container, use the full container id"
+	case codes.AlreadyExists:
+		return "the target name holds another image on the node, push to another name or remove that image first"
+	case codes.Unauthenticated:
+		return "the registry rejected the credentials, check the username, password and push permission of the repository"
+	case codes.Unavailable:
+		return "the registry cannot be reached from the node, check the registry address and the network"
+	case codes.Unimplemented:
+		return "the container runtime of the node cannot commit or push images, docker and containerd can"
+	case codes.ResourceExhausted:
+		return "the container changes exceed the size limit, exclude datasets and caches with --exclude or raise --max-size"
+	default:
+		return ""
+	}
+}
+
+// RegistryError wraps a push failure with ErrRegistryUnauthorized or
+// ErrRegistryUnreachable when it can be recognized as one.
+func RegistryError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isUnauthorized(err):
+		return fmt.Errorf("%w: %v", ErrRegistryUnauthorized, err)
+	case isUnreachable(err):
+		return fmt.Errorf("%w: %v", ErrRegistryUnreachable, err)
+	default:
+		return err
+	}
+}
+
+func isUnauthorized(err error) bool {
+	if errors.Is(err, docker.ErrInvalidAuthorization) {
+		return true
+	}
+	var statusErr remoteerrors.ErrUnexpectedStatus
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+	}
+	// the docker daemon only reports registry failures as messages
+	return containsAny(err.Error(), "unauthorized", "authentication required", "no basic auth credentials", "access to the resource is denied")
+}
+
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errutil.IsErrConnectionRefused(err) {
+		return true
+	}
+	return containsAny(err.Error(), "no such host", "connection refused", "i/o timeout", "network is unreachable", "tls handshake timeout")
+}
+
+func containsAny(msg string, substrs ...string) bool {
+	msg = strings.ToLower(msg)
+	for _, s := range substrs {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCode(t *testing.T) {
+	testCases := []struct {
+		testName string
+		err      error
+		code     codes.Code
+	}{
+		{
+			testName: "nil error",
+			err:      nil,
+			code:     codes.OK,
+		},
+		{
+			testName: "container not found",
+			err:      fmt.Errorf("%w: abc", ErrContainerNotFound),
+			code:     codes.NotFound,
+		},
+		{
+			testName: "ambiguous container id",
+			err:      fmt.Errorf("%w \"ab\"", ErrAmbiguousContainerID),
+			code:     codes.FailedPrecondition,
+		},
+		{
+			testName: "image not found",
+			err:      fmt.Errorf("%w: notebook:v1", ErrImageNotFound),
+			code:     codes.NotFound,
+		},
+		{
+			testName: "push target names another image",
+			err:      fmt.Errorf("%w: backup:v1", ErrImageExists),
+			code:     codes.AlreadyExists,
+		},
+		{
+			testName: "image not committed by the agent",
+			err:      fmt.Errorf("%w: nginx:latest", ErrNotCommittedImage),
+			code:     codes.PermissionDenied,
+		},
+		{
+			testName: "container changes over the size limit",
+			err:      fmt.Errorf("%w: 12GB changed", ErrImageTooLarge),
+			code:     codes.ResourceExhausted,
+		},
+		{
+			testName: "registry returns 401",
+			err:      RegistryError(remoteerrors.ErrUnexpectedStatus{Status: "401 Unauthorized", StatusCode: 401}),
+			code:     codes.Unauthenticated,
+		},
+		{
+			testName: "docker daemon reports denied push",
+			err:      RegistryError(errors.New("denied: requested access to the resource is denied")),
+			code:     codes.Unauthenticated,
+		},
+		{
+			testName: "registry dns failure",
+			err:      RegistryError(&net.DNSError{Err: "no such host", Name: "registry.example.com"}),
+			code:     codes.Unavailable,
+		},
+		{
+			testName: "unknown push failure",
+			err:      RegistryError(errors.New("manifest invalid")),
+			code:     codes.Internal,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if code := Code(c.err); code != c.code {
+				t.Errorf("expected code %s, got %s", c.code, code)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount/"
+
+	// Labels set by the kubelet on every container of a pod.
+	PodNamespaceLabel  = "io.kubernetes.pod.namespace"
+	PodNameLabel       = "io.kubernetes.pod.name"
+	PodUIDLabel        = "io.kubernetes.pod.uid"
+	ContainerNameLabel = "io.kubernetes.container.name"
+
+	SecretTypeDockerConfigJSON = "kubernetes.io/dockerconfigjson"
+	SecretTypeDockercfg        = "kubernetes.io/dockercfg"
+)
+
+var ErrNotInCluster = errors.New("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+
+// Client is a minimal read-only client of the Kubernetes API server, it only
+// covers the pod and secret lookups the agent needs.
+type Client struct {
+	host       string
+	tokenFile  string
+	httpClient *http.Client
+}
+
+// Pod is the part of a pod the agent relies on.
+type Pod struct {
+	Labels           map[string]string
+	ImagePullSecrets []string
+}
+
+type pod struct {
+	Metadata struct {
+		Labels map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		ImagePullSecrets []struct {
+			Name string `json:"name"`
+		} `json:"imagePullSecrets"`
+	} `json:"spec"`
+}
+
+type secret struct {
+	Type string            `json:"type"`
+	Data map[string][]byte `json:"data"`
+}
+
+// NewInClusterClient creates a client from the service account mounted into the agent pod.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, ErrNotInCluster
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %sca.crt", serviceAccountDir)
+	}
+
+	return &Client{
+		host:      "https://" + net.JoinHostPort(host, port),
+		tokenFile: serviceAccountDir + "token",
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+			},
+		},
+	}, nil
+}
+
+// GetPod returns the labels and imagePullSecrets of a pod.
+func (c *Client) GetPod(ctx context.Context, namespace, name string) (*Pod, error) {
+	p := &pod{}
+	if err := c.get(ctx, fmt.Sprintf("/api/v1/namespaces/%s/pods/%s", url.PathEscape(namespace), url.PathEscape(name)), p); err != nil {
+		return nil, err
+	}
+	result := &Pod{
+		Labels:           p.Metadata.Labels,
+		ImagePullSecrets: make([]string, 0, len(p.Spec.ImagePullSecrets)),
+	}
+	for _, s := range p.Spec.ImagePullSecrets {
+		result.ImagePullSecrets = append(result.ImagePullSecrets, s.Name)
+	}
+	return result, nil
+}
+
+// DockerConfig returns the content of a dockerconfigjson secret. Legacy
+// dockercfg secrets are converted to the config.json layout.
+func (c *Client) DockerConfig(ctx context.Context, namespace, name string) ([]byte, error) {
+	s := &secret{}
+	if err := c.get(ctx, fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", url.PathEscape(namespace), url.PathEscape(name)), s); err != nil {
+		return nil, err
+	}
+	switch s.Type {
+	case SecretTypeDockerConfigJSON:
+		return s.Data[".dockerconfigjson"], nil
+	case SecretTypeDockercfg:
+		return json.Marshal(map[string]json.RawMessage{"auths": s.Data[".dockercfg"]})
+	default:
+		return nil, fmt.Errorf("secret %s/%s has type %q, expected %s", namespace, name, s.Type, SecretTypeDockerConfigJSON)
+	}
+}
+
+func (c *Client) get(ctx context.Context, path string, into interface{}) error {
+	// the token is read on every request since projected tokens are rotated
+	token, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(into)
+}
@@ -0,0 +1,122 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package layer
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/moby/patternmatcher"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+)
+
+// Matcher tells which paths of a container filesystem are excluded from a
+// commit. Patterns use the .dockerignore syntax, may start with a slash and
+// exclude everything below a matched directory.
+type Matcher struct {
+	patterns *patternmatcher.PatternMatcher
+}
+
+// NewMatcher compiles the exclude patterns.
+func NewMatcher(excludes []string) (*Matcher, error) {
+	patterns := make([]string, 0, len(excludes))
+	for _, pattern := range excludes {
+		pattern = strings.TrimSpace(pattern)
+		negate := strings.HasPrefix(pattern, "!")
+		exclude := strings.TrimLeft(strings.TrimPrefix(pattern, "!"), "/")
+		if exclude == "" {
+			return nil, fmt.Errorf("exclude pattern %q matches the whole filesystem", pattern)
+		}
+		if negate {
+			exclude = "!" + exclude
+		}
+		patterns = append(patterns, exclude)
+	}
+	pm, err := patternmatcher.New(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("malformed exclude pattern: %w", err)
+	}
+	return &Matcher{patterns: pm}, nil
+}
+
+// Validate checks that every exclude pattern is well formed.
+func Validate(excludes []string) error {
+	_, err := NewMatcher(excludes)
+	return err
+}
+
+// Excluded reports whether the layer entry name is excluded.
+func (m *Matcher) Excluded(name string) (bool, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return false, nil
+	}
+	return m.patterns.MatchesOrParentMatches(name)
+}
+
+// Filter copies the uncompressed layer tar read from r to w without the
+// entries excluded by m and returns the size of the file content kept. When
+// maxSize is positive Filter stops with errdefs.ErrImageTooLarge as soon as
+// the content kept exceeds it.
+func Filter(w io.Writer, r io.Reader, m *Matcher, maxSize int64) (int64, error) {
+	tr := tar.NewReader(r)
+	tw := tar.NewWriter(w)
+	var size int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return size, err
+		}
+
+		excluded, err := m.Excluded(hdr.Name)
+		if err != nil {
+			return size, err
+		}
+		// a hard link to an excluded file has nothing left to point at
+		if !excluded && hdr.Typeflag == tar.TypeLink {
+			excluded, err = m.Excluded(hdr.Linkname)
+			if err != nil {
+				return size, err
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			size += hdr.Size
+			if maxSize > 0 && size > maxSize {
+				return size, fmt.Errorf("%w: the changes left after the excludes are larger than %s",
+					errdefs.ErrImageTooLarge, units.HumanSize(float64(maxSize)))
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return size, err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return size, err
+		}
+	}
+	return size, tw.Close()
+}
@@ -0,0 +1,182 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package layer
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+}
+
+func writeLayer(t *testing.T, entries []entry) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644, Size: int64(len(e.content))}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content[:hdr.Size])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func readNames(t *testing.T, r io.Reader) []string {
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	layer := []entry{
+		{name: "root/", typeflag: tar.TypeDir},
+		{name: "root/.cache/", typeflag: tar.TypeDir},
+		{name: "root/.cache/pip/wheel.whl", typeflag: tar.TypeReg, content: "wheel"},
+		{name: "root/model.ckpt", typeflag: tar.TypeReg, content: "weights"},
+		{name: "root/train.py", typeflag: tar.TypeReg, content: "print()"},
+		{name: "root/train-link.py", typeflag: tar.TypeLink, linkname: "root/train.py"},
+		{name: "root/cache-link", typeflag: tar.TypeLink, linkname: "root/.cache/pip/wheel.whl"},
+		{name: "data/.wh..wh..opq", typeflag: tar.TypeReg},
+		{name: "data/set.csv", typeflag: tar.TypeReg, content: "a,b"},
+	}
+
+	testCases := []struct {
+		testName string
+		excludes []string
+		maxSize  int64
+		expected []string
+		size     int64
+		tooLarge bool
+	}{
+		{
+			testName: "no excludes",
+			expected: []string{"root/", "root/.cache/", "root/.cache/pip/wheel.whl", "root/model.ckpt", "root/train.py",
+				"root/train-link.py", "root/cache-link", "data/.wh..wh..opq", "data/set.csv"},
+			size: 22,
+		},
+		{
+			testName: "absolute directory with hard link into it",
+			excludes: []string{"/root/.cache"},
+			expected: []string{"root/", "root/model.ckpt", "root/train.py", "root/train-link.py", "data/.wh..wh..opq", "data/set.csv"},
+			size:     17,
+		},
+		{
+			testName: "glob in any directory and re-included path",
+			excludes: []string{"**/*.ckpt", "data", "!data/set.csv"},
+			expected: []string{"root/", "root/.cache/", "root/.cache/pip/wheel.whl", "root/train.py", "root/train-link.py",
+				"root/cache-link", "data/set.csv"},
+			size: 15,
+		},
+		{
+			testName: "size under the limit after excludes",
+			excludes: []string{"root/model.ckpt"},
+			maxSize:  15,
+			expected: []string{"root/", "root/.cache/", "root/.cache/pip/wheel.whl", "root/train.py", "root/train-link.py",
+				"root/cache-link", "data/.wh..wh..opq", "data/set.csv"},
+			size: 15,
+		},
+		{
+			testName: "size over the limit",
+			maxSize:  10,
+			tooLarge: true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			m, err := NewMatcher(c.excludes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			size, err := Filter(out, writeLayer(t, layer), m, c.maxSize)
+			if c.tooLarge {
+				if !errors.Is(err, errdefs.ErrImageTooLarge) {
+					t.Fatalf("expected %v, got %v", errdefs.ErrImageTooLarge, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != c.size {
+				t.Errorf("expected size %d, got %d", c.size, size)
+			}
+			if names := readNames(t, out); !reflect.DeepEqual(names, c.expected) {
+				t.Errorf("expected entries %v, got %v", c.expected, names)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		testName string
+		excludes []string
+		valid    bool
+	}{
+		{
+			testName: "paths and globs",
+			excludes: []string{"/root/.cache", "**/*.ckpt", "!data/keep"},
+			valid:    true,
+		},
+		{
+			testName: "root of the filesystem",
+			excludes: []string{"/"},
+		},
+		{
+			testName: "malformed glob",
+			excludes: []string{"data/[a-"},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if err := Validate(c.excludes); (err == nil) != c.valid {
+				t.Errorf("expected valid %t, got %v", c.valid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+// Package metrics holds the Prometheus metrics of the agent.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+)
+
+const namespace = "commit_agent"
+
+var (
+	operations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operations_total",
+		Help:      "Commit and push operations by type and result.",
+	}, []string{"type", "result"})
+
+	failures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "operation_failures_total",
+		Help:      "Failed operations by type and reason, the reason is the gRPC status code of the error.",
+	}, []string{"type", "reason"})
+
+	duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of the commit and push operations.",
+		// notebook images take from seconds to more than half an hour
+		Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+	}, []string{"type", "result"})
+
+	pushedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "pushed_bytes_total",
+		Help:      "Bytes of the layers pushed to registries.",
+	})
+
+	runtimeUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "runtime_up",
+		Help:      "Whether the container runtime answered the last health check.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(operations, failures, duration, pushedBytes, runtimeUp)
+}
+
+// ObserveOperation records a finished operation, err is nil on success.
+func ObserveOperation(opType string, elapsed time.Duration, pushed int64, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+		failures.WithLabelValues(opType, errdefs.Code(err).String()).Inc()
+	}
+	operations.WithLabelValues(opType, result).Inc()
+	duration.WithLabelValues(opType, result).Observe(elapsed.Seconds())
+	if pushed > 0 {
+		pushedBytes.Add(float64(pushed))
+	}
+}
+
+// SetRuntimeUp records the result of a runtime health check.
+func SetRuntimeUp(up bool) {
+	if up {
+		runtimeUp.Set(1)
+	} else {
+		runtimeUp.Set(0)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,79 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+)
+
+func TestObserveOperation(t *testing.T) {
+	testCases := []struct {
+		testName string
+		opType   string
+		pushed   int64
+		err      error
+		result   string
+		reason   string
+	}{
+		{
+			testName: "successful push",
+			opType:   "Push",
+			pushed:   1024,
+			result:   "success",
+		},
+		{
+			testName: "commit too large",
+			opType:   "Commit",
+			err:      fmt.Errorf("%w: 2GB", errdefs.ErrImageTooLarge),
+			result:   "failure",
+			reason:   "ResourceExhausted",
+		},
+		{
+			testName: "unclassified error",
+			opType:   "CommitAndPush",
+			err:      errors.New("boom"),
+			result:   "failure",
+			reason:   "Internal",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			before := testutil.ToFloat64(operations.WithLabelValues(tc.opType, tc.result))
+			bytesBefore := testutil.ToFloat64(pushedBytes)
+			ObserveOperation(tc.opType, time.Second, tc.pushed, tc.err)
+
+			if got := testutil.ToFloat64(operations.WithLabelValues(tc.opType, tc.result)) - before; got != 1 {
+				t.Errorf("expected one %s %s operation, got %v", tc.opType, tc.result, got)
+			}
+			if tc.reason != "" {
+				if got := testutil.ToFloat64(failures.WithLabelValues(tc.opType, tc.reason)); got != 1 {
+					t.Errorf("expected one failure with reason %s, got %v", tc.reason, got)
+				}
+			}
+			if got := testutil.ToFloat64(pushedBytes) - bytesBefore; got != float64(tc.pushed) {
+				t.Errorf("expected %d pushed bytes, got %v", tc.pushed, got)
+			}
+		})
+	}
+}
@@ -16,75 +16,314 @@
 package operate
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"os"
-	"syscall"
+	"strings"
+	"sync"
 
-	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/containerd"
-	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/docker"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/credentials"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/kube"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/runtimes"
 	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+	"github.com/docker/go-units"
 	log "github.com/sirupsen/logrus"
 )
 
-func FileExist(path string) bool {
-	err := syscall.Access(path, syscall.F_OK)
-	return !os.IsNotExist(err)
+// Operator runs commits and pushes against the container runtime of the node.
+type Operator struct {
+	kube          *kube.Client
+	credentials   *credentials.Resolver
+	runtimeConfig runtimes.Config
+
+	mu     sync.Mutex
+	client _type.ContainerClient
 }
 
-func CommitContainer(containerID, image string) (string, error) {
-	isDocker := FileExist("/host/run/docker.sock")
-	var client _type.ContainerClient
-	var err error
+// NewOperator creates an Operator. dockerConfig is the docker config.json
+// used for registries not covered by the referenced secrets.
+func NewOperator(dockerConfig string, runtimeConfig runtimes.Config) *Operator {
+	kubeClient, err := kube.NewInClusterClient()
+	if err != nil {
+		log.Warnf("kubernetes api is not available, registry secrets and notebook labels are disabled: %v", err)
+	}
+	return &Operator{
+		kube:          kubeClient,
+		credentials:   credentials.NewResolver(kubeClient, dockerConfig),
+		runtimeConfig: runtimeConfig,
+	}
+}
 
-	if isDocker {
-		client, err = docker.NewDockerClient()
-		if err != nil {
-			log.Errorln("Docker client init error", err)
-			return "Docker client init error", err
-		}
-	} else {
-		client, err = containerd.NewContainerdClient()
-		if err != nil {
-			log.Errorln("Containerd client init error", err)
-			return "Containerd client init error", err
-		}
+// newClient returns the client of the container runtime of the node. The
+// runtime is detected on first use, and again as long as detection fails.
+func (o *Operator) newClient() (_type.ContainerClient, string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.client != nil {
+		return o.client, "", nil
+	}
+
+	client, info, err := runtimes.Detect(o.runtimeConfig)
+	if err != nil {
+		log.Errorln("Container runtime init error", err)
+		return nil, "Container runtime init error", err
+	}
+	log.Infof("using %s %s at %s", info.Name, info.Version, info.Endpoint)
+	o.client = client
+	return client, "", nil
+}
+
+// RuntimeInfo describes the container runtime in use and checks that it
+// still answers.
+func (o *Operator) RuntimeInfo() (*_type.RuntimeInfo, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
 	}
+	return client.Info()
+}
 
-	err = client.CommitImageFromSelf(containerID, image)
+func (o *Operator) CommitContainer(containerID, image string, opts _type.CommitOptions, progress _type.Progress) (string, error) {
+	client, msg, err := o.newClient()
+	if err != nil {
+		return msg, err
+	}
+
+	size, err := checkDiffSize(client, containerID, opts, progress)
+	if err != nil {
+		log.Errorln("Container size check error", err)
+		return "Container size check error", err
+	}
+	if opts.DryRun {
+		return dryRunResult(size), nil
+	}
+
+	progress.SetPhase(v1beta1.Phase_COMMITTING)
+	err = o.commit(client, containerID, image, opts)
 	if err != nil {
 		log.Errorln("Container save error", err)
 		return "Container save error", err
 	}
-	msg := fmt.Sprintf("Container save success, image: %s", image)
+	msg = fmt.Sprintf("Container save success, image: %s", image)
 	log.Println(msg)
 	return msg, nil
 }
 
-func PushImage(image string, username string, password string) (string, error) {
-	isDocker := FileExist("/host/run/docker.sock")
-	var client _type.ContainerClient
-	var err error
+// PushImage pushes the image, and then to every target.
+func (o *Operator) PushImage(image string, targets []string, auth credentials.Options, progress _type.Progress) (string, error) {
+	client, msg, err := o.newClient()
+	if err != nil {
+		return msg, err
+	}
 
-	if isDocker {
-		client, err = docker.NewDockerClient()
-		if err != nil {
-			log.Errorln("Docker client init error", err)
-			return "Docker client init error", err
-		}
-	} else {
-		client, err = containerd.NewContainerdClient()
-		if err != nil {
-			log.Errorln("Containerd client init error", err)
-			return "Containerd client init error", err
-		}
+	creds, err := o.credentials.Credentials(context.Background(), client, auth)
+	if err != nil {
+		log.Errorln("registry credentials error:", err)
+		return "registry credentials error", err
+	}
+
+	err = client.PushImageFromSelf(image, creds, progress)
+	if err != nil {
+		log.Errorln("image push error:", err)
+		return "image push error", err
+	}
+	if err := pushTargets(client, image, targets, creds, progress); err != nil {
+		log.Errorln("image push error:", err)
+		return "image push error", err
+	}
+	msg = fmt.Sprintf("Image pushed successfully: %s", strings.Join(append([]string{image}, targets...), ", "))
+	log.Println(msg)
+	return msg, nil
+}
+
+// CommitAndPush commits the container and pushes the result, and then to
+// every target. If the push of image fails the committed image is removed so
+// no partial result is left behind.
+func (o *Operator) CommitAndPush(containerID, image string, targets []string, opts _type.CommitOptions, auth credentials.Options, progress _type.Progress) (string, error) {
+	client, msg, err := o.newClient()
+	if err != nil {
+		return msg, err
+	}
+
+	auth.ContainerID = containerID
+	creds, err := o.credentials.Credentials(context.Background(), client, auth)
+	if err != nil {
+		log.Errorln("registry credentials error:", err)
+		return "registry credentials error", err
+	}
+
+	size, err := checkDiffSize(client, containerID, opts, progress)
+	if err != nil {
+		log.Errorln("Container size check error", err)
+		return "Container size check error", err
+	}
+	if opts.DryRun {
+		return dryRunResult(size), nil
+	}
+
+	progress.SetPhase(v1beta1.Phase_COMMITTING)
+	err = o.commit(client, containerID, image, opts)
+	if err != nil {
+		log.Errorln("Container save error", err)
+		return "Container save error", err
 	}
 
-	err = client.PushImageFromSelf(image, username, password)
+	err = client.PushImageFromSelf(image, creds, progress)
 	if err != nil {
 		log.Errorln("image push error:", err)
+		if rmErr := client.RemoveImage(image); rmErr != nil {
+			log.Warnf("failed to clean up committed image %s: %v", image, rmErr)
+		}
 		return "image push error", err
 	}
-	msg := fmt.Sprintf("Image pushed successfully: %s", image)
+	if err := pushTargets(client, image, targets, creds, progress); err != nil {
+		log.Errorln("image push error:", err)
+		return "image push error", err
+	}
+	msg = fmt.Sprintf("Container saved and pushed successfully: %s", strings.Join(append([]string{image}, targets...), ", "))
 	log.Println(msg)
 	return msg, nil
 }
+
+// pushTargets tags the pushed image as every target and pushes it there. A
+// failing target does not stop the others, the error names all that failed.
+func pushTargets(client _type.ContainerClient, image string, targets []string, creds _type.Credentials, progress _type.Progress) error {
+	var failed []string
+	var firstErr error
+	for _, target := range targets {
+		if err := pushTarget(client, image, target, creds, progress); err != nil {
+			log.Errorf("push to %s failed: %v", target, err)
+			failed = append(failed, fmt.Sprintf("%s (%v)", target, err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("pushed %s, failed to push to %s: %w", image, strings.Join(failed, ", "), firstErr)
+	}
+	return nil
+}
+
+// pushTarget pushes the image as target. A target naming another image of
+// the node is refused, tagging would repoint it. A target tag created for the
+// push is removed again.
+func pushTarget(client _type.ContainerClient, image, target string, creds _type.Credentials, progress _type.Progress) error {
+	source, err := client.InspectImage(image)
+	if err != nil {
+		return err
+	}
+	existing, err := client.InspectImage(target)
+	switch {
+	case err == nil:
+		if existing.ID != source.ID {
+			return fmt.Errorf("%w: %s names another image on the node", errdefs.ErrImageExists, target)
+		}
+		// the target already names the image
+		return client.PushImageFromSelf(target, creds, progress)
+	case !errors.Is(err, errdefs.ErrImageNotFound):
+		return err
+	}
+
+	if err := client.TagImage(image, target); err != nil {
+		return err
+	}
+	defer func() {
+		if err := client.RemoveImage(target); err != nil {
+			log.Warnf("failed to remove tag %s: %v", target, err)
+		}
+	}()
+	return client.PushImageFromSelf(target, creds, progress)
+}
+
+// checkDiffSize reports the size of the container changes and rejects them
+// when they are over opts.MaxSize. With excludes the runtime checks the size
+// again while filtering the layer, as leaving out a dataset or a cache may
+// bring the changes under the limit.
+func checkDiffSize(client _type.ContainerClient, containerID string, opts _type.CommitOptions, progress _type.Progress) (int64, error) {
+	size, err := client.DiffSize(containerID)
+	if err != nil {
+		return 0, err
+	}
+	progress.SetDiffSize(size)
+	log.Infof("container %s changed %s", containerID, units.HumanSize(float64(size)))
+	if opts.MaxSize > 0 && size > opts.MaxSize && len(opts.Excludes) == 0 {
+		return size, fmt.Errorf("%w: the container changed %s, the limit is %s", errdefs.ErrImageTooLarge,
+			units.HumanSize(float64(size)), units.HumanSize(float64(opts.MaxSize)))
+	}
+	return size, nil
+}
+
+func dryRunResult(size int64) string {
+	return fmt.Sprintf("Dry run, the container changed %s before excludes, no image was committed", units.HumanSize(float64(size)))
+}
+
+// commit labels the image with the notebook it was saved from, labels set
+// by the caller take precedence.
+func (o *Operator) commit(client _type.ContainerClient, containerID, image string, opts _type.CommitOptions) error {
+	labels, err := o.notebookLabels(client, containerID)
+	if err != nil {
+		return err
+	}
+	for k, v := range opts.Labels {
+		labels[k] = v
+	}
+	opts.Labels = labels
+	return client.CommitImageFromSelf(containerID, image, opts)
+}
+
+func (o *Operator) notebookLabels(client _type.ContainerClient, containerID string) (map[string]string, error) {
+	info, err := client.ContainerInfo(containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{
+		pkg.SourceImageLabel: info.Image,
+	}
+	namespace, podName := info.Labels[kube.PodNamespaceLabel], info.Labels[kube.PodNameLabel]
+	if namespace == "" {
+		return labels, nil
+	}
+	labels[pkg.NotebookNamespaceLabel] = namespace
+	// the notebook container is named after the notebook
+	labels[pkg.NotebookNameLabel] = info.Labels[kube.ContainerNameLabel]
+
+	if o.kube == nil {
+		return labels, nil
+	}
+	pod, err := o.kube.GetPod(context.Background(), namespace, podName)
+	if err != nil {
+		log.Warnf("failed to get pod %s/%s, the owner label is skipped: %v", namespace, podName, err)
+		return labels, nil
+	}
+	if name := pod.Labels[pkg.NotebookNamePodLabel]; name != "" {
+		labels[pkg.NotebookNameLabel] = name
+	}
+	if owner := pod.Labels[pkg.NotebookOwnerPodLabel]; owner != "" {
+		labels[pkg.NotebookOwnerLabel] = owner
+	}
+	return labels, nil
+}
+
+// ContainerInfo returns the image and labels of a container.
+func (o *Operator) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.ContainerInfo(containerID)
+}
+
+// NotebookLabels returns the labels an image committed from the container
+// would get.
+func (o *Operator) NotebookLabels(containerID string) (map[string]string, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return o.notebookLabels(client, containerID)
+}
@@ -0,0 +1,147 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package operate
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+)
+
+// fakeClient keeps images by name, tagging replaces an existing name as the
+// runtimes do.
+type fakeClient struct {
+	images map[string]_type.Image
+	pushed []string
+}
+
+func (f *fakeClient) Info() (*_type.RuntimeInfo, error) { return &_type.RuntimeInfo{}, nil }
+
+func (f *fakeClient) CommitImageFromSelf(containerID, image string, opts _type.CommitOptions) error {
+	return nil
+}
+
+func (f *fakeClient) PushImageFromSelf(image string, credentials _type.Credentials, progress _type.Progress) error {
+	if _, ok := f.images[image]; !ok {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+	f.pushed = append(f.pushed, image)
+	return nil
+}
+
+func (f *fakeClient) TagImage(source, target string) error {
+	img, ok := f.images[source]
+	if !ok {
+		return fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, source)
+	}
+	img.Name = target
+	f.images[target] = img
+	return nil
+}
+
+func (f *fakeClient) ExportImage(image string, w io.Writer) error { return nil }
+
+func (f *fakeClient) ListImages() ([]_type.Image, error) { return nil, nil }
+
+func (f *fakeClient) InspectImage(image string) (*_type.Image, error) {
+	img, ok := f.images[image]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errdefs.ErrImageNotFound, image)
+	}
+	return &img, nil
+}
+
+func (f *fakeClient) RemoveImage(image string) error {
+	delete(f.images, image)
+	return nil
+}
+
+func (f *fakeClient) ContainerInfo(containerID string) (*_type.ContainerInfo, error) {
+	return nil, fmt.Errorf("%w: %s", errdefs.ErrContainerNotFound, containerID)
+}
+
+func (f *fakeClient) DiffSize(containerID string) (int64, error) { return 0, nil }
+
+func TestPushTargets(t *testing.T) {
+	testCases := []struct {
+		testName string
+		targets  []string
+		// images on the node besides nb:v1
+		images  map[string]string
+		pushed  []string
+		wantErr error
+		// remaining maps image names to ids after the push
+		remaining map[string]string
+	}{
+		{
+			testName:  "new target",
+			targets:   []string{"backup:v1"},
+			pushed:    []string{"backup:v1"},
+			remaining: map[string]string{"nb:v1": "sha256:nb"},
+		},
+		{
+			testName:  "target already names the image",
+			targets:   []string{"nb:latest"},
+			images:    map[string]string{"nb:latest": "sha256:nb"},
+			pushed:    []string{"nb:latest"},
+			remaining: map[string]string{"nb:v1": "sha256:nb", "nb:latest": "sha256:nb"},
+		},
+		{
+			testName:  "target names another image",
+			targets:   []string{"registry.k8s.io/pause:3.9"},
+			images:    map[string]string{"registry.k8s.io/pause:3.9": "sha256:pause"},
+			wantErr:   errdefs.ErrImageExists,
+			remaining: map[string]string{"nb:v1": "sha256:nb", "registry.k8s.io/pause:3.9": "sha256:pause"},
+		},
+		{
+			testName:  "refused target does not stop the others",
+			targets:   []string{"registry.k8s.io/pause:3.9", "backup:v1"},
+			images:    map[string]string{"registry.k8s.io/pause:3.9": "sha256:pause"},
+			pushed:    []string{"backup:v1"},
+			wantErr:   errdefs.ErrImageExists,
+			remaining: map[string]string{"nb:v1": "sha256:nb", "registry.k8s.io/pause:3.9": "sha256:pause"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			client := &fakeClient{images: map[string]_type.Image{"nb:v1": {Name: "nb:v1", ID: "sha256:nb"}}}
+			for name, id := range tc.images {
+				client.images[name] = _type.Image{Name: name, ID: id}
+			}
+
+			err := pushTargets(client, "nb:v1", tc.targets, nil, nil)
+			if !errors.Is(err, tc.wantErr) || (tc.wantErr == nil) != (err == nil) {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(client.pushed, tc.pushed) {
+				t.Errorf("expected pushed %v, got %v", tc.pushed, client.pushed)
+			}
+			remaining := map[string]string{}
+			for name, img := range client.images {
+				remaining[name] = img.ID
+			}
+			if !reflect.DeepEqual(remaining, tc.remaining) {
+				t.Errorf("expected images %v after the push, got %v", tc.remaining, remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package operate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/go-units"
+	log "github.com/sirupsen/logrus"
+
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// ExportImage writes the image as an OCI image layout tarball to path. The
+// tarball is written next to path and only renamed to it once complete, so
+// an interrupted export never leaves a truncated file behind.
+func (o *Operator) ExportImage(image, path string, progress _type.Progress) (string, error) {
+	client, msg, err := o.newClient()
+	if err != nil {
+		return msg, err
+	}
+
+	progress.SetPhase(v1beta1.Phase_EXPORTING)
+	size, err := exportTo(client, image, path)
+	if err != nil {
+		log.Errorln("image export error:", err)
+		return "image export error", err
+	}
+	msg = fmt.Sprintf("Image exported successfully: %s, %s", path, units.HumanSize(float64(size)))
+	log.Println(msg)
+	return msg, nil
+}
+
+func exportTo(client _type.ContainerClient, image, path string) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := client.ExportImage(image, f); err != nil {
+		return 0, err
+	}
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := f.Chmod(0644); err != nil {
+		return 0, err
+	}
+	return stat.Size(), os.Rename(f.Name(), path)
+}
@@ -0,0 +1,93 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package operate
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+)
+
+func (o *Operator) ListImages() ([]_type.Image, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.ListImages()
+}
+
+func (o *Operator) InspectImage(image string) (*_type.Image, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.InspectImage(image)
+}
+
+// RemoveImage removes an image committed by the agent, the other images of
+// the node are left alone.
+func (o *Operator) RemoveImage(image string) error {
+	client, _, err := o.newClient()
+	if err != nil {
+		return err
+	}
+	img, err := client.InspectImage(image)
+	if err != nil {
+		return err
+	}
+	if _, ok := img.Labels[pkg.SourceImageLabel]; !ok {
+		return fmt.Errorf("%w: %s", errdefs.ErrNotCommittedImage, image)
+	}
+	if err := client.RemoveImage(image); err != nil {
+		return err
+	}
+	log.Infof("removed image %s", image)
+	return nil
+}
+
+// PruneImages removes the committed images created more than maxAge ago
+// which have been pushed, so they can be pulled again when needed. Images
+// that cannot be removed, e.g. because a container uses them, are skipped.
+func (o *Operator) PruneImages(maxAge time.Duration) ([]string, error) {
+	client, _, err := o.newClient()
+	if err != nil {
+		return nil, err
+	}
+	images, err := client.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	deadline := time.Now().Add(-maxAge)
+	for _, img := range images {
+		if !img.Pushed || img.Created.After(deadline) {
+			continue
+		}
+		if err := client.RemoveImage(img.Name); err != nil {
+			log.Warnf("failed to prune image %s: %v", img.Name, err)
+			continue
+		}
+		log.Infof("pruned image %s created at %s", img.Name, img.Created.Format(time.RFC3339))
+		removed = append(removed, img.Name)
+	}
+	return removed, nil
+}
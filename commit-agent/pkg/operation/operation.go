@@ -0,0 +1,257 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package operation
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// Operation tracks a single asynchronous commit or push. It implements
+// _type.Progress so runtime clients can report into it directly.
+type Operation struct {
+	mu         sync.Mutex
+	id         string
+	opType     _type.MessageType
+	image      string
+	phase      v1beta1.Phase
+	layers     map[string]*v1beta1.LayerProgress
+	layerOrder []string
+	result     string
+	err        string
+	code       codes.Code
+	diffSize   int64
+	startTime  time.Time
+	updateTime time.Time
+	// changed is closed and replaced on every update to wake up watchers.
+	changed chan struct{}
+}
+
+func newOperation(opType _type.MessageType, image string) *Operation {
+	now := time.Now()
+	return &Operation{
+		id:         newID(),
+		opType:     opType,
+		image:      image,
+		phase:      v1beta1.Phase_PENDING,
+		layers:     map[string]*v1beta1.LayerProgress{},
+		startTime:  now,
+		updateTime: now,
+		changed:    make(chan struct{}),
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (o *Operation) ID() string {
+	return o.id
+}
+
+// notify must be called with o.mu held.
+func (o *Operation) notify() {
+	o.updateTime = time.Now()
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+func (o *Operation) SetPhase(phase v1beta1.Phase) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.phase == phase {
+		return
+	}
+	o.phase = phase
+	o.notify()
+}
+
+func (o *Operation) SetDiffSize(size int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.diffSize = size
+	o.notify()
+}
+
+func (o *Operation) UpdateLayer(id string, current, total int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	layer := o.layer(id)
+	if layer.Done {
+		return
+	}
+	layer.Current = current
+	if total > 0 {
+		layer.Total = total
+	}
+	o.notify()
+}
+
+func (o *Operation) CompleteLayer(id string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	layer := o.layer(id)
+	if layer.Done {
+		return
+	}
+	layer.Done = true
+	if layer.Total > 0 {
+		layer.Current = layer.Total
+	}
+	o.notify()
+}
+
+// layer must be called with o.mu held.
+func (o *Operation) layer(id string) *v1beta1.LayerProgress {
+	layer, ok := o.layers[id]
+	if !ok {
+		layer = &v1beta1.LayerProgress{Id: id}
+		o.layers[id] = layer
+		o.layerOrder = append(o.layerOrder, id)
+	}
+	return layer
+}
+
+func (o *Operation) finish(result string, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.result = result
+	if err != nil {
+		o.err = err.Error()
+		o.code = errdefs.Code(err)
+		o.phase = v1beta1.Phase_FAILED
+	} else {
+		o.phase = v1beta1.Phase_DONE
+	}
+	o.notify()
+}
+
+// Finished reports whether the operation reached DONE or FAILED.
+func (o *Operation) Finished() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return isTerminal(o.phase)
+}
+
+func isTerminal(phase v1beta1.Phase) bool {
+	return phase == v1beta1.Phase_DONE || phase == v1beta1.Phase_FAILED
+}
+
+// Snapshot returns the current state of the operation and a channel that is
+// closed on the next update.
+func (o *Operation) Snapshot() (*v1beta1.Operation, <-chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	layers := make([]*v1beta1.LayerProgress, 0, len(o.layerOrder))
+	for _, id := range o.layerOrder {
+		l := o.layers[id]
+		layers = append(layers, &v1beta1.LayerProgress{Id: l.Id, Current: l.Current, Total: l.Total, Done: l.Done})
+	}
+	return &v1beta1.Operation{
+		Id:         o.id,
+		Type:       string(o.opType),
+		Image:      o.image,
+		Phase:      o.phase,
+		Layers:     layers,
+		Result:     o.result,
+		Error:      o.err,
+		StartTime:  o.startTime.Unix(),
+		UpdateTime: o.updateTime.Unix(),
+		Code:       int32(o.code),
+		DiffSize:   o.diffSize,
+	}, o.changed
+}
+
+// Watch calls send with every state change of the operation until it
+// finishes or ctx is cancelled.
+func (o *Operation) Watch(ctx context.Context, send func(*v1beta1.Operation) error) error {
+	for {
+		snapshot, changed := o.Snapshot()
+		if err := send(snapshot); err != nil {
+			return err
+		}
+		if isTerminal(snapshot.Phase) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Manager keeps the operations started by the agent. Finished operations are
+// kept for retention so that clients can still query their result.
+type Manager struct {
+	mu         sync.Mutex
+	operations map[string]*Operation
+	retention  time.Duration
+}
+
+func NewManager(retention time.Duration) *Manager {
+	return &Manager{
+		operations: map[string]*Operation{},
+		retention:  retention,
+	}
+}
+
+// Start registers a new operation and runs fn for it in the background.
+func (m *Manager) Start(opType _type.MessageType, image string, fn func(op *Operation) (string, error)) *Operation {
+	op := newOperation(opType, image)
+
+	m.mu.Lock()
+	m.gc()
+	m.operations[op.id] = op
+	m.mu.Unlock()
+
+	go func() {
+		result, err := fn(op)
+		op.finish(result, err)
+	}()
+	return op
+}
+
+func (m *Manager) Get(id string) (*Operation, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	op, ok := m.operations[id]
+	return op, ok
+}
+
+// gc must be called with m.mu held.
+func (m *Manager) gc() {
+	deadline := time.Now().Add(-m.retention)
+	for id, op := range m.operations {
+		op.mu.Lock()
+		expired := isTerminal(op.phase) && op.updateTime.Before(deadline)
+		op.mu.Unlock()
+		if expired {
+			delete(m.operations, id)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+func TestWatchUntilDone(t *testing.T) {
+	m := NewManager(time.Hour)
+	release := make(chan struct{})
+	op := m.Start(_type.TypePush, "registry/ns/image:tag", func(op *Operation) (string, error) {
+		op.SetPhase(v1beta1.Phase_PUSHING)
+		op.UpdateLayer("layer-1", 10, 100)
+		<-release
+		op.CompleteLayer("layer-1")
+		return "pushed", nil
+	})
+
+	if got, ok := m.Get(op.ID()); !ok || got != op {
+		t.Fatalf("operation %s is not registered", op.ID())
+	}
+
+	var phases []v1beta1.Phase
+	var last *v1beta1.Operation
+	err := op.Watch(context.Background(), func(o *v1beta1.Operation) error {
+		phases = append(phases, o.Phase)
+		if o.Phase == v1beta1.Phase_PUSHING && len(o.Layers) == 1 {
+			select {
+			case <-release:
+			default:
+				close(release)
+			}
+		}
+		last = o
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	if last.Phase != v1beta1.Phase_DONE || last.Result != "pushed" {
+		t.Errorf("expected DONE with result, got %s %q", last.Phase, last.Result)
+	}
+	if len(last.Layers) != 1 || !last.Layers[0].Done || last.Layers[0].Current != 100 {
+		t.Errorf("unexpected layer progress: %v", last.Layers)
+	}
+	if phases[len(phases)-1] != v1beta1.Phase_DONE {
+		t.Errorf("expected the last phase to be DONE, got %v", phases)
+	}
+}
+
+func TestFailedOperationIsCollected(t *testing.T) {
+	m := NewManager(0)
+	op := m.Start(_type.TypeCommit, "image", func(op *Operation) (string, error) {
+		return "Container save error", errors.New("boom")
+	})
+	if err := op.Watch(context.Background(), func(*v1beta1.Operation) error { return nil }); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	snapshot, _ := op.Snapshot()
+	if snapshot.Phase != v1beta1.Phase_FAILED || snapshot.Error != "boom" {
+		t.Errorf("expected FAILED with error, got %s %q", snapshot.Phase, snapshot.Error)
+	}
+
+	time.Sleep(time.Millisecond)
+	m.Start(_type.TypeCommit, "image", func(op *Operation) (string, error) { return "", nil })
+	if _, ok := m.Get(op.ID()); ok {
+		t.Errorf("expected finished operation %s to be garbage collected", op.ID())
+	}
+}
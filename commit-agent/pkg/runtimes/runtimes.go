@@ -0,0 +1,168 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package runtimes
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/containerd"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/crio"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/docker"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+)
+
+const (
+	Auto       = "auto"
+	Docker     = "docker"
+	Containerd = "containerd"
+	CRIO       = "cri-o"
+)
+
+// Config tells the agent how to reach the container runtime of the node.
+type Config struct {
+	// Runtime is auto, docker, containerd or cri-o. auto uses the first of
+	// them, in that order, whose socket exists and which answers.
+	Runtime             string `yaml:"runtime"`
+	DockerSocket        string `yaml:"dockerSocket"`
+	ContainerdSocket    string `yaml:"containerdSocket"`
+	ContainerdNamespace string `yaml:"containerdNamespace"`
+	CRIOSocket          string `yaml:"crioSocket"`
+}
+
+// DefaultConfig expects the /run directory of the host under /host/run.
+func DefaultConfig() Config {
+	return Config{
+		Runtime:             Auto,
+		DockerSocket:        "/host/run/docker.sock",
+		ContainerdSocket:    "/host/run/containerd/containerd.sock",
+		ContainerdNamespace: "k8s.io",
+		CRIOSocket:          "/host/run/crio/crio.sock",
+	}
+}
+
+// Environment variables overriding the config file.
+const (
+	RuntimeEnv             = "COMMIT_AGENT_RUNTIME"
+	DockerSocketEnv        = "COMMIT_AGENT_DOCKER_SOCKET"
+	ContainerdSocketEnv    = "COMMIT_AGENT_CONTAINERD_SOCKET"
+	ContainerdNamespaceEnv = "COMMIT_AGENT_CONTAINERD_NAMESPACE"
+	CRIOSocketEnv          = "COMMIT_AGENT_CRIO_SOCKET"
+)
+
+// LoadConfig returns the default config overridden by the YAML file at path,
+// when path is set, and then by the COMMIT_AGENT_* environment variables.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("failed to read runtime config: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("malformed runtime config %s: %w", path, err)
+		}
+	}
+
+	for env, field := range map[string]*string{
+		RuntimeEnv:             &config.Runtime,
+		DockerSocketEnv:        &config.DockerSocket,
+		ContainerdSocketEnv:    &config.ContainerdSocket,
+		ContainerdNamespaceEnv: &config.ContainerdNamespace,
+		CRIOSocketEnv:          &config.CRIOSocket,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*field = v
+		}
+	}
+	return config, config.Validate()
+}
+
+func (c Config) Validate() error {
+	switch c.Runtime {
+	case Auto, Docker, Containerd, CRIO:
+	default:
+		return fmt.Errorf("unknown runtime %q, use auto, docker, containerd or cri-o", c.Runtime)
+	}
+	if c.ContainerdNamespace == "" {
+		return errors.New("the containerd namespace must not be empty")
+	}
+	return nil
+}
+
+// Detect connects to the configured runtime and checks that it answers.
+func Detect(config Config) (_type.ContainerClient, *_type.RuntimeInfo, error) {
+	if config.Runtime != Auto {
+		return connect(config.Runtime, config)
+	}
+
+	var errs []string
+	for _, runtime := range []string{Docker, Containerd, CRIO} {
+		if _, err := os.Stat(socket(runtime, config)); err != nil {
+			continue
+		}
+		client, info, err := connect(runtime, config)
+		if err != nil {
+			log.Warnf("%s does not answer on %s: %v", runtime, socket(runtime, config), err)
+			errs = append(errs, err.Error())
+			continue
+		}
+		return client, info, nil
+	}
+	err := fmt.Errorf("%w at %s, %s or %s", errdefs.ErrRuntimeNotFound, config.DockerSocket, config.ContainerdSocket, config.CRIOSocket)
+	if len(errs) > 0 {
+		err = fmt.Errorf("%w: %s", err, strings.Join(errs, "; "))
+	}
+	return nil, nil, err
+}
+
+func socket(runtime string, config Config) string {
+	switch runtime {
+	case Docker:
+		return config.DockerSocket
+	case Containerd:
+		return config.ContainerdSocket
+	default:
+		return config.CRIOSocket
+	}
+}
+
+func connect(runtime string, config Config) (_type.ContainerClient, *_type.RuntimeInfo, error) {
+	var client _type.ContainerClient
+	var err error
+	switch runtime {
+	case Docker:
+		client, err = docker.NewDockerClient(config.DockerSocket)
+	case Containerd:
+		client, err = containerd.NewContainerdClient(config.ContainerdSocket, config.ContainerdNamespace)
+	default:
+		client, err = crio.NewCRIOClient(config.CRIOSocket)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := client.Info()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s does not answer: %w", runtime, err)
+	}
+	return client, info, nil
+}
@@ -0,0 +1,98 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package runtimes
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	testCases := []struct {
+		testName string
+		file     string
+		env      map[string]string
+		expected Config
+		invalid  bool
+	}{
+		{
+			testName: "defaults",
+			expected: DefaultConfig(),
+		},
+		{
+			testName: "file overrides defaults",
+			file:     "runtime: containerd\ncontainerdSocket: /host/data/containerd/containerd.sock\n",
+			expected: Config{
+				Runtime:             Containerd,
+				DockerSocket:        "/host/run/docker.sock",
+				ContainerdSocket:    "/host/data/containerd/containerd.sock",
+				ContainerdNamespace: "k8s.io",
+				CRIOSocket:          "/host/run/crio/crio.sock",
+			},
+		},
+		{
+			testName: "environment overrides file",
+			file:     "runtime: containerd\ncontainerdNamespace: kubelet\n",
+			env: map[string]string{
+				RuntimeEnv:             CRIO,
+				CRIOSocketEnv:          "/host/var/run/crio/crio.sock",
+				ContainerdNamespaceEnv: "",
+			},
+			expected: Config{
+				Runtime:             CRIO,
+				DockerSocket:        "/host/run/docker.sock",
+				ContainerdSocket:    "/host/run/containerd/containerd.sock",
+				ContainerdNamespace: "kubelet",
+				CRIOSocket:          "/host/var/run/crio/crio.sock",
+			},
+		},
+		{
+			testName: "unknown runtime",
+			env:      map[string]string{RuntimeEnv: "podman"},
+			invalid:  true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if c.file != "" {
+				path = filepath.Join(t.TempDir(), "runtime.yaml")
+				if err := os.WriteFile(path, []byte(c.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config, err := LoadConfig(path)
+			if c.invalid {
+				if err == nil {
+					t.Errorf("expected an error for %+v", config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config != c.expected {
+				t.Errorf("expected %+v, got %+v", c.expected, config)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/auth"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/operation"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// EnableExport lets callers export images to dir, a host path or a volume
+// mounted into the agent.
+func (s *ImageServer) EnableExport(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	s.exportDir = dir
+	log.Infof("exporting images to %s", dir)
+	return nil
+}
+
+func (s *ImageServer) ExportImage(ctx context.Context, request *v1beta1.ExportImageRequest) (*v1beta1.ExportImageResponse, error) {
+	path, err := s.exportPath(ctx, request.Path)
+	if err != nil {
+		return nil, err
+	}
+	op := s.start(ctx, _type.TypeExport, "", request.Image, func(op *operation.Operation) (string, error) {
+		return s.operator.ExportImage(request.Image, path, op)
+	})
+	log.Infof("export operation %s started, image: %s, path: %s", op.ID(), request.Image, path)
+
+	return &v1beta1.ExportImageResponse{
+		Result:      fmt.Sprintf("Image export started, operation: %s", op.ID()),
+		OperationID: op.ID(),
+	}, nil
+}
+
+// exportPath resolves the path of an export inside the export directory. The
+// exports of notebook pods go to a directory named after their namespace.
+func (s *ImageServer) exportPath(ctx context.Context, name string) (string, error) {
+	if s.exportDir == "" {
+		return "", status.Error(codes.FailedPrecondition, "image export is disabled, start the agent with --export-dir")
+	}
+	clean := filepath.Clean(name)
+	if name == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", status.Errorf(codes.InvalidArgument, "export path %q must be relative to the export directory", name)
+	}
+
+	dir := s.exportDir
+	if caller := auth.CallerFromContext(ctx); caller != nil && caller.Namespace() != "" {
+		dir = filepath.Join(dir, caller.Namespace())
+	}
+	full := filepath.Join(dir, clean)
+	rel, err := filepath.Rel(s.exportDir, filepath.Dir(full))
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+	if err := mkdirInside(s.exportDir, rel); err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "export path %q: %v", name, err)
+	}
+	return full, nil
+}
+
+// mkdirInside creates the directories of rel below root without following
+// links, the export directory may be a volume notebooks write to as well.
+func mkdirInside(root, rel string) error {
+	dir := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		if part == "" || part == "." {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+				return err
+			}
+		case err != nil:
+			return err
+		case !info.IsDir():
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	return nil
+}
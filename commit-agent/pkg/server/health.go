@@ -0,0 +1,82 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/metrics"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+)
+
+// StartHealthCheck checks every interval that the container runtime answers
+// and reports the result through the gRPC health service, for the whole
+// agent and for the image service.
+func (s *ImageServer) StartHealthCheck(interval time.Duration) {
+	s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+	go func() {
+		for {
+			_, err := s.operator.RuntimeInfo()
+			if err != nil {
+				log.Warnf("health check failed: %v", err)
+				s.setServingStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+			} else {
+				s.setServingStatus(healthpb.HealthCheckResponse_SERVING)
+			}
+			metrics.SetRuntimeUp(err == nil)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func (s *ImageServer) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	s.health.SetServingStatus("", status)
+	s.health.SetServingStatus(v1beta1.ImageService_ServiceDesc.ServiceName, status)
+}
+
+// StartHTTPServer serves /metrics and /healthz, the latter answers with the
+// status of the gRPC health service for probes that cannot speak gRPC.
+func (s *ImageServer) StartHTTPServer(address string) chan error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", s.healthz)
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	errorChan := make(chan error, 1)
+	go func() {
+		defer close(errorChan)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			errorChan <- err
+		}
+	}()
+	log.Infof("serving metrics and health on %s", address)
+	return errorChan
+}
+
+func (s *ImageServer) healthz(w http.ResponseWriter, r *http.Request) {
+	response, err := s.health.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil || response.Status != healthpb.HealthCheckResponse_SERVING {
+		http.Error(w, "container runtime unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	_, _ = w.Write([]byte("ok"))
+}
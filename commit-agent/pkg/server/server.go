@@ -19,11 +19,27 @@ import (
 	"context"
 	"fmt"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
 	"net"
 	"os"
+	"time"
 
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/audit"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/auth"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/changes"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/credentials"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/errdefs"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/layer"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/metrics"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/operate"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/operation"
+	"github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/runtimes"
+	_type "github.com/AliyunContainerService/data-on-ack/commit-agent/pkg/type"
 	"github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+	"github.com/docker/distribution/reference"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
@@ -32,19 +48,43 @@ const (
 	// Unix Domain Socket
 	netProtocol = "unix"
 	Version     = "0.1.0"
+	// operationRetention is how long finished operations can still be watched.
+	operationRetention = time.Hour
 )
 
 type ImageServer struct {
 	v1beta1.UnimplementedImageServiceServer
 	pathToUnixSocket string
+	operations       *operation.Manager
+	operator         *operate.Operator
+	authorizer       *auth.Authorizer
+	audit            *audit.Logger
+	health           *health.Server
+	// exportDir holds the exported images, exports are disabled when empty.
+	exportDir string
 	net.Listener
 	*grpc.Server
 }
 
-// New creates an instance of the Image Service Server.
-func New(pathToUnixSocketFile string) (*ImageServer, error) {
+// New creates an instance of the Image Service Server. dockerConfigFile is
+// the docker config.json used for registries no referenced secret covers.
+// Denied calls and every commit and push are written to auditLog.
+func New(pathToUnixSocketFile, dockerConfigFile string, runtimeConfig runtimes.Config, authConfig auth.Config, auditLog *audit.Logger) (*ImageServer, error) {
+	if err := runtimeConfig.Validate(); err != nil {
+		return nil, err
+	}
+	operator := operate.NewOperator(dockerConfigFile, runtimeConfig)
+	authorizer, err := auth.NewAuthorizer(authConfig, operator, auditLog)
+	if err != nil {
+		return nil, err
+	}
 	imageServer := &ImageServer{
 		pathToUnixSocket: pathToUnixSocketFile,
+		operations:       operation.NewManager(operationRetention),
+		operator:         operator,
+		authorizer:       authorizer,
+		audit:            auditLog,
+		health:           health.NewServer(),
 	}
 	return imageServer, nil
 }
@@ -60,8 +100,13 @@ func (s *ImageServer) setupRPCServer() error {
 	}
 	s.Listener = listener
 	log.Infof("register unix domain socket: %s", s.pathToUnixSocket)
-	server := grpc.NewServer()
+	server := grpc.NewServer(
+		grpc.Creds(auth.PeerCredentials{}),
+		grpc.UnaryInterceptor(s.authorizer.UnaryInterceptor()),
+		grpc.StreamInterceptor(s.authorizer.StreamInterceptor()),
+	)
 	v1beta1.RegisterImageServiceServer(server, s)
+	healthpb.RegisterHealthServer(server, s.health)
 	s.Server = server
 	return nil
 }
@@ -97,14 +142,224 @@ func (s *ImageServer) Version(ctx context.Context, request *v1beta1.VersionReque
 	return &v1beta1.VersionResponse{Version: Version}, nil
 }
 
+func (s *ImageServer) Info(ctx context.Context, request *v1beta1.InfoRequest) (*v1beta1.InfoResponse, error) {
+	info, err := s.operator.RuntimeInfo()
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return &v1beta1.InfoResponse{
+		Version:        Version,
+		Runtime:        info.Name,
+		RuntimeVersion: info.Version,
+		Endpoint:       info.Endpoint,
+		Namespace:      info.Namespace,
+		Commit:         info.Commit,
+	}, nil
+}
+
 func (s *ImageServer) CommitImage(ctx context.Context, request *v1beta1.CommitRequest) (*v1beta1.CommitResponse, error) {
-	result, err := operate.CommitContainer(request.ContainerID, request.Image)
+	opts, err := commitOptions(request.Options)
+	if err != nil {
+		return nil, err
+	}
+	op := s.start(ctx, _type.TypeCommit, request.ContainerID, request.Image, func(op *operation.Operation) (string, error) {
+		return s.operator.CommitContainer(request.ContainerID, request.Image, opts, op)
+	})
+	log.Infof("commit operation %s started, container: %s, image: %s", op.ID(), request.ContainerID, request.Image)
 
-	return &v1beta1.CommitResponse{Result: result}, err
+	return &v1beta1.CommitResponse{
+		Result:      fmt.Sprintf("Container commit started, operation: %s", op.ID()),
+		OperationID: op.ID(),
+	}, nil
 }
 
 func (s *ImageServer) PushImage(ctx context.Context, request *v1beta1.PushRequest) (*v1beta1.PushResponse, error) {
-	result, err := operate.PushImage(request.Image, request.Username, request.Password)
+	if err := validateTargets(request.Targets); err != nil {
+		return nil, err
+	}
+	op := s.start(ctx, _type.TypePush, request.ContainerID, request.Image, func(op *operation.Operation) (string, error) {
+		return s.operator.PushImage(request.Image, request.Targets, authOptions(request.ContainerID, request.Username, request.Password, request.Auth), op)
+	})
+	log.Infof("push operation %s started, image: %s", op.ID(), request.Image)
+
+	return &v1beta1.PushResponse{
+		Result:      fmt.Sprintf("Image push started, operation: %s", op.ID()),
+		OperationID: op.ID(),
+	}, nil
+}
+
+func (s *ImageServer) CommitAndPush(ctx context.Context, request *v1beta1.CommitAndPushRequest) (*v1beta1.CommitAndPushResponse, error) {
+	opts, err := commitOptions(request.Options)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTargets(request.Targets); err != nil {
+		return nil, err
+	}
+	op := s.start(ctx, _type.TypeCommitAndPush, request.ContainerID, request.Image, func(op *operation.Operation) (string, error) {
+		return s.operator.CommitAndPush(request.ContainerID, request.Image, request.Targets, opts, authOptions(request.ContainerID, request.Username, request.Password, request.Auth), op)
+	})
+	log.Infof("commit and push operation %s started, container: %s, image: %s", op.ID(), request.ContainerID, request.Image)
 
-	return &v1beta1.PushResponse{Result: result}, err
+	return &v1beta1.CommitAndPushResponse{
+		Result:      fmt.Sprintf("Container commit and push started, operation: %s", op.ID()),
+		OperationID: op.ID(),
+	}, nil
+}
+
+// start runs fn as a new operation and records its result in the metrics
+// and the audit log once it finishes.
+func (s *ImageServer) start(ctx context.Context, opType _type.MessageType, containerID, image string, fn func(op *operation.Operation) (string, error)) *operation.Operation {
+	caller := auth.CallerFromContext(ctx)
+	return s.operations.Start(opType, image, func(op *operation.Operation) (string, error) {
+		started := time.Now()
+		result, err := fn(op)
+		elapsed := time.Since(started)
+
+		snapshot, _ := op.Snapshot()
+		metrics.ObserveOperation(string(opType), elapsed, pushedBytes(snapshot.Layers), err)
+
+		event := caller.AuditEvent(string(opType))
+		event.ContainerID = containerID
+		event.Image = image
+		event.OperationID = op.ID()
+		event.Duration = elapsed.Seconds()
+		event.Result = "success"
+		if err != nil {
+			event.Result = "failure"
+			event.Error = err.Error()
+		}
+		s.audit.Log(event)
+		return result, err
+	})
+}
+
+// pushedBytes sums the layers the registry did not have yet.
+func pushedBytes(layers []*v1beta1.LayerProgress) int64 {
+	var total int64
+	for _, l := range layers {
+		if l.Done {
+			total += l.Total
+		}
+	}
+	return total
+}
+
+// commitOptions validates the changes and excludes before the operation starts so that
+// a malformed request fails right away.
+func commitOptions(options *v1beta1.CommitOptions) (_type.CommitOptions, error) {
+	if err := changes.Validate(options.GetChanges()); err != nil {
+		return _type.CommitOptions{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := layer.Validate(options.GetExcludes()); err != nil {
+		return _type.CommitOptions{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if options.GetMaxSize() < 0 {
+		return _type.CommitOptions{}, status.Error(codes.InvalidArgument, "max size must not be negative")
+	}
+	return _type.CommitOptions{
+		Author:   options.GetAuthor(),
+		Message:  options.GetMessage(),
+		Labels:   options.GetLabels(),
+		Changes:  options.GetChanges(),
+		Pause:    options.GetPause(),
+		Excludes: options.GetExcludes(),
+		MaxSize:  options.GetMaxSize(),
+		DryRun:   options.GetDryRun(),
+	}, nil
+}
+
+func validateTargets(targets []string) error {
+	for _, target := range targets {
+		if _, err := reference.ParseNormalizedNamed(target); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid target %q: %v", target, err)
+		}
+	}
+	return nil
+}
+
+func authOptions(containerID, username, password string, auth *v1beta1.RegistryAuth) credentials.Options {
+	if username != "" || password != "" {
+		log.Warnf("the request sends registry credentials in clear text, reference a docker config secret instead")
+	}
+	return credentials.Options{
+		ContainerID:         containerID,
+		Secrets:             auth.GetSecrets(),
+		PodImagePullSecrets: auth.GetPodImagePullSecrets(),
+		Username:            username,
+		Password:            password,
+	}
+}
+
+// WatchOperation streams the operation until it finishes. A failed operation
+// ends the stream with its gRPC status code after the final update was sent.
+func (s *ImageServer) WatchOperation(request *v1beta1.WatchOperationRequest, stream v1beta1.ImageService_WatchOperationServer) error {
+	op, ok := s.operations.Get(request.OperationID)
+	if !ok {
+		return status.Errorf(codes.NotFound, "operation %s not found", request.OperationID)
+	}
+	if err := op.Watch(stream.Context(), stream.Send); err != nil {
+		return err
+	}
+	snapshot, _ := op.Snapshot()
+	if snapshot.Phase == v1beta1.Phase_FAILED {
+		return status.Errorf(codes.Code(snapshot.Code), "%s: %s", snapshot.Result, snapshot.Error)
+	}
+	return nil
+}
+
+func (s *ImageServer) ListImages(ctx context.Context, request *v1beta1.ListImagesRequest) (*v1beta1.ListImagesResponse, error) {
+	images, err := s.operator.ListImages()
+	if err != nil {
+		return nil, status.Error(errdefs.Code(err), err.Error())
+	}
+	response := &v1beta1.ListImagesResponse{}
+	for i := range images {
+		response.Images = append(response.Images, toImage(&images[i]))
+	}
+	return response, nil
+}
+
+func (s *ImageServer) InspectImage(ctx context.Context, request *v1beta1.InspectImageRequest) (*v1beta1.InspectImageResponse, error) {
+	image, err := s.operator.InspectImage(request.Image)
+	if err != nil {
+		return nil, status.Error(errdefs.Code(err), err.Error())
+	}
+	return &v1beta1.InspectImageResponse{Image: toImage(image)}, nil
+}
+
+func (s *ImageServer) RemoveImage(ctx context.Context, request *v1beta1.RemoveImageRequest) (*v1beta1.RemoveImageResponse, error) {
+	if err := s.operator.RemoveImage(request.Image); err != nil {
+		return nil, status.Error(errdefs.Code(err), err.Error())
+	}
+	return &v1beta1.RemoveImageResponse{Result: fmt.Sprintf("Image removed: %s", request.Image)}, nil
+}
+
+func toImage(image *_type.Image) *v1beta1.Image {
+	result := &v1beta1.Image{
+		Name:    image.Name,
+		Id:      image.ID,
+		Created: image.Created.Unix(),
+		Size:    image.Size,
+		Pushed:  image.Pushed,
+		Labels:  image.Labels,
+	}
+	for _, l := range image.Layers {
+		result.Layers = append(result.Layers, &v1beta1.ImageLayer{Digest: l.Digest, Size: l.Size})
+	}
+	return result
+}
+
+// StartImageGC prunes the pushed images committed more than maxAge ago every
+// interval until the agent exits.
+func (s *ImageServer) StartImageGC(maxAge, interval time.Duration) {
+	log.Infof("pruning pushed images older than %s every %s", maxAge, interval)
+	go func() {
+		for {
+			if _, err := s.operator.PruneImages(maxAge); err != nil {
+				log.Errorf("failed to prune images: %v", err)
+			}
+			time.Sleep(interval)
+		}
+	}()
 }
@@ -20,6 +20,10 @@ type MessageType string
 const (
 	TypeCommit MessageType = "Commit"
 	TypePush   MessageType = "Push"
+	// TypeCommitAndPush commits the container and pushes the image in one operation
+	TypeCommitAndPush MessageType = "CommitAndPush"
+	// TypeExport writes an image as an OCI image layout tarball
+	TypeExport MessageType = "Export"
 )
 
 type CommitMessage struct {
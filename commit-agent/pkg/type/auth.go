@@ -0,0 +1,29 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package _type
+
+// AuthConfig holds the credentials used to authenticate against one registry.
+// IdentityToken and RegistryToken take precedence over Username and Password.
+type AuthConfig struct {
+	Username      string
+	Password      string
+	IdentityToken string
+	RegistryToken string
+}
+
+// Credentials returns the credentials for a registry host, the zero value
+// means anonymous access.
+type Credentials func(host string) (AuthConfig, error)
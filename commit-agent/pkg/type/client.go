@@ -15,7 +15,30 @@
 
 package _type
 
+import "io"
+
+// ContainerInfo is the part of the container metadata the agent relies on.
+type ContainerInfo struct {
+	ID     string
+	Image  string
+	Labels map[string]string
+}
+
 type ContainerClient interface {
-	CommitImageFromSelf(containerID, image string) error
-	PushImageFromSelf(image, username, password string) error
+	// Info checks that the runtime answers and describes it.
+	Info() (*RuntimeInfo, error)
+	CommitImageFromSelf(containerID, image string, opts CommitOptions) error
+	PushImageFromSelf(image string, credentials Credentials, progress Progress) error
+	// TagImage adds the target reference to the source image.
+	TagImage(source, target string) error
+	// ExportImage writes the image for the platform of the node as an OCI
+	// image layout tarball.
+	ExportImage(image string, w io.Writer) error
+	// ListImages lists the images committed by the agent.
+	ListImages() ([]Image, error)
+	InspectImage(image string) (*Image, error)
+	RemoveImage(image string) error
+	ContainerInfo(containerID string) (*ContainerInfo, error)
+	// DiffSize returns the size of the writable layer of the container.
+	DiffSize(containerID string) (int64, error)
 }
@@ -0,0 +1,35 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package _type
+
+// CommitOptions are applied to the image created from a container.
+type CommitOptions struct {
+	Author  string
+	Message string
+	Labels  map[string]string
+	// Changes are Dockerfile instructions applied to the image config, see
+	// the changes package for the supported ones.
+	Changes []string
+	// Pause pauses the container while its filesystem is committed.
+	Pause bool
+	// Excludes are .dockerignore style patterns of paths left out of the
+	// committed layer, see the layer package.
+	Excludes []string
+	// MaxSize rejects commits whose layer is larger, in bytes, when positive.
+	MaxSize int64
+	// DryRun only reports the size of the container changes.
+	DryRun bool
+}
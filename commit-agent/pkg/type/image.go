@@ -0,0 +1,36 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package _type
+
+import "time"
+
+// Image is an image on the node, as reported by ListImages and InspectImage.
+type Image struct {
+	Name    string
+	ID      string
+	Created time.Time
+	Size    int64
+	// Pushed tells whether the image was pushed from the node.
+	Pushed bool
+	Labels map[string]string
+	// Layers are only set by InspectImage.
+	Layers []ImageLayer
+}
+
+type ImageLayer struct {
+	Digest string
+	Size   int64
+}
@@ -0,0 +1,27 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package _type
+
+import "github.com/AliyunContainerService/data-on-ack/commit-agent/v1beta1"
+
+// Progress receives the phase and per-layer transfer updates of a long-running
+// commit or push so they can be streamed back to the caller.
+type Progress interface {
+	SetPhase(phase v1beta1.Phase)
+	SetDiffSize(size int64)
+	UpdateLayer(id string, current, total int64)
+	CompleteLayer(id string)
+}
@@ -0,0 +1,28 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+ */
+
+package _type
+
+// RuntimeInfo describes the container runtime the agent talks to.
+type RuntimeInfo struct {
+	// Name is docker, containerd or cri-o.
+	Name     string
+	Version  string
+	Endpoint string
+	// Namespace is the containerd namespace of the kubernetes containers.
+	Namespace string
+	// Commit tells whether the runtime can commit and push images.
+	Commit bool
+}
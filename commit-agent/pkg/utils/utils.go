@@ -61,3 +61,36 @@ func GetContainerID(cgroupContent string) string {
 
 	return tempArr[0]
 }
+
+// ContainerIDFromCgroup returns the id of the container a process with the
+// given /proc/<pid>/cgroup content runs in, for cgroup v1 and v2 layouts of
+// docker, containerd and cri-o. ok is false for processes outside containers.
+func ContainerIDFromCgroup(cgroupContent string) (id string, ok bool) {
+	for _, line := range strings.Split(cgroupContent, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		segments := strings.Split(parts[2], "/")
+		last := strings.TrimSuffix(segments[len(segments)-1], ".scope")
+		if i := strings.LastIndex(last, "-"); i >= 0 {
+			last = last[i+1:]
+		}
+		if isContainerID(last) {
+			return last, true
+		}
+	}
+	return "", false
+}
+
+func isContainerID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -28,6 +28,20 @@ type ImageServiceClient interface {
 	CommitImage(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*CommitResponse, error)
 	// PushImage to ACR
 	PushImage(ctx context.Context, in *PushRequest, opts ...grpc.CallOption) (*PushResponse, error)
+	// CommitAndPush commits the container and pushes the image in one operation
+	CommitAndPush(ctx context.Context, in *CommitAndPushRequest, opts ...grpc.CallOption) (*CommitAndPushResponse, error)
+	// WatchOperation streams the progress of a commit or push operation until it finishes
+	WatchOperation(ctx context.Context, in *WatchOperationRequest, opts ...grpc.CallOption) (ImageService_WatchOperationClient, error)
+	// ListImages lists the images committed on the node
+	ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error)
+	// InspectImage returns the layers and size of an image
+	InspectImage(ctx context.Context, in *InspectImageRequest, opts ...grpc.CallOption) (*InspectImageResponse, error)
+	// RemoveImage removes an image committed on the node
+	RemoveImage(ctx context.Context, in *RemoveImageRequest, opts ...grpc.CallOption) (*RemoveImageResponse, error)
+	// Info reports the container runtime the agent detected
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	// ExportImage writes an image as an OCI image layout tarball to the export directory of the agent
+	ExportImage(ctx context.Context, in *ExportImageRequest, opts ...grpc.CallOption) (*ExportImageResponse, error)
 }
 
 type imageServiceClient struct {
@@ -65,6 +79,92 @@ func (c *imageServiceClient) PushImage(ctx context.Context, in *PushRequest, opt
 	return out, nil
 }
 
+func (c *imageServiceClient) CommitAndPush(ctx context.Context, in *CommitAndPushRequest, opts ...grpc.CallOption) (*CommitAndPushResponse, error) {
+	out := new(CommitAndPushResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/CommitAndPush", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) WatchOperation(ctx context.Context, in *WatchOperationRequest, opts ...grpc.CallOption) (ImageService_WatchOperationClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ImageService_ServiceDesc.Streams[0], "/v1beta1.ImageService/WatchOperation", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &imageServiceWatchOperationClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ImageService_WatchOperationClient interface {
+	Recv() (*Operation, error)
+	grpc.ClientStream
+}
+
+type imageServiceWatchOperationClient struct {
+	grpc.ClientStream
+}
+
+func (x *imageServiceWatchOperationClient) Recv() (*Operation, error) {
+	m := new(Operation)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *imageServiceClient) ListImages(ctx context.Context, in *ListImagesRequest, opts ...grpc.CallOption) (*ListImagesResponse, error) {
+	out := new(ListImagesResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/ListImages", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) InspectImage(ctx context.Context, in *InspectImageRequest, opts ...grpc.CallOption) (*InspectImageResponse, error) {
+	out := new(InspectImageResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/InspectImage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) RemoveImage(ctx context.Context, in *RemoveImageRequest, opts ...grpc.CallOption) (*RemoveImageResponse, error) {
+	out := new(RemoveImageResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/RemoveImage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/Info", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *imageServiceClient) ExportImage(ctx context.Context, in *ExportImageRequest, opts ...grpc.CallOption) (*ExportImageResponse, error) {
+	out := new(ExportImageResponse)
+	err := c.cc.Invoke(ctx, "/v1beta1.ImageService/ExportImage", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ImageServiceServer is the server API for ImageService service.
 // All implementations must embed UnimplementedImageServiceServer
 // for forward compatibility
@@ -75,6 +175,20 @@ type ImageServiceServer interface {
 	CommitImage(context.Context, *CommitRequest) (*CommitResponse, error)
 	// PushImage to ACR
 	PushImage(context.Context, *PushRequest) (*PushResponse, error)
+	// CommitAndPush commits the container and pushes the image in one operation
+	CommitAndPush(context.Context, *CommitAndPushRequest) (*CommitAndPushResponse, error)
+	// WatchOperation streams the progress of a commit or push operation until it finishes
+	WatchOperation(*WatchOperationRequest, ImageService_WatchOperationServer) error
+	// ListImages lists the images committed on the node
+	ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error)
+	// InspectImage returns the layers and size of an image
+	InspectImage(context.Context, *InspectImageRequest) (*InspectImageResponse, error)
+	// RemoveImage removes an image committed on the node
+	RemoveImage(context.Context, *RemoveImageRequest) (*RemoveImageResponse, error)
+	// Info reports the container runtime the agent detected
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	// ExportImage writes an image as an OCI image layout tarball to the export directory of the agent
+	ExportImage(context.Context, *ExportImageRequest) (*ExportImageResponse, error)
 	mustEmbedUnimplementedImageServiceServer()
 }
 
@@ -91,6 +205,27 @@ func (UnimplementedImageServiceServer) CommitImage(context.Context, *CommitReque
 func (UnimplementedImageServiceServer) PushImage(context.Context, *PushRequest) (*PushResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PushImage not implemented")
 }
+func (UnimplementedImageServiceServer) CommitAndPush(context.Context, *CommitAndPushRequest) (*CommitAndPushResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitAndPush not implemented")
+}
+func (UnimplementedImageServiceServer) WatchOperation(*WatchOperationRequest, ImageService_WatchOperationServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchOperation not implemented")
+}
+func (UnimplementedImageServiceServer) ListImages(context.Context, *ListImagesRequest) (*ListImagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListImages not implemented")
+}
+func (UnimplementedImageServiceServer) InspectImage(context.Context, *InspectImageRequest) (*InspectImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InspectImage not implemented")
+}
+func (UnimplementedImageServiceServer) RemoveImage(context.Context, *RemoveImageRequest) (*RemoveImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveImage not implemented")
+}
+func (UnimplementedImageServiceServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedImageServiceServer) ExportImage(context.Context, *ExportImageRequest) (*ExportImageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportImage not implemented")
+}
 func (UnimplementedImageServiceServer) mustEmbedUnimplementedImageServiceServer() {}
 
 // UnsafeImageServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -158,6 +293,135 @@ func _ImageService_PushImage_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ImageService_CommitAndPush_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitAndPushRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).CommitAndPush(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/CommitAndPush",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).CommitAndPush(ctx, req.(*CommitAndPushRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_WatchOperation_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchOperationRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ImageServiceServer).WatchOperation(m, &imageServiceWatchOperationServer{stream})
+}
+
+type ImageService_WatchOperationServer interface {
+	Send(*Operation) error
+	grpc.ServerStream
+}
+
+type imageServiceWatchOperationServer struct {
+	grpc.ServerStream
+}
+
+func (x *imageServiceWatchOperationServer) Send(m *Operation) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ImageService_ListImages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListImagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).ListImages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/ListImages",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).ListImages(ctx, req.(*ListImagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_InspectImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).InspectImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/InspectImage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).InspectImage(ctx, req.(*InspectImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_RemoveImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).RemoveImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/RemoveImage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).RemoveImage(ctx, req.(*RemoveImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/Info",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ImageService_ExportImage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportImageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ImageServiceServer).ExportImage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/v1beta1.ImageService/ExportImage",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ImageServiceServer).ExportImage(ctx, req.(*ExportImageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ImageService_ServiceDesc is the grpc.ServiceDesc for ImageService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -177,7 +441,37 @@ var ImageService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PushImage",
 			Handler:    _ImageService_PushImage_Handler,
 		},
+		{
+			MethodName: "CommitAndPush",
+			Handler:    _ImageService_CommitAndPush_Handler,
+		},
+		{
+			MethodName: "ListImages",
+			Handler:    _ImageService_ListImages_Handler,
+		},
+		{
+			MethodName: "InspectImage",
+			Handler:    _ImageService_InspectImage_Handler,
+		},
+		{
+			MethodName: "RemoveImage",
+			Handler:    _ImageService_RemoveImage_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _ImageService_Info_Handler,
+		},
+		{
+			MethodName: "ExportImage",
+			Handler:    _ImageService_ExportImage_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchOperation",
+			Handler:       _ImageService_WatchOperation_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "service.proto",
 }
@@ -21,9 +21,40 @@ limitations under the License.
 package v1
 
 import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CullingPolicy) DeepCopyInto(out *CullingPolicy) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.MaxLifetime != nil {
+		in, out := &in.MaxLifetime, &out.MaxLifetime
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CullingPolicy.
+func (in *CullingPolicy) DeepCopy() *CullingPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CullingPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Notebook) DeepCopyInto(out *Notebook) {
 	*out = *in
@@ -99,10 +130,40 @@ func (in *NotebookList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotebookSchedule) DeepCopyInto(out *NotebookSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookSchedule.
+func (in *NotebookSchedule) DeepCopy() *NotebookSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(NotebookSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NotebookSpec) DeepCopyInto(out *NotebookSpec) {
 	*out = *in
 	in.Template.DeepCopyInto(&out.Template)
+	if in.Culling != nil {
+		in, out := &in.Culling, &out.Culling
+		*out = new(CullingPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(NotebookSchedule)
+		**out = **in
+	}
+	if in.Workspace != nil {
+		in, out := &in.Workspace, &out.Workspace
+		*out = new(WorkspaceVolume)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookSpec.
@@ -126,6 +187,35 @@ func (in *NotebookStatus) DeepCopyInto(out *NotebookStatus) {
 		}
 	}
 	in.ContainerState.DeepCopyInto(&out.ContainerState)
+	if in.CullingScheduledAt != nil {
+		in, out := &in.CullingScheduledAt, &out.CullingScheduledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.CullingSnoozedUntil != nil {
+		in, out := &in.CullingSnoozedUntil, &out.CullingSnoozedUntil
+		*out = (*in).DeepCopy()
+	}
+	if in.StoppedAt != nil {
+		in, out := &in.StoppedAt, &out.StoppedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Transitions != nil {
+		in, out := &in.Transitions, &out.Transitions
+		*out = make([]NotebookTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.UnschedulableReasons != nil {
+		in, out := &in.UnschedulableReasons, &out.UnschedulableReasons
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NextScheduledTransition != nil {
+		in, out := &in.NextScheduledTransition, &out.NextScheduledTransition
+		*out = new(NotebookTransition)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookStatus.
@@ -153,3 +243,40 @@ func (in *NotebookTemplateSpec) DeepCopy() *NotebookTemplateSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotebookTransition) DeepCopyInto(out *NotebookTransition) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotebookTransition.
+func (in *NotebookTransition) DeepCopy() *NotebookTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(NotebookTransition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkspaceVolume) DeepCopyInto(out *WorkspaceVolume) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkspaceVolume.
+func (in *WorkspaceVolume) DeepCopy() *WorkspaceVolume {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkspaceVolume)
+	in.DeepCopyInto(out)
+	return out
+}
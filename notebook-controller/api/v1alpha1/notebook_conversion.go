@@ -25,8 +25,66 @@ import (
 func (src *Notebook) ConvertTo(dstRaw conversion.Hub) error {
 	dst := dstRaw.(*nbv1beta1.Notebook)
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
+	dst.Spec.Culling = nil
+	if src.Spec.Culling != nil {
+		dst.Spec.Culling = &nbv1beta1.CullingPolicy{
+			Enabled:     src.Spec.Culling.Enabled,
+			IdleTimeout: src.Spec.Culling.IdleTimeout,
+			MaxLifetime: src.Spec.Culling.MaxLifetime,
+		}
+	}
+	dst.Spec.Schedule = nil
+	if src.Spec.Schedule != nil {
+		dst.Spec.Schedule = &nbv1beta1.NotebookSchedule{
+			Start:    src.Spec.Schedule.Start,
+			Stop:     src.Spec.Schedule.Stop,
+			TimeZone: src.Spec.Schedule.TimeZone,
+		}
+	}
+	dst.Spec.Workspace = nil
+	if w := src.Spec.Workspace; w != nil {
+		dst.Spec.Workspace = &nbv1beta1.WorkspaceVolume{
+			Size:             w.Size,
+			StorageClassName: w.StorageClassName,
+			AccessMode:       w.AccessMode,
+			MountPath:        w.MountPath,
+			RetentionPolicy:  w.RetentionPolicy,
+		}
+	}
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Status.CullingScheduledAt = src.Status.CullingScheduledAt
+	dst.Status.CullingReason = src.Status.CullingReason
+	dst.Status.CullingSnoozedUntil = src.Status.CullingSnoozedUntil
+	dst.Status.StoppedAt = src.Status.StoppedAt
+	dst.Status.StopReason = src.Status.StopReason
+	transitions := []nbv1beta1.NotebookTransition{}
+	for _, t := range src.Status.Transitions {
+		transitions = append(transitions, nbv1beta1.NotebookTransition{
+			Type:    t.Type,
+			Time:    t.Time,
+			Reason:  t.Reason,
+			Message: t.Message,
+		})
+	}
+	dst.Status.Transitions = transitions
+	dst.Status.PodPhase = src.Status.PodPhase
+	dst.Status.NodeName = src.Status.NodeName
+	dst.Status.UnschedulableReasons = src.Status.UnschedulableReasons
+	dst.Status.SchedulingMessage = src.Status.SchedulingMessage
+	dst.Status.ImagePullState = src.Status.ImagePullState
+	dst.Status.ImagePullMessage = src.Status.ImagePullMessage
+	dst.Status.URL = src.Status.URL
+	dst.Status.GPUSeconds = src.Status.GPUSeconds
+	dst.Status.NextScheduledTransition = nil
+	if t := src.Status.NextScheduledTransition; t != nil {
+		dst.Status.NextScheduledTransition = &nbv1beta1.NotebookTransition{
+			Type:    t.Type,
+			Time:    t.Time,
+			Reason:  t.Reason,
+			Message: t.Message,
+		}
+	}
 	conditions := []nbv1beta1.NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := nbv1beta1.NotebookCondition{
@@ -51,8 +109,66 @@ Most of the conversion is straightforward copying, except for converting our cha
 func (dst *Notebook) ConvertFrom(srcRaw conversion.Hub) error {
 	src := srcRaw.(*nbv1beta1.Notebook)
 	dst.Spec.Template.Spec = src.Spec.Template.Spec
+	dst.Spec.Culling = nil
+	if src.Spec.Culling != nil {
+		dst.Spec.Culling = &CullingPolicy{
+			Enabled:     src.Spec.Culling.Enabled,
+			IdleTimeout: src.Spec.Culling.IdleTimeout,
+			MaxLifetime: src.Spec.Culling.MaxLifetime,
+		}
+	}
+	dst.Spec.Schedule = nil
+	if src.Spec.Schedule != nil {
+		dst.Spec.Schedule = &NotebookSchedule{
+			Start:    src.Spec.Schedule.Start,
+			Stop:     src.Spec.Schedule.Stop,
+			TimeZone: src.Spec.Schedule.TimeZone,
+		}
+	}
+	dst.Spec.Workspace = nil
+	if w := src.Spec.Workspace; w != nil {
+		dst.Spec.Workspace = &WorkspaceVolume{
+			Size:             w.Size,
+			StorageClassName: w.StorageClassName,
+			AccessMode:       w.AccessMode,
+			MountPath:        w.MountPath,
+			RetentionPolicy:  w.RetentionPolicy,
+		}
+	}
 	dst.Status.ReadyReplicas = src.Status.ReadyReplicas
 	dst.Status.ContainerState = src.Status.ContainerState
+	dst.Status.CullingScheduledAt = src.Status.CullingScheduledAt
+	dst.Status.CullingReason = src.Status.CullingReason
+	dst.Status.CullingSnoozedUntil = src.Status.CullingSnoozedUntil
+	dst.Status.StoppedAt = src.Status.StoppedAt
+	dst.Status.StopReason = src.Status.StopReason
+	transitions := []NotebookTransition{}
+	for _, t := range src.Status.Transitions {
+		transitions = append(transitions, NotebookTransition{
+			Type:    t.Type,
+			Time:    t.Time,
+			Reason:  t.Reason,
+			Message: t.Message,
+		})
+	}
+	dst.Status.Transitions = transitions
+	dst.Status.PodPhase = src.Status.PodPhase
+	dst.Status.NodeName = src.Status.NodeName
+	dst.Status.UnschedulableReasons = src.Status.UnschedulableReasons
+	dst.Status.SchedulingMessage = src.Status.SchedulingMessage
+	dst.Status.ImagePullState = src.Status.ImagePullState
+	dst.Status.ImagePullMessage = src.Status.ImagePullMessage
+	dst.Status.URL = src.Status.URL
+	dst.Status.GPUSeconds = src.Status.GPUSeconds
+	dst.Status.NextScheduledTransition = nil
+	if t := src.Status.NextScheduledTransition; t != nil {
+		dst.Status.NextScheduledTransition = &NotebookTransition{
+			Type:    t.Type,
+			Time:    t.Time,
+			Reason:  t.Reason,
+			Message: t.Message,
+		}
+	}
 	conditions := []NotebookCondition{}
 	for _, c := range src.Status.Conditions {
 		newc := NotebookCondition{
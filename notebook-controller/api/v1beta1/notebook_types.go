@@ -17,6 +17,7 @@ package v1beta1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -28,6 +29,71 @@ type NotebookSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Template NotebookTemplateSpec `json:"template,omitempty"`
+	// Culling overrides the culling settings of the controller and of the
+	// namespace for this notebook.
+	// +optional
+	Culling *CullingPolicy `json:"culling,omitempty"`
+	// Schedule stops and starts the notebook at set times, whether it is
+	// idle or not.
+	// +optional
+	Schedule *NotebookSchedule `json:"schedule,omitempty"`
+	// Workspace is a volume the controller provisions for the notebook and
+	// mounts into its container.
+	// +optional
+	Workspace *WorkspaceVolume `json:"workspace,omitempty"`
+}
+
+// WorkspaceVolume is the template of the PersistentVolumeClaim of the
+// workspace of a notebook, named <notebook>-workspace.
+type WorkspaceVolume struct {
+	// Size of the volume, e.g. 10Gi. Growing it expands the volume when its
+	// storage class allows volume expansion, it cannot shrink.
+	Size resource.Quantity `json:"size"`
+	// StorageClassName of the volume. Defaults to the default storage class.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessMode of the volume. Defaults to ReadWriteOnce.
+	// +optional
+	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+	// MountPath of the volume in the notebook container. Defaults to /home/jovyan.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+	// RetentionPolicy is what happens to the volume when the notebook is
+	// deleted, Retain or Delete. Defaults to Retain.
+	// +optional
+	RetentionPolicy string `json:"retentionPolicy,omitempty"`
+}
+
+// CullingPolicy controls when an idle or long running notebook is stopped.
+// Unset fields fall back to the namespace defaults and then to the controller
+// settings.
+type CullingPolicy struct {
+	// Enabled opts the notebook in or out of culling.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+	// IdleTimeout is how long the notebook may stay idle before it is stopped, e.g. 1h.
+	// +optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+	// MaxLifetime stops the notebook once its pod has been running this long,
+	// whether it is idle or not. Zero means no limit.
+	// +optional
+	MaxLifetime *metav1.Duration `json:"maxLifetime,omitempty"`
+}
+
+// NotebookSchedule stops and starts a notebook on cron schedules, e.g. a stop
+// at "0 20 * * 1-5" and a start at "0 8 * * 1-5" for workdays.
+type NotebookSchedule struct {
+	// Start is the cron expression of when the notebook is started:
+	// minute, hour, day of month, month and day of week.
+	// +optional
+	Start string `json:"start,omitempty"`
+	// Stop is the cron expression of when the notebook is stopped.
+	// +optional
+	Stop string `json:"stop,omitempty"`
+	// TimeZone the cron expressions are evaluated in, e.g. Asia/Shanghai.
+	// Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
 }
 
 type NotebookTemplateSpec struct {
@@ -42,10 +108,61 @@ type NotebookStatus struct {
 	ReadyReplicas int32 `json:"readyReplicas"`
 	// ContainerState is the state of underlying container.
 	ContainerState corev1.ContainerState `json:"containerState"`
+	// CullingScheduledAt is when the culler will stop the notebook. It is set
+	// when the notebook is warned and cleared once it is stopped or in use again.
+	// +optional
+	CullingScheduledAt *metav1.Time `json:"cullingScheduledAt,omitempty"`
+	// CullingReason is why the notebook is scheduled for culling, Idle or MaxLifetime.
+	// +optional
+	CullingReason string `json:"cullingReason,omitempty"`
+	// CullingSnoozedUntil is the time until which a user postponed culling.
+	// +optional
+	CullingSnoozedUntil *metav1.Time `json:"cullingSnoozedUntil,omitempty"`
+	// StoppedAt is when the notebook was stopped. It is cleared once the
+	// notebook is resumed.
+	// +optional
+	StoppedAt *metav1.Time `json:"stoppedAt,omitempty"`
+	// StopReason is who stopped the notebook, User, Culled or Scheduled.
+	// +optional
+	StopReason string `json:"stopReason,omitempty"`
+	// Transitions are the last stop and resume transitions of the notebook, newest first.
+	// +optional
+	Transitions []NotebookTransition `json:"transitions,omitempty"`
+	// PodPhase is the phase of the pod of the notebook.
+	// +optional
+	PodPhase corev1.PodPhase `json:"podPhase,omitempty"`
+	// NodeName is the node the pod of the notebook is scheduled on.
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+	// UnschedulableReasons are why the pod cannot be scheduled, e.g.
+	// InsufficientGPU, Taints or PVCPending.
+	// +optional
+	UnschedulableReasons []string `json:"unschedulableReasons,omitempty"`
+	// SchedulingMessage is the message of the scheduler while the pod cannot be scheduled.
+	// +optional
+	SchedulingMessage string `json:"schedulingMessage,omitempty"`
+	// ImagePullState is Pulling, Pulled or Failed.
+	// +optional
+	ImagePullState string `json:"imagePullState,omitempty"`
+	// ImagePullMessage is the progress or the error of the image pull.
+	// +optional
+	ImagePullMessage string `json:"imagePullMessage,omitempty"`
+	// URL is where the notebook is routed to, empty when it is not routed.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// GPUSeconds is the GPU time the notebook used before it was last
+	// stopped. The current run is added to it when the notebook is stopped.
+	// +optional
+	GPUSeconds int64 `json:"gpuSeconds,omitempty"`
+	// NextScheduledTransition is the next stop or start of the notebook by its schedule.
+	// +optional
+	NextScheduledTransition *NotebookTransition `json:"nextScheduledTransition,omitempty"`
 }
 
 type NotebookCondition struct {
 	// Type is the type of the condition. Possible values are Running|Waiting|Terminated
+	// for the container, Stopped|Culled|Starting when the notebook is stopped or resumed, and
+	// CullingScheduled|CullingSnoozed while the culler wants to stop it.
 	Type string `json:"type"`
 	// Last time we probed the condition.
 	// +optional
@@ -58,6 +175,20 @@ type NotebookCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+// NotebookTransition records a notebook being stopped or resumed.
+type NotebookTransition struct {
+	// Type is Stopped or Resumed.
+	Type string `json:"type"`
+	// Time of the transition.
+	Time metav1.Time `json:"time"`
+	// Reason is who stopped the notebook, User, Culled or Scheduled.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Message regarding the transition, e.g. why the notebook was culled.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
@@ -17,10 +17,11 @@ package controllers
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
 	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/culler"
@@ -53,6 +54,7 @@ const AnnotationHeadersRequestSet = "notebooks.kubeflow.org/http-headers-request
 const PrefixEnvVar = "NB_PREFIX"
 
 const DefaultFSGroup = int64(100)
+const DefaultWorkingDir = "/home/jovyan"
 
 func ignoreNotFound(err error) error {
 	if apierrs.IsNotFound(err) {
@@ -67,6 +69,10 @@ type NotebookReconciler struct {
 	Scheme        *runtime.Scheme
 	Metrics       *metrics.Metrics
 	EventRecorder record.EventRecorder
+
+	// routesCleanedUp holds the notebooks whose routes of the other routing
+	// modes were deleted since the controller started.
+	routesCleanedUp sync.Map
 }
 
 func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
@@ -94,6 +100,11 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		log.Info("Emitting Notebook Event.", "Event", event)
 		r.EventRecorder.Eventf(involvedNotebook, event.Type, event.Reason,
 			"Reissued from %s/%s: %s", strings.ToLower(event.InvolvedObject.Kind), event.InvolvedObject.Name, event.Message)
+		if updateEventStatus(&involvedNotebook.Status, event) {
+			if err := r.Status().Update(ctx, involvedNotebook); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		return ctrl.Result{}, nil
 	}
 
@@ -107,13 +118,33 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	if len(instance.Spec.Template.Spec.Containers) == 0 {
+		log.Info("Notebook has no container, skipping it")
+		r.EventRecorder.Event(instance, corev1.EventTypeWarning, "InvalidNotebook",
+			"spec.template.spec.containers must have at least one container")
+		return ctrl.Result{}, nil
+	}
+
+	scheduleRequeue, err := r.reconcileSchedule(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileStopState(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileWorkspace(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	ss := generateStatefulSet(instance)
 	if err := ctrl.SetControllerReference(instance, ss, r.Scheme); err != nil {
 		return ctrl.Result{}, err
 	}
 	foundStateful := &appsv1.StatefulSet{}
 	justCreated := false
-	err := r.Get(ctx, types.NamespacedName{Name: ss.Name, Namespace: ss.Namespace}, foundStateful)
+	err = r.Get(ctx, types.NamespacedName{Name: ss.Name, Namespace: ss.Namespace}, foundStateful)
 	if err != nil && apierrs.IsNotFound(err) {
 		log.Info("Creating StatefulSet", "namespace", ss.Namespace, "name", ss.Name)
 		r.Metrics.NotebookCreation.WithLabelValues(ss.Namespace).Inc()
@@ -165,16 +196,15 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
-	if os.Getenv("USE_ISTIO") == "true" {
-		err = r.reconcileVirtualService(instance)
-		if err != nil {
-			return ctrl.Result{}, err
-		}
+	if err := r.reconcileRoutes(instance); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	if foundStateful.Status.ReadyReplicas != instance.Status.ReadyReplicas {
+	url := notebookURL(instance)
+	if foundStateful.Status.ReadyReplicas != instance.Status.ReadyReplicas || instance.Status.URL != url {
 		log.Info("Updating Status", "namespace", instance.Namespace, "name", instance.Name)
 		instance.Status.ReadyReplicas = foundStateful.Status.ReadyReplicas
+		instance.Status.URL = url
 		err = r.Status().Update(ctx, instance)
 		if err != nil {
 			return ctrl.Result{}, err
@@ -191,52 +221,51 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	} else {
 		podFound = true
 
-		if len(pod.Status.ContainerStatuses) > 0 {
-			notebookContainerFound := false
-			for i := range pod.Status.ContainerStatuses {
-				if pod.Status.ContainerStatuses[i].Name != instance.Name {
-					continue
-				}
-				if pod.Status.ContainerStatuses[i].State == instance.Status.ContainerState {
-					continue
-				}
-
-				log.Info("Updating Notebook CR state: ", "namespace", instance.Namespace, "name", instance.Name)
-				cs := pod.Status.ContainerStatuses[i].State
-				instance.Status.ContainerState = cs
-				oldConditions := instance.Status.Conditions
-				newCondition := getNextCondition(cs)
-				if len(oldConditions) == 0 || oldConditions[0].Type != newCondition.Type ||
-					oldConditions[0].Reason != newCondition.Reason ||
-					oldConditions[0].Message != newCondition.Message {
-					log.Info("Appending to conditions: ", "namespace", instance.Namespace, "name", instance.Name, "type", newCondition.Type, "reason", newCondition.Reason, "message", newCondition.Message)
-					instance.Status.Conditions = append([]v1beta1.NotebookCondition{newCondition}, oldConditions...)
-				}
-				err = r.Status().Update(ctx, instance)
-				if err != nil {
-					return ctrl.Result{}, err
-				}
-				notebookContainerFound = true
-				break
-
+		statusChanged := updatePodStatus(&instance.Status, pod, instance.Name)
+
+		// While a notebook is stopped its pod is going away, keep the
+		// Stopped or Culled condition on top until it is resumed.
+		// The container named after the notebook is preferred, the first
+		// container is used when none is.
+		cs := notebookContainerStatus(pod, instance.Name)
+		if cs != nil && cs.State != instance.Status.ContainerState && !culler.StopAnnotationIsSet(instance.ObjectMeta) {
+			log.Info("Updating Notebook CR state: ", "namespace", instance.Namespace, "name", instance.Name)
+			instance.Status.ContainerState = cs.State
+			oldConditions := instance.Status.Conditions
+			newCondition := getNextCondition(cs.State)
+			if len(oldConditions) == 0 || oldConditions[0].Type != newCondition.Type ||
+				oldConditions[0].Reason != newCondition.Reason ||
+				oldConditions[0].Message != newCondition.Message {
+				log.Info("Appending to conditions: ", "namespace", instance.Namespace, "name", instance.Name, "type", newCondition.Type, "reason", newCondition.Reason, "message", newCondition.Message)
+				instance.Status.Conditions = append([]v1beta1.NotebookCondition{newCondition}, oldConditions...)
 			}
-			if !notebookContainerFound {
-				log.Error(nil, "Could not find the Notebook container, will not update the status of the CR. No container has the same name as the CR.", "CR name:", instance.Name)
+			statusChanged = true
+		}
+		if statusChanged {
+			err = r.Status().Update(ctx, instance)
+			if err != nil {
+				return ctrl.Result{}, err
 			}
 		}
 	}
 
 	if !podFound {
+		if clearPodStatus(&instance.Status) {
+			if err := r.Status().Update(ctx, instance); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
 		log.Info("Notebook has not Pod running. Will remove last-activity annotation")
 		meta := instance.ObjectMeta
 		if meta.GetAnnotations() == nil {
 			log.Info("No annotations found")
-			return ctrl.Result{}, nil
+			return requeueBefore(ctrl.Result{}, scheduleRequeue), nil
 		}
 
 		if _, ok := meta.GetAnnotations()[culler.LAST_ACTIVITY_ANNOTATION]; !ok {
 			log.Info("No last-activity annotations found")
-			return ctrl.Result{}, nil
+			return requeueBefore(ctrl.Result{}, scheduleRequeue), nil
 		}
 
 		log.Info("Removing last-activity annotation")
@@ -245,31 +274,213 @@ func (r *NotebookReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		if err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{}, nil
+		return requeueBefore(ctrl.Result{}, scheduleRequeue), nil
 
 	}
-	if culler.UpdateNotebookLastActivityAnnotation(&instance.ObjectMeta) {
+	if culler.UpdateNotebookLastActivityAnnotation(&instance.ObjectMeta, pod) {
 		err = r.Update(ctx, instance)
 		if err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	if culler.NotebookNeedsCulling(instance.ObjectMeta) {
-		log.Info(fmt.Sprintf(
-			"Notebook %s/%s needs culling. Setting annotations",
-			instance.Namespace, instance.Name))
+	result, err := r.reconcileCulling(ctx, instance, pod)
+	return requeueBefore(result, scheduleRequeue), err
+}
 
-		culler.SetStopAnnotation(&instance.ObjectMeta, r.Metrics)
-		r.Metrics.NotebookCullingCount.WithLabelValues(instance.Namespace, instance.Name).Inc()
-		err = r.Update(ctx, instance)
+// reconcileStopState records in the status of the notebook that it was
+// stopped or resumed. Resuming drops the annotations of the stopped notebook,
+// the StatefulSet is then scaled back up by the rest of the reconcile.
+func (r *NotebookReconciler) reconcileStopState(ctx context.Context, instance *v1beta1.Notebook) error {
+	log := r.Log.WithValues("notebook", types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	switch culler.NextStopTransition(instance.ObjectMeta, &instance.Status) {
+	case culler.TRANSITION_STOPPED:
+		markStopped(instance)
+		log.Info(fmt.Sprintf("Notebook stopped, reason: %s", instance.Status.StopReason))
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, "Stopped",
+			"Notebook stopped, reason: %s", instance.Status.StopReason)
+
+	case culler.TRANSITION_RESUMED:
+		log.Info("Notebook resumed. Scaling it back up")
+		if culler.ResetAnnotationsForResume(&instance.ObjectMeta) {
+			if err := r.Update(ctx, instance); err != nil {
+				return err
+			}
+		}
+		culler.MarkResumed(&instance.Status, time.Now())
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return err
+		}
+		r.EventRecorder.Event(instance, corev1.EventTypeNormal, "Resumed", "Notebook resumed")
+	}
+	return nil
+}
+
+// reconcileSchedule stops or starts the notebook when its schedule says so,
+// through the stop annotation, and records the next scheduled transition in
+// its status. It returns how long until that transition.
+func (r *NotebookReconciler) reconcileSchedule(ctx context.Context, instance *v1beta1.Notebook) (time.Duration, error) {
+	log := r.Log.WithValues("notebook", types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	var next *v1beta1.NotebookTransition
+	if instance.Spec.Schedule != nil {
+		schedule, err := culler.ParseSchedule(instance.Spec.Schedule)
 		if err != nil {
+			log.Info(fmt.Sprintf("Invalid schedule: %v", err))
+			r.EventRecorder.Eventf(instance, corev1.EventTypeWarning, "InvalidSchedule", "Invalid schedule: %v", err)
+		} else {
+			now := time.Now()
+			transition := schedule.DueTransition(instance.Status.NextScheduledTransition, now)
+			if culler.SetScheduledTransitionAnnotations(&instance.ObjectMeta, transition) {
+				log.Info(fmt.Sprintf("Scheduled transition: %s", transition))
+				if err := r.Update(ctx, instance); err != nil {
+					return 0, err
+				}
+				if transition == culler.TRANSITION_STOPPED {
+					r.EventRecorder.Event(instance, corev1.EventTypeNormal, "ScheduledStop", "Notebook stopped by its schedule")
+				} else {
+					r.EventRecorder.Event(instance, corev1.EventTypeNormal, "ScheduledStart", "Notebook started by its schedule")
+				}
+			}
+			next = schedule.Next(now)
+		}
+	}
+
+	if !culler.ScheduledTransitionsEqual(next, instance.Status.NextScheduledTransition) {
+		instance.Status.NextScheduledTransition = next
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return 0, err
+		}
+	}
+	if next == nil {
+		return 0, nil
+	}
+	return time.Until(next.Time.Time), nil
+}
+
+// requeueBefore makes a result requeue no later than after, when after is set.
+func requeueBefore(result ctrl.Result, after time.Duration) ctrl.Result {
+	if after > 0 && (result.RequeueAfter == 0 || after < result.RequeueAfter) {
+		result.RequeueAfter = after
+	}
+	return result
+}
+
+// markStopped records the stop in the status of the notebook, with the GPU
+// time of the run it ends.
+func markStopped(instance *v1beta1.Notebook) {
+	runningSince := metrics.RunningSince(instance)
+	culler.MarkStopped(instance.ObjectMeta, &instance.Status, time.Now())
+	instance.Status.GPUSeconds += metrics.GPUSeconds(instance, runningSince, instance.Status.StoppedAt.Time)
+}
+
+// reconcileCulling warns the users of a notebook the culling policy wants to
+// stop, and stops it once the grace period is over unless it is snoozed or in
+// use again.
+func (r *NotebookReconciler) reconcileCulling(ctx context.Context, instance *v1beta1.Notebook, pod *corev1.Pod) (ctrl.Result, error) {
+	log := r.Log.WithValues("notebook", types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	policy, err := r.cullingPolicy(ctx, instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if snooze, ok := culler.SnoozeRequested(instance.ObjectMeta); ok {
+		log.Info(fmt.Sprintf("Snoozing culling for %s", snooze))
+		delete(instance.Annotations, culler.SNOOZE_ANNOTATION)
+		if err := r.Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		until := metav1.NewTime(time.Now().Add(snooze))
+		culler.MarkCullingSnoozed(&instance.Status, until, time.Now())
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Metrics.NotebookCullingEvents.WithLabelValues(instance.Namespace, instance.Name, culler.CULLING_ACTION_SNOOZE).Inc()
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, "CullingSnoozed",
+			"Culling postponed until %s", until.Format(time.RFC3339))
+		return ctrl.Result{RequeueAfter: policy.RequeueAfter(pod.Status.StartTime)}, nil
+	}
+
+	reason := policy.CullingReason(instance.ObjectMeta, pod.Status.StartTime)
+	requeue := policy.RequeueAfter(pod.Status.StartTime)
+	action, wait := policy.NextCullingAction(reason, &instance.Status, time.Now())
+	switch action {
+	case culler.CULLING_ACTION_WARN:
+		scheduledAt := metav1.NewTime(time.Now().Add(wait))
+		log.Info(fmt.Sprintf(
+			"Notebook %s/%s needs culling (%s). Scheduling it at %s",
+			instance.Namespace, instance.Name, reason, scheduledAt.Format(time.RFC3339)))
+		culler.MarkCullingScheduled(&instance.Status, reason, scheduledAt, time.Now())
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Metrics.NotebookCullingEvents.WithLabelValues(instance.Namespace, instance.Name, action).Inc()
+		r.EventRecorder.Eventf(instance, corev1.EventTypeWarning, "CullingScheduled",
+			"Notebook will be stopped at %s (%s), save your work or set the %s annotation to postpone it",
+			scheduledAt.Format(time.RFC3339), reason, culler.SNOOZE_ANNOTATION)
+		return ctrl.Result{RequeueAfter: minDuration(wait, requeue)}, nil
+
+	case culler.CULLING_ACTION_WAIT:
+		return ctrl.Result{RequeueAfter: minDuration(wait, requeue)}, nil
+
+	case culler.CULLING_ACTION_CANCEL:
+		log.Info("Notebook is in use again. Cancelling culling")
+		culler.ClearCullingStatus(&instance.Status)
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.EventRecorder.Event(instance, corev1.EventTypeNormal, "CullingCancelled", "Notebook is in use again")
+
+	case culler.CULLING_ACTION_CULL:
+		log.Info(fmt.Sprintf(
+			"Notebook %s/%s needs culling (%s). Setting annotations",
+			instance.Namespace, instance.Name, reason))
+
+		culler.SetCullingStopAnnotations(&instance.ObjectMeta, reason, r.Metrics)
+		if err := r.Update(ctx, instance); err != nil {
 			return ctrl.Result{}, err
 		}
-	} else if !culler.StopAnnotationIsSet(instance.ObjectMeta) {
+		markStopped(instance)
+		if err := r.Status().Update(ctx, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.Metrics.NotebookCullingEvents.WithLabelValues(instance.Namespace, instance.Name, action).Inc()
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, "Culled",
+			"Notebook stopped by the culling policy: %s", reason)
+		return ctrl.Result{RequeueAfter: culler.GetRequeueTime()}, nil
+	}
+
+	if culler.StopAnnotationIsSet(instance.ObjectMeta) {
 		return ctrl.Result{RequeueAfter: culler.GetRequeueTime()}, nil
 	}
-	return ctrl.Result{RequeueAfter: culler.GetRequeueTime()}, nil
+	return ctrl.Result{RequeueAfter: requeue}, nil
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// cullingPolicy resolves the culling policy of the notebook from the
+// controller env, the ConfigMap of its namespace and its spec.
+func (r *NotebookReconciler) cullingPolicy(ctx context.Context, instance *v1beta1.Notebook) (culler.Policy, error) {
+	policy := culler.PolicyFromEnv()
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: culler.GetPolicyConfigMapName(), Namespace: instance.Namespace}, cm)
+	if err != nil && apierrs.IsNotFound(err) {
+		cm = nil
+	} else if err != nil {
+		return policy, err
+	}
+	gpu := culler.PodSpecRequestsGPU(&instance.Spec.Template.Spec)
+	return policy.WithNamespaceDefaults(cm, gpu).WithSpec(instance.Spec.Culling), nil
 }
 
 func getNextCondition(cs corev1.ContainerState) v1beta1.NotebookCondition {
@@ -350,11 +561,21 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 	}
 
 	podSpec := &ss.Spec.Template.Spec
+	setPodSpecDefaults(podSpec)
+	setWorkspaceVolume(instance, podSpec)
+	setPrefixEnvVar(instance, &podSpec.Containers[0])
+	return ss
+}
+
+// setPodSpecDefaults fills in the working dir and the port of the notebook
+// container, and the fsGroup of the pod. The defaulting webhook applies them
+// to the Notebook too.
+func setPodSpecDefaults(podSpec *corev1.PodSpec) {
 	container := &podSpec.Containers[0]
 	if container.WorkingDir == "" {
-		container.WorkingDir = "/home/jovyan"
+		container.WorkingDir = DefaultWorkingDir
 	}
-	if container.Ports == nil {
+	if len(container.Ports) == 0 {
 		container.Ports = []corev1.ContainerPort{
 			{
 				ContainerPort: DefaultContainerPort,
@@ -364,7 +585,6 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 		}
 	}
 
-	setPrefixEnvVar(instance, container)
 	if value, exists := os.LookupEnv("ADD_FSGROUP"); !exists || value == "true" {
 		if podSpec.SecurityContext == nil {
 			fsGroup := DefaultFSGroup
@@ -373,13 +593,12 @@ func generateStatefulSet(instance *v1beta1.Notebook) *appsv1.StatefulSet {
 			}
 		}
 	}
-	return ss
 }
 
 func generateService(instance *v1beta1.Notebook) *corev1.Service {
 	port := DefaultContainerPort
 	containerPorts := instance.Spec.Template.Spec.Containers[0].Ports
-	if containerPorts != nil {
+	if len(containerPorts) > 0 {
 		port = int(containerPorts[0].ContainerPort)
 	}
 	svc := &corev1.Service{
@@ -403,35 +622,17 @@ func generateService(instance *v1beta1.Notebook) *corev1.Service {
 	return svc
 }
 
-func virtualServiceName(kfName string, namespace string) string {
-	return fmt.Sprintf("notebook-%s-%s", namespace, kfName)
-}
-
 func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructured, error) {
 	name := instance.Name
 	namespace := instance.Namespace
-	clusterDomain := "cluster.local"
-	prefix := fmt.Sprintf("/notebook/%s/%s/", namespace, name)
-
-	annotations := make(map[string]string)
-	for k, v := range instance.ObjectMeta.Annotations {
-		annotations[k] = v
-	}
-
-	rewrite := fmt.Sprintf("/notebook/%s/%s/", namespace, name)
-	if _, ok := annotations[AnnotationRewriteURI]; ok && len(annotations[AnnotationRewriteURI]) > 0 {
-		rewrite = annotations[AnnotationRewriteURI]
-	}
-
-	if clusterDomainFromEnv, ok := os.LookupEnv("CLUSTER_DOMAIN"); ok {
-		clusterDomain = clusterDomainFromEnv
-	}
-	service := fmt.Sprintf("%s.%s.svc.%s", name, namespace, clusterDomain)
+	prefix := routePrefix(instance)
+	rewrite := routeRewrite(instance)
+	service := serviceHost(instance)
 
 	vsvc := &unstructured.Unstructured{}
 	vsvc.SetAPIVersion("networking.istio.io/v1alpha3")
 	vsvc.SetKind("VirtualService")
-	vsvc.SetName(virtualServiceName(name, namespace))
+	vsvc.SetName(routeName(name, namespace))
 	vsvc.SetNamespace(namespace)
 	if err := unstructured.SetNestedStringSlice(vsvc.Object, []string{"*"}, "spec", "hosts"); err != nil {
 		return nil, fmt.Errorf("Set .spec.hosts error: %v", err)
@@ -446,15 +647,8 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 		return nil, fmt.Errorf("Set .spec.gateways error: %v", err)
 	}
 
-	headersRequestSet := make(map[string]string)
-	if _, ok := annotations[AnnotationHeadersRequestSet]; ok && len(annotations[AnnotationHeadersRequestSet]) > 0 {
-		requestHeadersBytes := []byte(annotations[AnnotationHeadersRequestSet])
-		if err := json.Unmarshal(requestHeadersBytes, &headersRequestSet); err != nil {
-			headersRequestSet = make(map[string]string)
-		}
-	}
 	headersRequestSetInterface := make(map[string]interface{})
-	for key, element := range headersRequestSet {
+	for key, element := range routeRequestHeaders(instance) {
 		headersRequestSetInterface[key] = element
 	}
 
@@ -497,39 +691,11 @@ func generateVirtualService(instance *v1beta1.Notebook) (*unstructured.Unstructu
 }
 
 func (r *NotebookReconciler) reconcileVirtualService(instance *v1beta1.Notebook) error {
-	log := r.Log.WithValues("notebook", instance.Namespace)
 	virtualService, err := generateVirtualService(instance)
-	if err := ctrl.SetControllerReference(instance, virtualService, r.Scheme); err != nil {
-		return err
-	}
-	foundVirtual := &unstructured.Unstructured{}
-	justCreated := false
-	foundVirtual.SetAPIVersion("networking.istio.io/v1alpha3")
-	foundVirtual.SetKind("VirtualService")
-	err = r.Get(context.TODO(), types.NamespacedName{Name: virtualServiceName(instance.Name,
-		instance.Namespace), Namespace: instance.Namespace}, foundVirtual)
-	if err != nil && apierrs.IsNotFound(err) {
-		log.Info("Creating virtual service", "namespace", instance.Namespace, "name",
-			virtualServiceName(instance.Name, instance.Namespace))
-		err = r.Create(context.TODO(), virtualService)
-		justCreated = true
-		if err != nil {
-			return err
-		}
-	} else if err != nil {
+	if err != nil {
 		return err
 	}
-
-	if !justCreated && reconcilehelper.CopyVirtualService(virtualService, foundVirtual) {
-		log.Info("Updating virtual service", "namespace", instance.Namespace, "name",
-			virtualServiceName(instance.Name, instance.Namespace))
-		err = r.Update(context.TODO(), foundVirtual)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return r.reconcileRoute(instance, virtualService, reconcilehelper.CopyVirtualService)
 }
 
 func isStsOrPodEvent(event *corev1.Event) bool {
@@ -574,12 +740,9 @@ func (r *NotebookReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		For(&v1beta1.Notebook{}).
 		Owns(&appsv1.StatefulSet{}).
 		Owns(&corev1.Service{})
-	// watch Istio virtual service
-	if os.Getenv("USE_ISTIO") == "true" {
-		virtualService := &unstructured.Unstructured{}
-		virtualService.SetAPIVersion("networking.istio.io/v1alpha3")
-		virtualService.SetKind("VirtualService")
-		builder.Owns(virtualService)
+	// watch the Istio virtual services, HTTPRoutes or Ingresses of the notebooks
+	if kind, ok := routeKindOf(getRoutingMode()); ok {
+		builder.Owns(kind.newObject())
 	}
 
 	c, err := builder.Build(r)
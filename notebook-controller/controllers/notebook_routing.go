@@ -0,0 +1,385 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Routing modes exposing notebooks outside of the cluster, set with the
+// ROUTING_MODE env var. USE_ISTIO=true selects RoutingModeIstio when it is unset.
+const (
+	RoutingModeNone       = "none"
+	RoutingModeIstio      = "istio"
+	RoutingModeGatewayAPI = "gateway-api"
+	RoutingModeIngress    = "ingress"
+)
+
+const DefaultGateway = "kubeflow/kubeflow-gateway"
+const DefaultIngressClass = "nginx"
+
+const AnnotationNginxUseRegex = "nginx.ingress.kubernetes.io/use-regex"
+const AnnotationNginxRewriteTarget = "nginx.ingress.kubernetes.io/rewrite-target"
+
+// routeKind is the kind of object a routing mode routes notebooks with.
+type routeKind struct {
+	mode       string
+	apiVersion string
+	kind       string
+}
+
+var routeKinds = []routeKind{
+	{mode: RoutingModeIstio, apiVersion: "networking.istio.io/v1alpha3", kind: "VirtualService"},
+	{mode: RoutingModeGatewayAPI, apiVersion: "gateway.networking.k8s.io/v1beta1", kind: "HTTPRoute"},
+	{mode: RoutingModeIngress, apiVersion: "networking.k8s.io/v1", kind: "Ingress"},
+}
+
+func (k routeKind) newObject() *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(k.apiVersion)
+	obj.SetKind(k.kind)
+	return obj
+}
+
+func routeKindOf(mode string) (routeKind, bool) {
+	for _, k := range routeKinds {
+		if k.mode == mode {
+			return k, true
+		}
+	}
+	return routeKind{}, false
+}
+
+func getRoutingMode() string {
+	mode := strings.ToLower(os.Getenv("ROUTING_MODE"))
+	if mode == "" {
+		if os.Getenv("USE_ISTIO") == "true" {
+			return RoutingModeIstio
+		}
+		return RoutingModeNone
+	}
+	if _, ok := routeKindOf(mode); !ok && mode != RoutingModeNone {
+		ctrl.Log.WithName("routing").Info(fmt.Sprintf("Unknown ROUTING_MODE '%s'. Notebooks will not be routed.", mode))
+		return RoutingModeNone
+	}
+	return mode
+}
+
+func routeName(kfName string, namespace string) string {
+	return fmt.Sprintf("notebook-%s-%s", namespace, kfName)
+}
+
+// routePrefix is the path every routing mode serves the notebook under, the
+// one advertised to the notebook by the NB_PREFIX env var.
+func routePrefix(instance *v1beta1.Notebook) string {
+	return fmt.Sprintf("/notebook/%s/%s/", instance.Namespace, instance.Name)
+}
+
+// notebookURL is where the routing mode serves the notebook, reported in its
+// status. It is empty when notebooks are not routed, and holds the host of the
+// Ingresses when INGRESS_HOST is set.
+func notebookURL(instance *v1beta1.Notebook) string {
+	switch getRoutingMode() {
+	case RoutingModeNone:
+		return ""
+	case RoutingModeIngress:
+		if host := os.Getenv("INGRESS_HOST"); len(host) > 0 {
+			return "http://" + host + routePrefix(instance)
+		}
+	}
+	return routePrefix(instance)
+}
+
+// routeRewrite is the path the route prefix is rewritten to before reaching
+// the notebook, routePrefix unless set by AnnotationRewriteURI.
+func routeRewrite(instance *v1beta1.Notebook) string {
+	if rewrite := instance.Annotations[AnnotationRewriteURI]; len(rewrite) > 0 {
+		return rewrite
+	}
+	return routePrefix(instance)
+}
+
+// routeRequestHeaders are the request headers set by AnnotationHeadersRequestSet.
+func routeRequestHeaders(instance *v1beta1.Notebook) map[string]string {
+	headersRequestSet := make(map[string]string)
+	if value := instance.Annotations[AnnotationHeadersRequestSet]; len(value) > 0 {
+		if err := json.Unmarshal([]byte(value), &headersRequestSet); err != nil {
+			headersRequestSet = make(map[string]string)
+		}
+	}
+	return headersRequestSet
+}
+
+func serviceHost(instance *v1beta1.Notebook) string {
+	clusterDomain := "cluster.local"
+	if clusterDomainFromEnv, ok := os.LookupEnv("CLUSTER_DOMAIN"); ok {
+		clusterDomain = clusterDomainFromEnv
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", instance.Name, instance.Namespace, clusterDomain)
+}
+
+func generateHTTPRoute(instance *v1beta1.Notebook) (*unstructured.Unstructured, error) {
+	kind, _ := routeKindOf(RoutingModeGatewayAPI)
+	route := kind.newObject()
+	route.SetName(routeName(instance.Name, instance.Namespace))
+	route.SetNamespace(instance.Namespace)
+
+	gateway := os.Getenv("GATEWAY_API_GATEWAY")
+	if len(gateway) == 0 {
+		gateway = DefaultGateway
+	}
+	parentRef := map[string]interface{}{
+		"group": "gateway.networking.k8s.io",
+		"kind":  "Gateway",
+		"name":  gateway,
+	}
+	if parts := strings.SplitN(gateway, "/", 2); len(parts) == 2 {
+		parentRef["namespace"] = parts[0]
+		parentRef["name"] = parts[1]
+	}
+	if err := unstructured.SetNestedSlice(route.Object, []interface{}{parentRef}, "spec", "parentRefs"); err != nil {
+		return nil, fmt.Errorf("Set .spec.parentRefs error: %v", err)
+	}
+
+	filters := []interface{}{}
+	if rewrite := routeRewrite(instance); rewrite != routePrefix(instance) {
+		filters = append(filters, map[string]interface{}{
+			"type": "URLRewrite",
+			"urlRewrite": map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":               "ReplacePrefixMatch",
+					"replacePrefixMatch": rewrite,
+				},
+			},
+		})
+	}
+	headers := routeRequestHeaders(instance)
+	if len(headers) > 0 {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		set := []interface{}{}
+		for _, name := range names {
+			set = append(set, map[string]interface{}{"name": name, "value": headers[name]})
+		}
+		filters = append(filters, map[string]interface{}{
+			"type": "RequestHeaderModifier",
+			"requestHeaderModifier": map[string]interface{}{
+				"set": set,
+			},
+		})
+	}
+
+	rule := map[string]interface{}{
+		"matches": []interface{}{
+			map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":  "PathPrefix",
+					"value": routePrefix(instance),
+				},
+			},
+		},
+		"backendRefs": []interface{}{
+			map[string]interface{}{
+				"group":  "",
+				"kind":   "Service",
+				"name":   instance.Name,
+				"port":   int64(DefaultServingPort),
+				"weight": int64(1),
+			},
+		},
+	}
+	if len(filters) > 0 {
+		rule["filters"] = filters
+	}
+	if err := unstructured.SetNestedSlice(route.Object, []interface{}{rule}, "spec", "rules"); err != nil {
+		return nil, fmt.Errorf("Set .spec.rules error: %v", err)
+	}
+	return route, nil
+}
+
+// generateIngress routes the notebook with an Ingress. AnnotationRewriteURI is
+// implemented with the annotations of the NGINX ingress controller.
+func generateIngress(instance *v1beta1.Notebook) (*unstructured.Unstructured, error) {
+	kind, _ := routeKindOf(RoutingModeIngress)
+	ingress := kind.newObject()
+	ingress.SetName(routeName(instance.Name, instance.Namespace))
+	ingress.SetNamespace(instance.Namespace)
+
+	path := routePrefix(instance)
+	pathType := "Prefix"
+	if rewrite := routeRewrite(instance); rewrite != routePrefix(instance) {
+		path = routePrefix(instance) + "(.*)"
+		pathType = "ImplementationSpecific"
+		ingress.SetAnnotations(map[string]string{
+			AnnotationNginxUseRegex:      "true",
+			AnnotationNginxRewriteTarget: rewrite + "$1",
+		})
+	}
+
+	ingressClass := os.Getenv("INGRESS_CLASS")
+	if len(ingressClass) == 0 {
+		ingressClass = DefaultIngressClass
+	}
+	if err := unstructured.SetNestedField(ingress.Object, ingressClass, "spec", "ingressClassName"); err != nil {
+		return nil, fmt.Errorf("Set .spec.ingressClassName error: %v", err)
+	}
+
+	rule := map[string]interface{}{
+		"http": map[string]interface{}{
+			"paths": []interface{}{
+				map[string]interface{}{
+					"path":     path,
+					"pathType": pathType,
+					"backend": map[string]interface{}{
+						"service": map[string]interface{}{
+							"name": instance.Name,
+							"port": map[string]interface{}{
+								"number": int64(DefaultServingPort),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if host := os.Getenv("INGRESS_HOST"); len(host) > 0 {
+		rule["host"] = host
+	}
+	if err := unstructured.SetNestedSlice(ingress.Object, []interface{}{rule}, "spec", "rules"); err != nil {
+		return nil, fmt.Errorf("Set .spec.rules error: %v", err)
+	}
+	return ingress, nil
+}
+
+// copyRouteFields copies the annotations and the spec of a route, and tells
+// whether they differed.
+func copyRouteFields(from, to *unstructured.Unstructured) bool {
+	requireUpdate := false
+	if !reflect.DeepEqual(from.GetAnnotations(), to.GetAnnotations()) {
+		to.SetAnnotations(from.GetAnnotations())
+		requireUpdate = true
+	}
+	if !reflect.DeepEqual(from.Object["spec"], to.Object["spec"]) {
+		to.Object["spec"] = from.Object["spec"]
+		requireUpdate = true
+	}
+	return requireUpdate
+}
+
+// reconcileRoutes routes the notebook with the routing mode of the controller
+// and deletes the routes other modes created for it.
+func (r *NotebookReconciler) reconcileRoutes(instance *v1beta1.Notebook) error {
+	mode := getRoutingMode()
+	var err error
+	switch mode {
+	case RoutingModeIstio:
+		err = r.reconcileVirtualService(instance)
+	case RoutingModeGatewayAPI:
+		var route *unstructured.Unstructured
+		if route, err = generateHTTPRoute(instance); err == nil {
+			err = r.reconcileRoute(instance, route, copyRouteFields)
+		}
+	case RoutingModeIngress:
+		var ingress *unstructured.Unstructured
+		if ingress, err = generateIngress(instance); err == nil {
+			err = r.reconcileRoute(instance, ingress, copyRouteFields)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return r.cleanupRoutes(instance, mode)
+}
+
+func (r *NotebookReconciler) reconcileRoute(instance *v1beta1.Notebook, route *unstructured.Unstructured,
+	copyFields func(from, to *unstructured.Unstructured) bool) error {
+	log := r.Log.WithValues("notebook", instance.Namespace)
+	if err := ctrl.SetControllerReference(instance, route, r.Scheme); err != nil {
+		return err
+	}
+	found := &unstructured.Unstructured{}
+	found.SetAPIVersion(route.GetAPIVersion())
+	found.SetKind(route.GetKind())
+	err := r.Get(context.TODO(), types.NamespacedName{Name: route.GetName(), Namespace: route.GetNamespace()}, found)
+	if err != nil && apierrs.IsNotFound(err) {
+		log.Info(fmt.Sprintf("Creating %s", route.GetKind()), "namespace", route.GetNamespace(), "name", route.GetName())
+		return r.Create(context.TODO(), route)
+	} else if err != nil {
+		return err
+	}
+
+	if copyFields(route, found) {
+		log.Info(fmt.Sprintf("Updating %s", route.GetKind()), "namespace", route.GetNamespace(), "name", route.GetName())
+		return r.Update(context.TODO(), found)
+	}
+	return nil
+}
+
+// cleanupRoutes deletes the routes of the other routing modes once per
+// notebook. The routing mode only changes when the controller restarts. A
+// route of the same name the notebook does not control is left alone.
+func (r *NotebookReconciler) cleanupRoutes(instance *v1beta1.Notebook, mode string) error {
+	key := types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace}
+	if _, ok := r.routesCleanedUp.Load(key); ok {
+		return nil
+	}
+	log := r.Log.WithValues("notebook", key)
+	for _, kind := range routeKinds {
+		if kind.mode == mode {
+			continue
+		}
+		route := kind.newObject()
+		name := types.NamespacedName{Name: routeName(instance.Name, instance.Namespace), Namespace: instance.Namespace}
+		err := r.Get(context.TODO(), name, route)
+		if err == nil {
+			if !metav1.IsControlledBy(route, instance) {
+				log.Info(fmt.Sprintf("%s %s is not controlled by the notebook, skipping it", kind.kind, name.Name))
+				continue
+			}
+			err = r.Delete(context.TODO(), route)
+		}
+		if err == nil {
+			log.Info(fmt.Sprintf("Deleted %s of routing mode %s", kind.kind, kind.mode))
+			continue
+		}
+		if apierrs.IsForbidden(err) {
+			log.Info(fmt.Sprintf("Not allowed to get or delete %s, skipping it", kind.kind))
+			continue
+		}
+		if !apierrs.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return err
+		}
+	}
+	r.routesCleanedUp.Store(key, true)
+	return nil
+}
@@ -0,0 +1,259 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestGetRoutingMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		routingMode string
+		useIstio    string
+		expected    string
+	}{
+		{name: "unset", expected: RoutingModeNone},
+		{name: "USE_ISTIO", useIstio: "true", expected: RoutingModeIstio},
+		{name: "ingress", routingMode: "Ingress", useIstio: "true", expected: RoutingModeIngress},
+		{name: "gateway api", routingMode: "gateway-api", expected: RoutingModeGatewayAPI},
+		{name: "unknown", routingMode: "traefik", expected: RoutingModeNone},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("ROUTING_MODE", test.routingMode)
+			os.Setenv("USE_ISTIO", test.useIstio)
+			defer os.Unsetenv("ROUTING_MODE")
+			defer os.Unsetenv("USE_ISTIO")
+			if mode := getRoutingMode(); mode != test.expected {
+				t.Errorf("expected routing mode %s, got %s", test.expected, mode)
+			}
+		})
+	}
+}
+
+func testRoutedNotebook(annotations map[string]string) *v1beta1.Notebook {
+	return &v1beta1.Notebook{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        "test-notebook",
+			Namespace:   "test-namespace",
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestGenerateHTTPRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		filters     []interface{}
+	}{
+		{
+			name: "prefix only",
+		},
+		{
+			name: "rewrite and headers",
+			annotations: map[string]string{
+				AnnotationRewriteURI:        "/",
+				AnnotationHeadersRequestSet: `{"X-b": "2", "X-a": "1"}`,
+			},
+			filters: []interface{}{
+				map[string]interface{}{
+					"type": "URLRewrite",
+					"urlRewrite": map[string]interface{}{
+						"path": map[string]interface{}{
+							"type":               "ReplacePrefixMatch",
+							"replacePrefixMatch": "/",
+						},
+					},
+				},
+				map[string]interface{}{
+					"type": "RequestHeaderModifier",
+					"requestHeaderModifier": map[string]interface{}{
+						"set": []interface{}{
+							map[string]interface{}{"name": "X-a", "value": "1"},
+							map[string]interface{}{"name": "X-b", "value": "2"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			route, err := generateHTTPRoute(testRoutedNotebook(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if route.GetName() != "notebook-test-namespace-test-notebook" {
+				t.Errorf("unexpected route name %s", route.GetName())
+			}
+			rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+			rule := rules[0].(map[string]interface{})
+			prefix, _, _ := unstructured.NestedString(rule["matches"].([]interface{})[0].(map[string]interface{}), "path", "value")
+			if prefix != "/notebook/test-namespace/test-notebook/" {
+				t.Errorf("unexpected prefix %s", prefix)
+			}
+			filters, _ := rule["filters"].([]interface{})
+			if !reflect.DeepEqual(filters, test.filters) {
+				t.Errorf("expected filters %v, got %v", test.filters, filters)
+			}
+			parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+			parentRef := parentRefs[0].(map[string]interface{})
+			if parentRef["namespace"] != "kubeflow" || parentRef["name"] != "kubeflow-gateway" {
+				t.Errorf("unexpected parentRef %v", parentRef)
+			}
+		})
+	}
+}
+
+func TestNotebookURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		routingMode string
+		ingressHost string
+		expected    string
+	}{
+		{name: "not routed", ingressHost: "notebooks.example.com", expected: ""},
+		{name: "istio", routingMode: RoutingModeIstio, expected: "/notebook/test-namespace/test-notebook/"},
+		{name: "ingress of any host", routingMode: RoutingModeIngress, expected: "/notebook/test-namespace/test-notebook/"},
+		{name: "ingress host", routingMode: RoutingModeIngress, ingressHost: "notebooks.example.com",
+			expected: "http://notebooks.example.com/notebook/test-namespace/test-notebook/"},
+		{name: "gateway api ignoring the ingress host", routingMode: RoutingModeGatewayAPI, ingressHost: "notebooks.example.com",
+			expected: "/notebook/test-namespace/test-notebook/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("ROUTING_MODE", test.routingMode)
+			os.Setenv("INGRESS_HOST", test.ingressHost)
+			defer os.Unsetenv("ROUTING_MODE")
+			defer os.Unsetenv("INGRESS_HOST")
+			if url := notebookURL(testRoutedNotebook(nil)); url != test.expected {
+				t.Errorf("expected url %q, got %q", test.expected, url)
+			}
+		})
+	}
+}
+
+func TestGenerateIngress(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		path        string
+		pathType    string
+		rewrite     string
+	}{
+		{
+			name:     "prefix only",
+			path:     "/notebook/test-namespace/test-notebook/",
+			pathType: "Prefix",
+		},
+		{
+			name:        "rewrite",
+			annotations: map[string]string{AnnotationRewriteURI: "/"},
+			path:        "/notebook/test-namespace/test-notebook/(.*)",
+			pathType:    "ImplementationSpecific",
+			rewrite:     "/$1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ingress, err := generateIngress(testRoutedNotebook(test.annotations))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+			paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+			path := paths[0].(map[string]interface{})
+			if path["path"] != test.path || path["pathType"] != test.pathType {
+				t.Errorf("expected path %s of type %s, got %v", test.path, test.pathType, path)
+			}
+			if rewrite := ingress.GetAnnotations()[AnnotationNginxRewriteTarget]; rewrite != test.rewrite {
+				t.Errorf("expected rewrite target %q, got %q", test.rewrite, rewrite)
+			}
+			class, _, _ := unstructured.NestedString(ingress.Object, "spec", "ingressClassName")
+			if class != DefaultIngressClass {
+				t.Errorf("expected ingress class %s, got %s", DefaultIngressClass, class)
+			}
+		})
+	}
+}
+
+func TestCleanupRoutes(t *testing.T) {
+	notebook := testRoutedNotebook(nil)
+	notebook.UID = "test-uid"
+	name := routeName(notebook.Name, notebook.Namespace)
+	route := func(mode string, controller *v1.OwnerReference) *unstructured.Unstructured {
+		kind, _ := routeKindOf(mode)
+		obj := kind.newObject()
+		obj.SetName(name)
+		obj.SetNamespace(notebook.Namespace)
+		if controller != nil {
+			obj.SetOwnerReferences([]v1.OwnerReference{*controller})
+		}
+		return obj
+	}
+	isController := true
+	ownedBy := func(uid types.UID) *v1.OwnerReference {
+		return &v1.OwnerReference{APIVersion: "kubeflow.org/v1beta1", Kind: "Notebook", Name: notebook.Name, UID: uid, Controller: &isController}
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		route   *unstructured.Unstructured
+		deleted bool
+	}{
+		{name: "route of the notebook", mode: RoutingModeIngress, route: route(RoutingModeIstio, ownedBy(notebook.UID)), deleted: true},
+		{name: "route of a former notebook of the same name", mode: RoutingModeIngress, route: route(RoutingModeIstio, ownedBy("former-uid"))},
+		{name: "route created by a user", mode: RoutingModeNone, route: route(RoutingModeIngress, nil)},
+		{name: "route of the current mode", mode: RoutingModeGatewayAPI, route: route(RoutingModeGatewayAPI, ownedBy(notebook.UID))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := &NotebookReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme.Scheme, test.route.DeepCopy()),
+				Log:    ctrl.Log.WithName("test"),
+				Scheme: scheme.Scheme,
+			}
+			if err := r.cleanupRoutes(notebook, test.mode); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			found := test.route.DeepCopy()
+			err := r.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: notebook.Namespace}, found)
+			if deleted := apierrs.IsNotFound(err); deleted != test.deleted {
+				t.Errorf("expected %s deleted %t, got error %v", test.route.GetKind(), test.deleted, err)
+			}
+		})
+	}
+}
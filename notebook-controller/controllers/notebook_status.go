@@ -0,0 +1,176 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Reasons the pod of a notebook cannot be scheduled, parsed from the message
+// of the scheduler.
+const (
+	UnschedulableInsufficientGPU    = "InsufficientGPU"
+	UnschedulableInsufficientCPU    = "InsufficientCPU"
+	UnschedulableInsufficientMemory = "InsufficientMemory"
+	UnschedulableTaints             = "Taints"
+	UnschedulableNodeSelector       = "NodeSelector"
+	UnschedulablePVCPending         = "PVCPending"
+	UnschedulableOther              = "Unschedulable"
+)
+
+const (
+	ImagePullPulling = "Pulling"
+	ImagePullPulled  = "Pulled"
+	ImagePullFailed  = "Failed"
+)
+
+var unschedulableMessages = []struct {
+	reason   string
+	patterns []string
+}{
+	{UnschedulableInsufficientGPU, []string{"insufficient nvidia.com/gpu", "insufficient aliyun.com/gpu"}},
+	{UnschedulableInsufficientCPU, []string{"insufficient cpu"}},
+	{UnschedulableInsufficientMemory, []string{"insufficient memory"}},
+	{UnschedulableTaints, []string{"had taint", "had untolerated taint"}},
+	{UnschedulableNodeSelector, []string{"didn't match node selector", "didn't match pod's node affinity"}},
+	{UnschedulablePVCPending, []string{"unbound immediate persistentvolumeclaims", "persistentvolumeclaim", "volume node affinity conflict"}},
+}
+
+var imagePullErrors = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// unschedulableReasons parses the reasons out of a message of the scheduler
+// such as "0/3 nodes are available: 3 Insufficient nvidia.com/gpu.".
+func unschedulableReasons(message string) []string {
+	message = strings.ToLower(message)
+	reasons := []string{}
+	for _, m := range unschedulableMessages {
+		for _, pattern := range m.patterns {
+			if strings.Contains(message, pattern) {
+				reasons = append(reasons, m.reason)
+				break
+			}
+		}
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, UnschedulableOther)
+	}
+	return reasons
+}
+
+// notebookContainerStatus is the status of the container named after the
+// notebook, or of the first container when none is.
+func notebookContainerStatus(pod *corev1.Pod, name string) *corev1.ContainerStatus {
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return nil
+	}
+	for i := range pod.Status.ContainerStatuses {
+		if pod.Status.ContainerStatuses[i].Name == name {
+			return &pod.Status.ContainerStatuses[i]
+		}
+	}
+	return &pod.Status.ContainerStatuses[0]
+}
+
+// updatePodStatus copies the phase, the node, the scheduling problems and the
+// image pull errors of the pod into the status, and tells whether it changed.
+func updatePodStatus(status *v1beta1.NotebookStatus, pod *corev1.Pod, name string) bool {
+	old := status.DeepCopy()
+
+	status.PodPhase = pod.Status.Phase
+	status.NodeName = pod.Spec.NodeName
+	for _, c := range pod.Status.Conditions {
+		if c.Type != corev1.PodScheduled {
+			continue
+		}
+		if c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+			setUnschedulable(status, c.Message)
+		} else if c.Status == corev1.ConditionTrue {
+			setUnschedulable(status, "")
+		}
+	}
+
+	if cs := notebookContainerStatus(pod, name); cs != nil {
+		if cs.State.Waiting != nil && imagePullErrors[cs.State.Waiting.Reason] {
+			status.ImagePullState = ImagePullFailed
+			status.ImagePullMessage = cs.State.Waiting.Message
+			if status.ImagePullMessage == "" {
+				status.ImagePullMessage = cs.State.Waiting.Reason
+			}
+		} else if (cs.State.Running != nil || cs.State.Terminated != nil) && status.ImagePullState != ImagePullPulled {
+			status.ImagePullState = ImagePullPulled
+			status.ImagePullMessage = ""
+		}
+	}
+	return !reflect.DeepEqual(old, status)
+}
+
+// updateEventStatus applies a scheduling or image pull event of the pod of
+// the notebook to its status, and tells whether it changed.
+func updateEventStatus(status *v1beta1.NotebookStatus, event *corev1.Event) bool {
+	if event.InvolvedObject.Kind != "Pod" {
+		return false
+	}
+	old := status.DeepCopy()
+
+	switch event.Reason {
+	case "FailedScheduling":
+		setUnschedulable(status, event.Message)
+	case "Scheduled":
+		setUnschedulable(status, "")
+	case "Pulling":
+		status.ImagePullState = ImagePullPulling
+		status.ImagePullMessage = event.Message
+	case "Pulled":
+		status.ImagePullState = ImagePullPulled
+		status.ImagePullMessage = event.Message
+	case "Failed", "ErrImagePull", "BackOff":
+		if strings.Contains(strings.ToLower(event.Message), "image") {
+			status.ImagePullState = ImagePullFailed
+			status.ImagePullMessage = event.Message
+		}
+	}
+	return !reflect.DeepEqual(old, status)
+}
+
+// clearPodStatus forgets the pod of a notebook that has none.
+func clearPodStatus(status *v1beta1.NotebookStatus) bool {
+	old := status.DeepCopy()
+	status.PodPhase = ""
+	status.NodeName = ""
+	status.ImagePullState = ""
+	status.ImagePullMessage = ""
+	setUnschedulable(status, "")
+	return !reflect.DeepEqual(old, status)
+}
+
+func setUnschedulable(status *v1beta1.NotebookStatus, message string) {
+	if message == "" {
+		status.UnschedulableReasons = nil
+		status.SchedulingMessage = ""
+		return
+	}
+	status.UnschedulableReasons = unschedulableReasons(message)
+	status.SchedulingMessage = message
+}
@@ -0,0 +1,218 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestUnschedulableReasons(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected []string
+	}{
+		{
+			name:     "no GPU node free",
+			message:  "0/3 nodes are available: 3 Insufficient nvidia.com/gpu.",
+			expected: []string{UnschedulableInsufficientGPU},
+		},
+		{
+			name:     "GPU and taints",
+			message:  "0/4 nodes are available: 1 node(s) had untolerated taint {virtual-kubelet.io/provider: alibabacloud}, 3 Insufficient aliyun.com/gpu-mem.",
+			expected: []string{UnschedulableInsufficientGPU, UnschedulableTaints},
+		},
+		{
+			name:     "pending PVC",
+			message:  "0/3 nodes are available: pod has unbound immediate PersistentVolumeClaims.",
+			expected: []string{UnschedulablePVCPending},
+		},
+		{
+			name:     "unknown",
+			message:  "0/3 nodes are available: 3 node(s) were unschedulable.",
+			expected: []string{UnschedulableOther},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if reasons := unschedulableReasons(test.message); !reflect.DeepEqual(reasons, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, reasons)
+			}
+		})
+	}
+}
+
+func TestUpdatePodStatus(t *testing.T) {
+	unschedulable := corev1.PodCondition{
+		Type:    corev1.PodScheduled,
+		Status:  corev1.ConditionFalse,
+		Reason:  corev1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 3 Insufficient nvidia.com/gpu.",
+	}
+	scheduled := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}
+
+	tests := []struct {
+		name     string
+		status   v1beta1.NotebookStatus
+		pod      corev1.Pod
+		expected v1beta1.NotebookStatus
+		changed  bool
+	}{
+		{
+			name: "unschedulable",
+			pod: corev1.Pod{Status: corev1.PodStatus{
+				Phase:      corev1.PodPending,
+				Conditions: []corev1.PodCondition{unschedulable},
+			}},
+			expected: v1beta1.NotebookStatus{
+				PodPhase:             corev1.PodPending,
+				UnschedulableReasons: []string{UnschedulableInsufficientGPU},
+				SchedulingMessage:    unschedulable.Message,
+			},
+			changed: true,
+		},
+		{
+			name: "image pull error of a container not named after the notebook",
+			status: v1beta1.NotebookStatus{
+				PodPhase:             corev1.PodPending,
+				UnschedulableReasons: []string{UnschedulableInsufficientGPU},
+				SchedulingMessage:    unschedulable.Message,
+			},
+			pod: corev1.Pod{
+				Spec: corev1.PodSpec{NodeName: "node-1"},
+				Status: corev1.PodStatus{
+					Phase:      corev1.PodPending,
+					Conditions: []corev1.PodCondition{scheduled},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name: "jupyter",
+						State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+							Reason:  "ImagePullBackOff",
+							Message: "Back-off pulling image \"jupyter:missing\"",
+						}},
+					}},
+				},
+			},
+			expected: v1beta1.NotebookStatus{
+				PodPhase:         corev1.PodPending,
+				NodeName:         "node-1",
+				ImagePullState:   ImagePullFailed,
+				ImagePullMessage: "Back-off pulling image \"jupyter:missing\"",
+			},
+			changed: true,
+		},
+		{
+			name: "running",
+			status: v1beta1.NotebookStatus{
+				PodPhase:       corev1.PodRunning,
+				NodeName:       "node-1",
+				ImagePullState: ImagePullPulled,
+			},
+			pod: corev1.Pod{
+				Spec: corev1.PodSpec{NodeName: "node-1"},
+				Status: corev1.PodStatus{
+					Phase:      corev1.PodRunning,
+					Conditions: []corev1.PodCondition{scheduled},
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "test-notebook",
+						State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					}},
+				},
+			},
+			expected: v1beta1.NotebookStatus{
+				PodPhase:       corev1.PodRunning,
+				NodeName:       "node-1",
+				ImagePullState: ImagePullPulled,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changed := updatePodStatus(&test.status, &test.pod, "test-notebook")
+			if changed != test.changed {
+				t.Errorf("expected changed %v, got %v", test.changed, changed)
+			}
+			if !reflect.DeepEqual(test.status, test.expected) {
+				t.Errorf("expected status %+v, got %+v", test.expected, test.status)
+			}
+		})
+	}
+}
+
+func TestUpdateEventStatus(t *testing.T) {
+	podEvent := func(reason, message string) *corev1.Event {
+		return &corev1.Event{
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "test-notebook-0"},
+			Reason:         reason,
+			Message:        message,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		event    *corev1.Event
+		expected v1beta1.NotebookStatus
+		changed  bool
+	}{
+		{
+			name:  "pulling",
+			event: podEvent("Pulling", "Pulling image \"jupyter:latest\""),
+			expected: v1beta1.NotebookStatus{
+				ImagePullState:   ImagePullPulling,
+				ImagePullMessage: "Pulling image \"jupyter:latest\"",
+			},
+			changed: true,
+		},
+		{
+			name:  "failed scheduling",
+			event: podEvent("FailedScheduling", "0/3 nodes are available: 3 Insufficient nvidia.com/gpu."),
+			expected: v1beta1.NotebookStatus{
+				UnschedulableReasons: []string{UnschedulableInsufficientGPU},
+				SchedulingMessage:    "0/3 nodes are available: 3 Insufficient nvidia.com/gpu.",
+			},
+			changed: true,
+		},
+		{
+			name:  "crash loop",
+			event: podEvent("BackOff", "Back-off restarting failed container"),
+		},
+		{
+			name: "statefulset event",
+			event: &corev1.Event{
+				InvolvedObject: corev1.ObjectReference{Kind: "StatefulSet", Name: "test-notebook"},
+				Reason:         "FailedCreate",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := v1beta1.NotebookStatus{}
+			changed := updateEventStatus(&status, test.event)
+			if changed != test.changed {
+				t.Errorf("expected changed %v, got %v", test.changed, changed)
+			}
+			if !reflect.DeepEqual(status, test.expected) {
+				t.Errorf("expected status %+v, got %+v", test.expected, status)
+			}
+		})
+	}
+}
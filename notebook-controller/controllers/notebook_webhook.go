@@ -0,0 +1,268 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"reflect"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/culler"
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// MaxNotebookNameLength keeps the controller-revision-hash label of the pods
+// of the StatefulSet, <name>-<10 characters hash>, within 63 characters. The
+// Service and route names of the notebook then fit too.
+const MaxNotebookNameLength = 52
+
+const DefaultResourceLimitsConfigMap = "notebook-resource-limits"
+
+// Keys of the resource limits ConfigMap of a namespace, each one caps the sum
+// of the resources of the containers of a notebook.
+const (
+	ResourceLimitCPU       = metrics.ResourceCPU
+	ResourceLimitMemory    = metrics.ResourceMemory
+	ResourceLimitGPU       = metrics.ResourceGPU
+	ResourceLimitGPUMemory = metrics.ResourceGPUMemory
+)
+
+var webhookLog = ctrl.Log.WithName("webhooks").WithName("Notebook")
+
+func getResourceLimitsConfigMapName() string {
+	if name := os.Getenv("RESOURCE_LIMITS_CONFIGMAP"); len(name) > 0 {
+		return name
+	}
+	return DefaultResourceLimitsConfigMap
+}
+
+// All the versions of the Notebook share the schema of the v1beta1 hub, so the
+// webhooks read every version as a v1beta1 Notebook.
+func decodeNotebook(raw []byte) (*v1beta1.Notebook, error) {
+	notebook := &v1beta1.Notebook{}
+	if err := json.Unmarshal(raw, notebook); err != nil {
+		return nil, err
+	}
+	return notebook, nil
+}
+
+// NotebookDefaulter fills in the defaults the controller applies to the pods
+// of a notebook, so they are visible on the Notebook.
+type NotebookDefaulter struct{}
+
+func (d *NotebookDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	notebook, err := decodeNotebook(req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if len(notebook.Spec.Template.Spec.Containers) == 0 {
+		return admission.Allowed("rejected by validation")
+	}
+	setPodSpecDefaults(&notebook.Spec.Template.Spec)
+
+	// Only the spec is patched, the rest of the object is kept as sent.
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(req.Object.Raw, &object); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	spec, err := json.Marshal(notebook.Spec)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	var specObject interface{}
+	if err := json.Unmarshal(spec, &specObject); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	object["spec"] = specObject
+	current, err := json.Marshal(object)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, current)
+}
+
+// NotebookValidator rejects the notebooks the controller cannot run, and the
+// ones requesting more resources than their namespace allows.
+type NotebookValidator struct {
+	Client client.Client
+}
+
+func (v *NotebookValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	notebook, err := decodeNotebook(req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	allErrs := field.ErrorList{}
+	// The controller and the console update the annotations of notebooks, do
+	// not reject them when the limits of the namespace changed since.
+	if len(req.OldObject.Raw) > 0 {
+		old, err := decodeNotebook(req.OldObject.Raw)
+		if err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if reflect.DeepEqual(old.Spec, notebook.Spec) {
+			return admission.Allowed("spec unchanged")
+		}
+		allErrs = append(allErrs, validateNotebookUpdate(notebook, old)...)
+	}
+
+	allErrs = append(allErrs, validateNotebook(notebook)...)
+	limits := &corev1.ConfigMap{}
+	err = v.Client.Get(ctx, types.NamespacedName{Name: getResourceLimitsConfigMapName(), Namespace: req.Namespace}, limits)
+	if err != nil && !apierrs.IsNotFound(err) {
+		return admission.Errored(http.StatusInternalServerError, err)
+	} else if err == nil {
+		allErrs = append(allErrs, validateResourceLimits(notebook, limits)...)
+	}
+
+	if len(allErrs) > 0 {
+		return admission.Denied(allErrs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+func validateNotebook(notebook *v1beta1.Notebook) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	// The name is not known yet when it is generated.
+	if name := notebook.Name; name != "" {
+		namePath := field.NewPath("metadata", "name")
+		if len(name) > MaxNotebookNameLength {
+			allErrs = append(allErrs, field.TooLong(namePath, name, MaxNotebookNameLength))
+		}
+		for _, msg := range validation.IsDNS1035Label(name) {
+			allErrs = append(allErrs, field.Invalid(namePath, name, msg))
+		}
+	}
+
+	containersPath := field.NewPath("spec", "template", "spec", "containers")
+	containers := notebook.Spec.Template.Spec.Containers
+	if len(containers) == 0 {
+		allErrs = append(allErrs, field.Required(containersPath, "a notebook needs a container"))
+	} else if notebook.Name != "" && containers[0].Name != notebook.Name {
+		allErrs = append(allErrs, field.Invalid(containersPath.Index(0).Child("name"), containers[0].Name,
+			fmt.Sprintf("the first container must be named after the notebook, %s", notebook.Name)))
+	}
+
+	if schedule := notebook.Spec.Schedule; schedule != nil {
+		if _, err := culler.ParseSchedule(schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "schedule"), *schedule, err.Error()))
+		}
+	}
+
+	if workspace := notebook.Spec.Workspace; workspace != nil {
+		allErrs = append(allErrs, validateWorkspace(workspace, &notebook.Spec.Template.Spec)...)
+	}
+	return allErrs
+}
+
+var workspaceAccessModes = []string{string(corev1.ReadWriteOnce), string(corev1.ReadOnlyMany), string(corev1.ReadWriteMany)}
+
+var workspaceRetentionPolicies = []string{WorkspaceRetentionRetain, WorkspaceRetentionDelete}
+
+func validateWorkspace(workspace *v1beta1.WorkspaceVolume, podSpec *corev1.PodSpec) field.ErrorList {
+	allErrs := field.ErrorList{}
+	workspacePath := field.NewPath("spec", "workspace")
+
+	if workspace.Size.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(workspacePath.Child("size"), workspace.Size.String(), "must be greater than zero"))
+	}
+	if workspace.AccessMode != "" && !containsString(workspaceAccessModes, string(workspace.AccessMode)) {
+		allErrs = append(allErrs, field.NotSupported(workspacePath.Child("accessMode"), workspace.AccessMode, workspaceAccessModes))
+	}
+	if workspace.RetentionPolicy != "" && !containsString(workspaceRetentionPolicies, workspace.RetentionPolicy) {
+		allErrs = append(allErrs, field.NotSupported(workspacePath.Child("retentionPolicy"), workspace.RetentionPolicy, workspaceRetentionPolicies))
+	}
+	if workspace.MountPath != "" && !path.IsAbs(workspace.MountPath) {
+		allErrs = append(allErrs, field.Invalid(workspacePath.Child("mountPath"), workspace.MountPath, "must be an absolute path"))
+	}
+
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == WorkspaceVolumeName {
+			allErrs = append(allErrs, field.Duplicate(field.NewPath("spec", "template", "spec", "volumes"), WorkspaceVolumeName))
+		}
+	}
+	if len(podSpec.Containers) > 0 {
+		mountPath := workspaceMountPath(workspace)
+		for _, mount := range podSpec.Containers[0].VolumeMounts {
+			if path.Clean(mount.MountPath) == path.Clean(mountPath) {
+				allErrs = append(allErrs, field.Invalid(workspacePath.Child("mountPath"), mountPath,
+					fmt.Sprintf("volume %s is already mounted there", mount.Name)))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateNotebookUpdate rejects the changes of a notebook its resources
+// cannot follow.
+func validateNotebookUpdate(notebook, old *v1beta1.Notebook) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if notebook.Spec.Workspace != nil && old.Spec.Workspace != nil &&
+		notebook.Spec.Workspace.Size.Cmp(old.Spec.Workspace.Size) < 0 {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "workspace", "size"),
+			fmt.Sprintf("the workspace cannot shrink from %s", old.Spec.Workspace.Size.String())))
+	}
+	return allErrs
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func validateResourceLimits(notebook *v1beta1.Notebook, limits *corev1.ConfigMap) field.ErrorList {
+	allErrs := field.ErrorList{}
+	containersPath := field.NewPath("spec", "template", "spec", "containers")
+	total := metrics.NotebookResources(&notebook.Spec.Template.Spec)
+	for _, key := range []string{ResourceLimitCPU, ResourceLimitMemory, ResourceLimitGPU, ResourceLimitGPUMemory} {
+		value, ok := limits.Data[key]
+		if !ok {
+			continue
+		}
+		limit, err := resource.ParseQuantity(value)
+		if err != nil {
+			webhookLog.Info(fmt.Sprintf("Invalid %s limit '%s' in ConfigMap %s/%s, ignoring it",
+				key, value, limits.Namespace, limits.Name))
+			continue
+		}
+		if total[key].Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Forbidden(containersPath,
+				fmt.Sprintf("the notebook asks for %s %s, more than the %s allowed in namespace %s",
+					total[key].String(), key, limit.String(), limits.Namespace)))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,229 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func testWebhookNotebook(name string, containers ...corev1.Container) *v1beta1.Notebook {
+	notebook := &v1beta1.Notebook{
+		ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "test-namespace"},
+	}
+	notebook.Spec.Template.Spec.Containers = containers
+	return notebook
+}
+
+func testAdmissionRequest(t *testing.T, notebook, old *v1beta1.Notebook) admission.Request {
+	raw, err := json.Marshal(notebook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+		Namespace: notebook.Namespace,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+	if old != nil {
+		oldRaw, err := json.Marshal(old)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Operation = admissionv1beta1.Update
+		req.OldObject = runtime.RawExtension{Raw: oldRaw}
+	}
+	return req
+}
+
+func TestValidateNotebook(t *testing.T) {
+	tests := []struct {
+		name     string
+		notebook *v1beta1.Notebook
+		errors   int
+	}{
+		{
+			name:     "valid",
+			notebook: testWebhookNotebook("test-notebook", corev1.Container{Name: "test-notebook"}),
+		},
+		{
+			name:     "no container",
+			notebook: testWebhookNotebook("test-notebook"),
+			errors:   1,
+		},
+		{
+			name:     "container not named after the notebook",
+			notebook: testWebhookNotebook("test-notebook", corev1.Container{Name: "notebook"}),
+			errors:   1,
+		},
+		{
+			name:     "name too long for the StatefulSet",
+			notebook: testWebhookNotebook(strings.Repeat("a", 53), corev1.Container{Name: strings.Repeat("a", 53)}),
+			errors:   1,
+		},
+		{
+			name:     "name invalid for the Service",
+			notebook: testWebhookNotebook("1-notebook", corev1.Container{Name: "1-notebook"}),
+			errors:   1,
+		},
+		{
+			name: "invalid schedule",
+			notebook: func() *v1beta1.Notebook {
+				notebook := testWebhookNotebook("test-notebook", corev1.Container{Name: "test-notebook"})
+				notebook.Spec.Schedule = &v1beta1.NotebookSchedule{Stop: "0 20 * *"}
+				return notebook
+			}(),
+			errors: 1,
+		},
+		{
+			name: "workspace",
+			notebook: func() *v1beta1.Notebook {
+				notebook := testWebhookNotebook("test-notebook", corev1.Container{Name: "test-notebook"})
+				notebook.Spec.Workspace = &v1beta1.WorkspaceVolume{Size: resource.MustParse("10Gi"), RetentionPolicy: WorkspaceRetentionDelete}
+				return notebook
+			}(),
+		},
+		{
+			name: "invalid workspace",
+			notebook: func() *v1beta1.Notebook {
+				notebook := testWebhookNotebook("test-notebook", corev1.Container{
+					Name:         "test-notebook",
+					VolumeMounts: []corev1.VolumeMount{{Name: "data", MountPath: "/home/jovyan/"}},
+				})
+				notebook.Spec.Workspace = &v1beta1.WorkspaceVolume{RetentionPolicy: "Keep"}
+				return notebook
+			}(),
+			errors: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if errs := validateNotebook(test.notebook); len(errs) != test.errors {
+				t.Errorf("expected %d errors, got %v", test.errors, errs)
+			}
+		})
+	}
+}
+
+func TestNotebookValidator(t *testing.T) {
+	limits := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{Name: DefaultResourceLimitsConfigMap, Namespace: "test-namespace"},
+		Data: map[string]string{
+			ResourceLimitCPU: "4",
+			ResourceLimitGPU: "1",
+		},
+	}
+	container := func(cpu, gpu string) corev1.Container {
+		return corev1.Container{
+			Name: "test-notebook",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				Limits:   corev1.ResourceList{"nvidia.com/gpu": resource.MustParse(gpu)},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		notebook *v1beta1.Notebook
+		old      *v1beta1.Notebook
+		allowed  bool
+	}{
+		{
+			name:     "within the limits",
+			notebook: testWebhookNotebook("test-notebook", container("2", "1")),
+			allowed:  true,
+		},
+		{
+			name:     "too many GPUs",
+			notebook: testWebhookNotebook("test-notebook", container("2", "2")),
+		},
+		{
+			name: "too many CPUs over two containers",
+			notebook: testWebhookNotebook("test-notebook", container("3", "0"),
+				corev1.Container{Name: "sidecar", Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				}}),
+		},
+		{
+			name: "shrunk workspace",
+			notebook: func() *v1beta1.Notebook {
+				notebook := testWebhookNotebook("test-notebook", container("2", "1"))
+				notebook.Spec.Workspace = &v1beta1.WorkspaceVolume{Size: resource.MustParse("5Gi")}
+				return notebook
+			}(),
+			old: func() *v1beta1.Notebook {
+				notebook := testWebhookNotebook("test-notebook", container("2", "1"))
+				notebook.Spec.Workspace = &v1beta1.WorkspaceVolume{Size: resource.MustParse("10Gi")}
+				return notebook
+			}(),
+		},
+		{
+			name:     "annotations of a notebook over the limits",
+			notebook: testWebhookNotebook("test-notebook", container("2", "2")),
+			old:      testWebhookNotebook("test-notebook", container("2", "2")),
+			allowed:  true,
+		},
+	}
+
+	validator := &NotebookValidator{Client: fake.NewFakeClientWithScheme(scheme.Scheme, limits)}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := validator.Handle(context.TODO(), testAdmissionRequest(t, test.notebook, test.old))
+			if resp.Allowed != test.allowed {
+				t.Errorf("expected allowed %v, got %+v", test.allowed, resp.Result)
+			}
+		})
+	}
+}
+
+func TestNotebookDefaulter(t *testing.T) {
+	notebook := testWebhookNotebook("test-notebook", corev1.Container{Name: "test-notebook", Image: "jupyter"})
+	resp := (&NotebookDefaulter{}).Handle(context.TODO(), testAdmissionRequest(t, notebook, nil))
+	if !resp.Allowed {
+		t.Fatalf("expected the notebook to be allowed, got %+v", resp.Result)
+	}
+
+	patched := map[string]bool{}
+	for _, p := range resp.Patches {
+		if !strings.HasPrefix(p.Path, "/spec/") {
+			t.Errorf("unexpected patch outside of the spec: %v", p)
+		}
+		patched[p.Path] = true
+	}
+	for _, path := range []string{
+		"/spec/template/spec/containers/0/workingDir",
+		"/spec/template/spec/containers/0/ports",
+		"/spec/template/spec/securityContext",
+	} {
+		if !patched[path] {
+			t.Errorf("expected %s to be defaulted, got %v", path, resp.Patches)
+		}
+	}
+}
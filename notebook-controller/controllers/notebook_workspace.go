@@ -0,0 +1,191 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// WorkspaceVolumeName is the name of the workspace volume in the pod of a
+// notebook. The console names the volumes of a notebook after their PVCs.
+const WorkspaceVolumeName = "notebook-workspace"
+
+// What happens to the workspace PVC of a notebook when it is deleted.
+const (
+	WorkspaceRetentionRetain = "Retain"
+	WorkspaceRetentionDelete = "Delete"
+)
+
+func workspacePVCName(instance *v1beta1.Notebook) string {
+	return instance.Name + "-workspace"
+}
+
+func workspaceMountPath(workspace *v1beta1.WorkspaceVolume) string {
+	if workspace.MountPath != "" {
+		return workspace.MountPath
+	}
+	return DefaultWorkingDir
+}
+
+func generateWorkspacePVC(instance *v1beta1.Notebook) *corev1.PersistentVolumeClaim {
+	workspace := instance.Spec.Workspace
+	accessMode := workspace.AccessMode
+	if accessMode == "" {
+		accessMode = corev1.ReadWriteOnce
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workspacePVCName(instance),
+			Namespace: instance.Namespace,
+			Labels:    map[string]string{"notebook-name": instance.Name},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{accessMode},
+			StorageClassName: workspace.StorageClassName,
+		},
+	}
+	pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: workspace.Size}
+	return pvc
+}
+
+// setWorkspaceVolume mounts the workspace PVC of the notebook into its
+// container.
+func setWorkspaceVolume(instance *v1beta1.Notebook, podSpec *corev1.PodSpec) {
+	if instance.Spec.Workspace == nil {
+		return
+	}
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: WorkspaceVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: workspacePVCName(instance),
+			},
+		},
+	})
+	container := &podSpec.Containers[0]
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      WorkspaceVolumeName,
+		MountPath: workspaceMountPath(instance.Spec.Workspace),
+	})
+}
+
+// copyWorkspacePVCFields copies the size and the owner of the notebook from
+// the generated PVC to the existing one. The storage class and access mode
+// of a PVC cannot change, and it cannot shrink, which the returned message
+// explains.
+func copyWorkspacePVCFields(instance *v1beta1.Notebook, from, to *corev1.PersistentVolumeClaim) (bool, string) {
+	requireUpdate := false
+	message := ""
+
+	size := from.Spec.Resources.Requests[corev1.ResourceStorage]
+	current := to.Spec.Resources.Requests[corev1.ResourceStorage]
+	if size.Cmp(current) > 0 {
+		if to.Spec.Resources.Requests == nil {
+			to.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		to.Spec.Resources.Requests[corev1.ResourceStorage] = size
+		requireUpdate = true
+	} else if size.Cmp(current) < 0 {
+		message = fmt.Sprintf("Workspace %s cannot shrink from %s to %s", to.Name, current.String(), size.String())
+	}
+
+	// Only the notebook owns a retained PVC after it is deleted, drop the
+	// owner reference of the notebook and keep the others.
+	owners := []metav1.OwnerReference{}
+	owned := false
+	for _, owner := range to.OwnerReferences {
+		if owner.UID == instance.UID {
+			owned = true
+			continue
+		}
+		owners = append(owners, owner)
+	}
+	if len(from.OwnerReferences) > 0 {
+		owners = append(owners, from.OwnerReferences...)
+	}
+	if owned != (len(from.OwnerReferences) > 0) {
+		to.OwnerReferences = owners
+		requireUpdate = true
+	}
+	return requireUpdate, message
+}
+
+// reconcileWorkspace provisions the workspace PVC of the notebook, and
+// expands it when the notebook asks for a bigger workspace. A PVC left by a
+// deleted notebook of the same name is reused.
+func (r *NotebookReconciler) reconcileWorkspace(ctx context.Context, instance *v1beta1.Notebook) error {
+	if instance.Spec.Workspace == nil {
+		return nil
+	}
+	log := r.Log.WithValues("notebook", types.NamespacedName{Name: instance.Name, Namespace: instance.Namespace})
+
+	pvc := generateWorkspacePVC(instance)
+	if instance.Spec.Workspace.RetentionPolicy == WorkspaceRetentionDelete {
+		if err := ctrl.SetControllerReference(instance, pvc, r.Scheme); err != nil {
+			return err
+		}
+	}
+
+	found := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, types.NamespacedName{Name: pvc.Name, Namespace: pvc.Namespace}, found)
+	if err != nil && apierrs.IsNotFound(err) {
+		log.Info("Creating workspace PVC", "namespace", pvc.Namespace, "name", pvc.Name)
+		if err := r.Create(ctx, pvc); err != nil {
+			log.Error(err, "unable to create workspace PVC")
+			return err
+		}
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, "WorkspaceCreated",
+			"Created workspace %s of %s", pvc.Name, instance.Spec.Workspace.Size.String())
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	current := found.Spec.Resources.Requests[corev1.ResourceStorage]
+	requireUpdate, message := copyWorkspacePVCFields(instance, pvc, found)
+	if message != "" {
+		r.EventRecorder.Event(instance, corev1.EventTypeWarning, "WorkspaceResizeFailed", message)
+	}
+	if !requireUpdate {
+		return nil
+	}
+	log.Info("Updating workspace PVC", "namespace", found.Namespace, "name", found.Name)
+	if err := r.Update(ctx, found); err != nil {
+		// A storage class without volume expansion rejects the resize, which
+		// retrying does not fix.
+		if apierrs.IsForbidden(err) || apierrs.IsInvalid(err) {
+			r.EventRecorder.Eventf(instance, corev1.EventTypeWarning, "WorkspaceResizeFailed",
+				"Cannot resize workspace %s: %v", found.Name, err)
+			return nil
+		}
+		log.Error(err, "unable to update workspace PVC")
+		return err
+	}
+	size := found.Spec.Resources.Requests[corev1.ResourceStorage]
+	if size.Cmp(current) != 0 {
+		r.EventRecorder.Eventf(instance, corev1.EventTypeNormal, "WorkspaceResizing",
+			"Expanding workspace %s from %s to %s", found.Name, current.String(), size.String())
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func testWorkspaceNotebook(size string) *v1beta1.Notebook {
+	notebook := testWebhookNotebook("test-notebook", corev1.Container{Name: "test-notebook"})
+	notebook.UID = "test-uid"
+	notebook.Spec.Workspace = &v1beta1.WorkspaceVolume{Size: resource.MustParse(size)}
+	return notebook
+}
+
+func TestGenerateStatefulSetWorkspace(t *testing.T) {
+	notebook := testWorkspaceNotebook("10Gi")
+	notebook.Spec.Workspace.MountPath = "/data"
+	podSpec := generateStatefulSet(notebook).Spec.Template.Spec
+
+	if len(podSpec.Volumes) != 1 || podSpec.Volumes[0].Name != WorkspaceVolumeName ||
+		podSpec.Volumes[0].PersistentVolumeClaim == nil ||
+		podSpec.Volumes[0].PersistentVolumeClaim.ClaimName != "test-notebook-workspace" {
+		t.Errorf("expected the workspace volume, got %+v", podSpec.Volumes)
+	}
+	mounts := podSpec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != WorkspaceVolumeName || mounts[0].MountPath != "/data" {
+		t.Errorf("expected the workspace mounted at /data, got %+v", mounts)
+	}
+	if len(notebook.Spec.Template.Spec.Volumes) != 0 {
+		t.Errorf("expected the notebook to be left alone, got %+v", notebook.Spec.Template.Spec.Volumes)
+	}
+}
+
+func TestCopyWorkspacePVCFields(t *testing.T) {
+	owner := v1.OwnerReference{Kind: "Notebook", Name: "test-notebook", UID: "test-uid"}
+	other := v1.OwnerReference{Kind: "Other", Name: "other", UID: "other-uid"}
+
+	tests := []struct {
+		name          string
+		size          string
+		owners        []v1.OwnerReference
+		current       string
+		currentOwners []v1.OwnerReference
+		update        bool
+		shrink        bool
+		expectedSize  string
+		expectedOwner int
+	}{
+		{
+			name:         "unchanged",
+			size:         "10Gi",
+			current:      "10Gi",
+			expectedSize: "10Gi",
+		},
+		{
+			name:         "grown",
+			size:         "20Gi",
+			current:      "10Gi",
+			update:       true,
+			expectedSize: "20Gi",
+		},
+		{
+			name:         "shrunk",
+			size:         "5Gi",
+			current:      "10Gi",
+			shrink:       true,
+			expectedSize: "10Gi",
+		},
+		{
+			name:          "deleted with the notebook",
+			size:          "10Gi",
+			owners:        []v1.OwnerReference{owner},
+			current:       "10Gi",
+			currentOwners: []v1.OwnerReference{other},
+			update:        true,
+			expectedSize:  "10Gi",
+			expectedOwner: 2,
+		},
+		{
+			name:          "retained",
+			size:          "10Gi",
+			current:       "10Gi",
+			currentOwners: []v1.OwnerReference{other, owner},
+			update:        true,
+			expectedSize:  "10Gi",
+			expectedOwner: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			notebook := testWorkspaceNotebook(test.size)
+			from := generateWorkspacePVC(notebook)
+			from.OwnerReferences = test.owners
+			to := generateWorkspacePVC(testWorkspaceNotebook(test.current))
+			to.OwnerReferences = test.currentOwners
+
+			update, message := copyWorkspacePVCFields(notebook, from, to)
+			if update != test.update || (message != "") != test.shrink {
+				t.Errorf("expected update %v and shrink %v, got %v and %q", test.update, test.shrink, update, message)
+			}
+			size := to.Spec.Resources.Requests[corev1.ResourceStorage]
+			if size.Cmp(resource.MustParse(test.expectedSize)) != 0 {
+				t.Errorf("expected size %s, got %s", test.expectedSize, size.String())
+			}
+			if len(to.OwnerReferences) != test.expectedOwner {
+				t.Errorf("expected %d owners, got %+v", test.expectedOwner, to.OwnerReferences)
+			}
+		})
+	}
+}
+
+func TestReconcileWorkspace(t *testing.T) {
+	r := &NotebookReconciler{
+		Client:        fake.NewFakeClientWithScheme(scheme.Scheme),
+		Log:           ctrl.Log.WithName("test"),
+		Scheme:        scheme.Scheme,
+		EventRecorder: record.NewFakeRecorder(10),
+	}
+	key := types.NamespacedName{Name: "test-notebook-workspace", Namespace: "test-namespace"}
+
+	for _, size := range []string{"10Gi", "20Gi"} {
+		if err := r.reconcileWorkspace(context.TODO(), testWorkspaceNotebook(size)); err != nil {
+			t.Fatal(err)
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(context.TODO(), key, pvc); err != nil {
+			t.Fatal(err)
+		}
+		current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if current.Cmp(resource.MustParse(size)) != 0 {
+			t.Errorf("expected a workspace of %s, got %s", size, current.String())
+		}
+		if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadWriteOnce {
+			t.Errorf("expected a ReadWriteOnce workspace, got %v", pvc.Spec.AccessModes)
+		}
+	}
+}
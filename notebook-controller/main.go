@@ -18,17 +18,22 @@ package main
 import (
 	"flag"
 	"os"
+	// The time zones of notebook schedules, the image has no tzdata.
+	_ "time/tzdata"
 
 	nbv1 "github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1"
 	nbv1alpha1 "github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1alpha1"
 	nbv1beta1 "github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
 	"github.com/AliyunContainerService/data-on-ack/notebook-controller/controllers"
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/culler"
 	controller_metrics "github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/metrics"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -48,27 +53,42 @@ func init() {
 
 func main() {
 	var metricsAddr, leaderElectionNamespace string
-	var enableLeaderElection bool
+	var enableLeaderElection, enableWebhooks bool
+	var webhookPort int
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
 		"Determines the namespace in which the leader election configmap will be created.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the defaulting and validating admission webhooks for notebooks.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443, "The port the admission webhook server binds to.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.Logger(true))
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	cfg := ctrl.GetConfigOrDie()
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
 		Scheme:                  scheme,
 		MetricsBindAddress:      metricsAddr,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionNamespace: leaderElectionNamespace,
 		LeaderElectionID:        "kubeflow-notebook-controller",
+		Port:                    webhookPort,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes client")
+		os.Exit(1)
+	}
+	culler.RegisterProbe(culler.NewUsageProbe(func(path string) ([]byte, error) {
+		return clientset.CoreV1().RESTClient().Get().AbsPath(path).DoRaw()
+	}))
+
 	if err = (&controllers.NotebookReconciler{
 		Client:        mgr.GetClient(),
 		Log:           ctrl.Log.WithName("controllers").WithName("Notebook"),
@@ -86,6 +106,12 @@ func main() {
 	// 	os.Exit(1)
 	// }
 
+	if enableWebhooks {
+		hookServer := mgr.GetWebhookServer()
+		hookServer.Register("/mutate-notebook", &webhook.Admission{Handler: &controllers.NotebookDefaulter{}})
+		hookServer.Register("/validate-notebook", &webhook.Admission{Handler: &controllers.NotebookValidator{Client: mgr.GetClient()}})
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -0,0 +1,173 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is one of the five fields of a cron expression, with the names
+// its values may be given by.
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is Sunday too.
+	{"day of week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// MAX_CRON_YEARS bounds the search of the next time of a cron expression that
+// may never match, such as February 30th.
+const MAX_CRON_YEARS = 5
+
+// cronSchedule is a standard cron expression: minute, hour, day of month,
+// month and day of week, each a bit set of the values it matches.
+type cronSchedule struct {
+	fields [5]uint64
+	// Whether the day of month and day of week are *. When both are
+	// restricted, a day matching either of them matches, as in cron.
+	domStar, dowStar bool
+}
+
+// parseCron parses a five fields cron expression. A field is a comma
+// separated list of *, values and ranges, each with an optional /step.
+func parseCron(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields, it has %d", expr, len(cronFields), len(parts))
+	}
+
+	s := &cronSchedule{}
+	for i, part := range parts {
+		bits, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		s.fields[i] = bits
+	}
+	// Sunday is 0 or 7.
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	s.domStar = strings.HasPrefix(parts[2], "*")
+	s.dowStar = strings.HasPrefix(parts[4], "*")
+	return s, nil
+}
+
+func parseCronField(expr string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rng, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			var err error
+			rng = item[:i]
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q of the %s", item[i+1:], field.name)
+			}
+		}
+
+		low, high := field.min, field.max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if low, err = parseCronValue(bounds[0], field); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseCronValue(bounds[1], field); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a/step is every step from a on.
+				high = field.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q of the %s", rng, field.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, field cronField) (int, error) {
+	for i, name := range field.names {
+		if name != "" && strings.EqualFold(value, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("invalid %s %q, it must be within %d-%d", field.name, value, field.min, field.max)
+	}
+	return v, nil
+}
+
+func (s *cronSchedule) matches(field, value int) bool {
+	return s.fields[field]&(1<<uint(value)) != 0
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.matches(2, t.Day())
+	dow := s.matches(4, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next is the first time after t the expression matches, in the location of
+// t, or the zero time when it does not match within MAX_CRON_YEARS.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + MAX_CRON_YEARS
+
+	for t.Year() <= limit {
+		if !s.matches(3, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matches(1, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matches(0, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
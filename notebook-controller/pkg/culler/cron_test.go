@@ -0,0 +1,86 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	testCases := []struct {
+		testName string
+		expr     string
+		valid    bool
+	}{
+		{"Every minute", "* * * * *", true},
+		{"Workdays at 8", "0 8 * * 1-5", true},
+		{"Names", "30 20 * jan-jun MON,wed,FRI", true},
+		{"Steps", "*/15 9-17/2 1/10 * 7", true},
+		{"Missing field", "0 8 * *", false},
+		{"Hour out of range", "0 24 * * *", false},
+		{"Reversed range", "0 17-9 * * *", false},
+		{"Zero step", "*/0 * * * *", false},
+		{"Unknown name", "0 8 * * mo", false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if _, err := parseCron(c.expr); (err == nil) != c.valid {
+				t.Errorf("expected valid %v, got error %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	// A Friday.
+	friday := time.Date(2021, 8, 27, 19, 30, 15, 0, time.UTC)
+
+	testCases := []struct {
+		testName string
+		expr     string
+		from     time.Time
+		next     time.Time
+	}{
+		{"Next minute", "* * * * *", friday, time.Date(2021, 8, 27, 19, 31, 0, 0, time.UTC)},
+		{"Later today", "0 20 * * 1-5", friday, time.Date(2021, 8, 27, 20, 0, 0, 0, time.UTC)},
+		{"After the weekend", "0 8 * * 1-5", friday, time.Date(2021, 8, 30, 8, 0, 0, 0, time.UTC)},
+		{"Strictly after", "30 19 * * *", time.Date(2021, 8, 27, 19, 30, 0, 0, time.UTC), time.Date(2021, 8, 28, 19, 30, 0, 0, time.UTC)},
+		{"Next month", "0 0 1 * *", friday, time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"Day of month or day of week", "0 0 1 * sun", friday, time.Date(2021, 8, 29, 0, 0, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 0 * * 7", friday, time.Date(2021, 8, 29, 0, 0, 0, 0, time.UTC)},
+		{"Leap day", "0 0 29 2 *", friday, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"Never", "0 0 30 2 *", friday, time.Time{}},
+		{"Time zone", "0 8 * * *", friday.In(shanghai), time.Date(2021, 8, 28, 8, 0, 0, 0, shanghai)},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			s, err := parseCron(c.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next := s.next(c.from); !next.Equal(c.next) {
+				t.Errorf("expected %v, got %v", c.next, next)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/metrics"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -58,6 +59,11 @@ type KernelStatus struct {
 	Connections    int    `json:"connections"`
 }
 
+type TerminalStatus struct {
+	Name         string `json:"name"`
+	LastActivity string `json:"last_activity"`
+}
+
 func getEnvDefault(variable string, defaultVal string) string {
 	envVar := os.Getenv(variable)
 	if len(envVar) == 0 {
@@ -78,6 +84,35 @@ func createTimestamp() string {
 	return now.Format(time.RFC3339)
 }
 
+// notebookServiceURL returns the URL of path on the service of the notebook.
+// It is a variable so that tests can point it to a local server.
+var notebookServiceURL = func(nm, ns, path string) string {
+	if getEnvDefault("DEV", DEFAULT_DEV) != "false" {
+		return fmt.Sprintf(
+			"http://localhost:8001/api/v1/namespaces/%s/services/%s:http-%s/proxy%s",
+			ns, nm, nm, path)
+	}
+	domain := getEnvDefault("CLUSTER_DOMAIN", DEFAULT_CLUSTER_DOMAIN)
+	return fmt.Sprintf("http://%s.%s.svc.%s%s", nm, ns, domain, path)
+}
+
+func getJSON(url string, into interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("error talking to %s: %v", url, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET to %s: %d", url, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("error parsing JSON response of %s: %v", url, err)
+	}
+	return nil
+}
+
 func GetRequeueTime() time.Duration {
 	cullingPeriod := getEnvDefault(
 		"IDLENESS_CHECK_PERIOD", DEFAULT_IDLENESS_CHECK_PERIOD)
@@ -142,40 +177,22 @@ func StopAnnotationIsSet(meta metav1.ObjectMeta) bool {
 	}
 }
 
-func getNotebookApiKernels(nm, ns string) []KernelStatus {
-
-	domain := getEnvDefault("CLUSTER_DOMAIN", DEFAULT_CLUSTER_DOMAIN)
-	url := fmt.Sprintf(
-		"http://%s.%s.svc.%s/notebook/%s/%s/api/kernels",
-		nm, ns, domain, ns, nm)
-	if getEnvDefault("DEV", DEFAULT_DEV) != "false" {
-		url = fmt.Sprintf(
-			"http://localhost:8001/api/v1/namespaces/%s/services/%s:http-%s/proxy/notebook/%s/%s/api/kernels",
-			ns, nm, nm, ns, nm)
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		log.Error(err, fmt.Sprintf("Error talking to %s", url))
-		return nil
-	}
-
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		log.Info(fmt.Sprintf(
-			"Warning: GET to %s: %d", url, resp.StatusCode))
-		return nil
-	}
-
+func getNotebookApiKernels(nm, ns string) ([]KernelStatus, error) {
 	var kernels []KernelStatus
-
-	err = json.NewDecoder(resp.Body).Decode(&kernels)
-	if err != nil {
-		log.Error(err, "Error parsing JSON response for Notebook API Kernels.")
-		return nil
+	url := notebookServiceURL(nm, ns, fmt.Sprintf("/notebook/%s/%s/api/kernels", ns, nm))
+	if err := getJSON(url, &kernels); err != nil {
+		return nil, err
 	}
+	return kernels, nil
+}
 
-	return kernels
+func getNotebookApiTerminals(nm, ns string) ([]TerminalStatus, error) {
+	var terminals []TerminalStatus
+	url := notebookServiceURL(nm, ns, fmt.Sprintf("/notebook/%s/%s/api/terminals", ns, nm))
+	if err := getJSON(url, &terminals); err != nil {
+		return nil, err
+	}
+	return terminals, nil
 }
 
 func allKernelsAreIdle(kernels []KernelStatus, log logr.Logger) bool {
@@ -195,7 +212,9 @@ func allKernelsAreIdle(kernels []KernelStatus, log logr.Logger) bool {
 	return true
 }
 
-func UpdateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta) bool {
+// UpdateNotebookLastActivityAnnotation refreshes the last-activity annotation
+// from the activity probes of the notebook. pod is the running notebook pod.
+func UpdateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta, pod *corev1.Pod) bool {
 	log := log.WithValues("notebook", getNamespacedNameFromMeta(*meta))
 	if meta == nil {
 		log.Info("Metadata is Nil. Can't update Last Activity Annotation.")
@@ -203,7 +222,6 @@ func UpdateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta) bool {
 	}
 
 	log.Info("Updating the last-activity annotation.")
-	nm, ns := meta.GetName(), meta.GetNamespace()
 
 	if _, ok := meta.GetAnnotations()[LAST_ACTIVITY_ANNOTATION]; !ok {
 		t := createTimestamp()
@@ -216,56 +234,49 @@ func UpdateNotebookLastActivityAnnotation(meta *metav1.ObjectMeta) bool {
 		return true
 	}
 
-	log.Info("last-activity annotation exists. Checking /api/kernels")
-	kernels := getNotebookApiKernels(nm, ns)
-	if kernels == nil {
-		log.Info("Could not GET the kernels status. Will not update last-activity.")
+	log.Info("last-activity annotation exists. Probing the Notebook activity")
+	activity := probeActivity(Target{Meta: *meta, Pod: pod})
+	if activity == nil {
+		log.Info("Could not probe the Notebook activity. Will not update last-activity.")
 		return false
 	}
 
-	return updateTimestampFromKernelsActivity(meta, kernels)
+	return updateTimestampFromActivity(meta, activity)
 }
 
-func updateTimestampFromKernelsActivity(meta *metav1.ObjectMeta, kernels []KernelStatus) bool {
+// updateTimestampFromActivity moves the last-activity annotation forward, it
+// never goes back so that an older timestamp reported by one probe does not
+// hide the activity seen by another one.
+func updateTimestampFromActivity(meta *metav1.ObjectMeta, activity *Activity) bool {
 	log := log.WithValues("notebook", getNamespacedNameFromMeta(*meta))
 
-	if len(kernels) == 0 {
-		log.Info("Notebook has no kernels. Will not update last-activity")
-		return false
+	recentTime := activity.LastActivity
+	if activity.Busy {
+		log.Info("Notebook is busy")
+		recentTime = time.Now()
 	}
-
-	if !allKernelsAreIdle(kernels, log) {
-		t := createTimestamp()
-		log.Info(fmt.Sprintf("Found a busy kernel. Updating the last-activity to %s", t))
-
-		meta.Annotations[LAST_ACTIVITY_ANNOTATION] = t
-		return true
+	if recentTime.IsZero() {
+		log.Info("Notebook reported no activity. Will not update last-activity")
+		return false
 	}
 
-	recentTime, err := time.Parse(time.RFC3339, kernels[0].LastActivity)
-	if err != nil {
-		log.Error(err, "Error parsing the last-activity from the /api/kernels")
+	if current, err := time.Parse(time.RFC3339, meta.Annotations[LAST_ACTIVITY_ANNOTATION]); err == nil &&
+		!recentTime.Truncate(time.Second).After(current) {
+		log.Info("No activity since the last-activity annotation")
 		return false
 	}
 
-	for i := 1; i < len(kernels); i++ {
-		kernelLastActivity, err := time.Parse(time.RFC3339, kernels[i].LastActivity)
-		if err != nil {
-			log.Error(err, "Error parsing the last-activity from the /api/kernels")
-			return false
-		}
-		if kernelLastActivity.After(recentTime) {
-			recentTime = kernelLastActivity
-		}
-	}
 	t := recentTime.Format(time.RFC3339)
-
 	meta.Annotations[LAST_ACTIVITY_ANNOTATION] = t
-	log.Info(fmt.Sprintf("Successfully updated last-activity from latest kernel action, %s", t))
+	log.Info(fmt.Sprintf("Successfully updated last-activity to %s", t))
 	return true
 }
 
 func notebookIsIdle(meta metav1.ObjectMeta) bool {
+	return notebookIsIdleFor(meta, getMaxIdleTime())
+}
+
+func notebookIsIdleFor(meta metav1.ObjectMeta, maxIdleTime time.Duration) bool {
 	log := log.WithValues("notebook", getNamespacedNameFromMeta(meta))
 
 	if meta.GetAnnotations() != nil {
@@ -277,7 +288,7 @@ func notebookIsIdle(meta metav1.ObjectMeta) bool {
 			return false
 		}
 
-		timeCap := LastActivity.Add(getMaxIdleTime())
+		timeCap := LastActivity.Add(maxIdleTime)
 		if time.Now().After(timeCap) {
 			return true
 		}
@@ -285,19 +296,8 @@ func notebookIsIdle(meta metav1.ObjectMeta) bool {
 	return false
 }
 
+// NotebookNeedsCulling tells whether the notebook should be stopped according
+// to the cluster wide policy of the controller env.
 func NotebookNeedsCulling(meta metav1.ObjectMeta) bool {
-	log := log.WithValues("notebook", getNamespacedNameFromMeta(meta))
-
-	if getEnvDefault("ENABLE_CULLING", DEFAULT_ENABLE_CULLING) != "true" {
-		log.Info("Culling of idle Pods is Disabled. To enable it set the " +
-			"ENV Var 'ENABLE_CULLING=true'")
-		return false
-	}
-
-	if StopAnnotationIsSet(meta) {
-		log.Info("Notebook is already stopping")
-		return false
-	}
-
-	return notebookIsIdle(meta)
+	return PolicyFromEnv().NeedsCulling(meta, nil)
 }
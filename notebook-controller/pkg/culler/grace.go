@@ -0,0 +1,178 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const DEFAULT_CULLING_GRACE_PERIOD = "10"
+const DEFAULT_CULLING_SNOOZE_TIME = "60"
+
+// SNOOZE_ANNOTATION postpones culling. Its value is a duration, e.g. 30m, or
+// empty for CULLING_SNOOZE_TIME. The controller removes it once applied.
+const SNOOZE_ANNOTATION = "notebooks.kubeflow.org/snooze-culling"
+
+// Culling actions, the non empty ones label the notebook_culling_events_total metric.
+const (
+	CULLING_ACTION_NONE   = ""
+	CULLING_ACTION_WAIT   = "wait"
+	CULLING_ACTION_CANCEL = "cancelled"
+	CULLING_ACTION_WARN   = "warned"
+	CULLING_ACTION_SNOOZE = "snoozed"
+	CULLING_ACTION_CULL   = "culled"
+)
+
+// Conditions of a notebook the culler wants to stop. At most one of them is
+// kept, under the condition of the notebook state, and it is replaced as
+// culling goes on.
+const (
+	CONDITION_CULLING_SCHEDULED = "CullingScheduled"
+	CONDITION_CULLING_SNOOZED   = "CullingSnoozed"
+)
+
+func getGracePeriod() time.Duration {
+	return getEnvMinutes("CULLING_GRACE_PERIOD", DEFAULT_CULLING_GRACE_PERIOD)
+}
+
+func getSnoozeTime() time.Duration {
+	return getEnvMinutes("CULLING_SNOOZE_TIME", DEFAULT_CULLING_SNOOZE_TIME)
+}
+
+func getEnvMinutes(variable, defaultVal string) time.Duration {
+	value := getEnvDefault(variable, defaultVal)
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		log.Info(fmt.Sprintf(
+			"%s should be a positive Int. Got '%s'. Using default value.",
+			variable, value))
+		minutes, _ = strconv.Atoi(defaultVal)
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// SnoozeRequested tells whether a user asked to postpone culling with
+// SNOOZE_ANNOTATION, and for how long.
+func SnoozeRequested(meta metav1.ObjectMeta) (time.Duration, bool) {
+	value, ok := meta.GetAnnotations()[SNOOZE_ANNOTATION]
+	if !ok {
+		return 0, false
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return getSnoozeTime(), true
+	}
+	snooze, err := time.ParseDuration(value)
+	if err != nil || snooze <= 0 {
+		log.Info(fmt.Sprintf(
+			"%s should be a positive duration. Got '%s'. Using default value.",
+			SNOOZE_ANNOTATION, value))
+		return getSnoozeTime(), true
+	}
+	return snooze, true
+}
+
+// NextCullingAction decides what to do with a notebook the policy wants to
+// cull for reason, or wants to keep when reason is empty. A notebook is
+// warned and given the grace period of the policy before it is culled, unless
+// a user snoozed culling. wait is how long the notebook has left in the
+// current state.
+func (p Policy) NextCullingAction(reason string, status *v1beta1.NotebookStatus, now time.Time) (action string, wait time.Duration) {
+	if reason == "" {
+		if status.CullingScheduledAt != nil {
+			return CULLING_ACTION_CANCEL, 0
+		}
+		return CULLING_ACTION_NONE, 0
+	}
+
+	if status.CullingSnoozedUntil != nil && now.Before(status.CullingSnoozedUntil.Time) {
+		return CULLING_ACTION_WAIT, status.CullingSnoozedUntil.Sub(now)
+	}
+
+	if status.CullingScheduledAt == nil {
+		if p.GracePeriod <= 0 {
+			return CULLING_ACTION_CULL, 0
+		}
+		return CULLING_ACTION_WARN, p.GracePeriod
+	}
+
+	if now.Before(status.CullingScheduledAt.Time) {
+		return CULLING_ACTION_WAIT, status.CullingScheduledAt.Sub(now)
+	}
+	return CULLING_ACTION_CULL, 0
+}
+
+// MarkCullingScheduled records in the status that the notebook is warned and
+// will be stopped at scheduledAt for reason.
+func MarkCullingScheduled(status *v1beta1.NotebookStatus, reason string, scheduledAt metav1.Time, now time.Time) {
+	ClearCullingStatus(status)
+	status.CullingScheduledAt = &scheduledAt
+	status.CullingReason = reason
+	setCullingCondition(status, v1beta1.NotebookCondition{
+		Type:          CONDITION_CULLING_SCHEDULED,
+		LastProbeTime: metav1.NewTime(now),
+		Reason:        reason,
+		Message: fmt.Sprintf("Notebook will be stopped at %s, set the %s annotation to postpone it",
+			scheduledAt.Format(time.RFC3339), SNOOZE_ANNOTATION),
+	})
+}
+
+// MarkCullingSnoozed records in the status that a user postponed culling until
+// the given time.
+func MarkCullingSnoozed(status *v1beta1.NotebookStatus, until metav1.Time, now time.Time) {
+	ClearCullingStatus(status)
+	status.CullingSnoozedUntil = &until
+	setCullingCondition(status, v1beta1.NotebookCondition{
+		Type:          CONDITION_CULLING_SNOOZED,
+		LastProbeTime: metav1.NewTime(now),
+		Reason:        STOP_REASON_USER,
+		Message:       fmt.Sprintf("Culling postponed until %s", until.Format(time.RFC3339)),
+	})
+}
+
+// setCullingCondition puts the culling condition under the first condition,
+// which remains the state of the notebook read by the console and compared
+// with the next container state.
+func setCullingCondition(status *v1beta1.NotebookStatus, condition v1beta1.NotebookCondition) {
+	if len(status.Conditions) == 0 {
+		status.Conditions = []v1beta1.NotebookCondition{condition}
+		return
+	}
+	conditions := make([]v1beta1.NotebookCondition, 0, len(status.Conditions)+1)
+	conditions = append(conditions, status.Conditions[0], condition)
+	status.Conditions = append(conditions, status.Conditions[1:]...)
+}
+
+// ClearCullingStatus forgets that the notebook was warned or snoozed, along
+// with the condition telling it.
+func ClearCullingStatus(status *v1beta1.NotebookStatus) {
+	status.CullingScheduledAt = nil
+	status.CullingReason = ""
+	status.CullingSnoozedUntil = nil
+	conditions := status.Conditions[:0]
+	for _, condition := range status.Conditions {
+		if condition.Type != CONDITION_CULLING_SCHEDULED && condition.Type != CONDITION_CULLING_SNOOZED {
+			conditions = append(conditions, condition)
+		}
+	}
+	status.Conditions = conditions
+}
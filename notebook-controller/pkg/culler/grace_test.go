@@ -0,0 +1,226 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextCullingAction(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	policy := Policy{Enabled: true, IdleTime: time.Hour, GracePeriod: 10 * time.Minute}
+
+	testCases := []struct {
+		testName string
+		policy   Policy
+		reason   string
+		status   v1beta1.NotebookStatus
+		action   string
+		wait     time.Duration
+	}{
+		{
+			testName: "Notebook in use",
+			policy:   policy,
+			action:   CULLING_ACTION_NONE,
+		},
+		{
+			testName: "Idle notebook is warned first",
+			policy:   policy,
+			reason:   CULLING_REASON_IDLE,
+			action:   CULLING_ACTION_WARN,
+			wait:     10 * time.Minute,
+		},
+		{
+			testName: "Idle notebook without grace period",
+			policy:   Policy{Enabled: true, IdleTime: time.Hour},
+			reason:   CULLING_REASON_IDLE,
+			action:   CULLING_ACTION_CULL,
+		},
+		{
+			testName: "Warned notebook within the grace period",
+			policy:   policy,
+			reason:   CULLING_REASON_IDLE,
+			status:   v1beta1.NotebookStatus{CullingScheduledAt: at(4 * time.Minute)},
+			action:   CULLING_ACTION_WAIT,
+			wait:     4 * time.Minute,
+		},
+		{
+			testName: "Warned notebook after the grace period",
+			policy:   policy,
+			reason:   CULLING_REASON_MAX_LIFETIME,
+			status:   v1beta1.NotebookStatus{CullingScheduledAt: at(-time.Second)},
+			action:   CULLING_ACTION_CULL,
+		},
+		{
+			testName: "Warned notebook in use again",
+			policy:   policy,
+			status:   v1beta1.NotebookStatus{CullingScheduledAt: at(4 * time.Minute)},
+			action:   CULLING_ACTION_CANCEL,
+		},
+		{
+			testName: "Snoozed notebook",
+			policy:   policy,
+			reason:   CULLING_REASON_IDLE,
+			status:   v1beta1.NotebookStatus{CullingSnoozedUntil: at(30 * time.Minute)},
+			action:   CULLING_ACTION_WAIT,
+			wait:     30 * time.Minute,
+		},
+		{
+			testName: "Snooze is over",
+			policy:   policy,
+			reason:   CULLING_REASON_IDLE,
+			status:   v1beta1.NotebookStatus{CullingSnoozedUntil: at(-time.Minute)},
+			action:   CULLING_ACTION_WARN,
+			wait:     10 * time.Minute,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			action, wait := c.policy.NextCullingAction(c.reason, &c.status, now)
+			if action != c.action || wait != c.wait {
+				t.Errorf("Expected %q after %s, got %q after %s", c.action, c.wait, action, wait)
+			}
+		})
+	}
+}
+
+func TestSnoozeRequested(t *testing.T) {
+	os.Setenv("CULLING_SNOOZE_TIME", "60")
+
+	testCases := []struct {
+		testName    string
+		annotations map[string]string
+		snooze      time.Duration
+		requested   bool
+	}{
+		{
+			testName:  "No annotation",
+			requested: false,
+		},
+		{
+			testName:    "Default snooze time",
+			annotations: map[string]string{SNOOZE_ANNOTATION: ""},
+			snooze:      time.Hour,
+			requested:   true,
+		},
+		{
+			testName:    "Snooze time of the annotation",
+			annotations: map[string]string{SNOOZE_ANNOTATION: "2h"},
+			snooze:      2 * time.Hour,
+			requested:   true,
+		},
+		{
+			testName:    "Invalid snooze time",
+			annotations: map[string]string{SNOOZE_ANNOTATION: "later"},
+			snooze:      time.Hour,
+			requested:   true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			snooze, requested := SnoozeRequested(metav1.ObjectMeta{Annotations: c.annotations})
+			if snooze != c.snooze || requested != c.requested {
+				t.Errorf("Expected %s, %t, got %s, %t", c.snooze, c.requested, snooze, requested)
+			}
+		})
+	}
+}
+
+func TestCullingCondition(t *testing.T) {
+	now := time.Now()
+	at := metav1.NewTime(now.Add(10 * time.Minute).Truncate(time.Second))
+	running := v1beta1.NotebookCondition{Type: "Running"}
+	scheduled := v1beta1.NotebookCondition{Type: CONDITION_CULLING_SCHEDULED, Reason: CULLING_REASON_IDLE}
+	snoozed := v1beta1.NotebookCondition{Type: CONDITION_CULLING_SNOOZED}
+
+	testCases := []struct {
+		testName   string
+		conditions []v1beta1.NotebookCondition
+		mark       func(status *v1beta1.NotebookStatus)
+		condition  string
+		message    string
+		remaining  int
+	}{
+		{
+			testName:   "Warned",
+			conditions: []v1beta1.NotebookCondition{running},
+			mark: func(status *v1beta1.NotebookStatus) {
+				MarkCullingScheduled(status, CULLING_REASON_IDLE, at, now)
+			},
+			condition: CONDITION_CULLING_SCHEDULED,
+			message:   "Notebook will be stopped at " + at.Format(time.RFC3339),
+			remaining: 2,
+		},
+		{
+			testName:   "Warned again after a snooze",
+			conditions: []v1beta1.NotebookCondition{running, snoozed},
+			mark: func(status *v1beta1.NotebookStatus) {
+				MarkCullingScheduled(status, CULLING_REASON_IDLE, at, now)
+			},
+			condition: CONDITION_CULLING_SCHEDULED,
+			message:   "Notebook will be stopped at " + at.Format(time.RFC3339),
+			remaining: 2,
+		},
+		{
+			testName:   "Snoozed",
+			conditions: []v1beta1.NotebookCondition{running, scheduled},
+			mark: func(status *v1beta1.NotebookStatus) {
+				MarkCullingSnoozed(status, at, now)
+			},
+			condition: CONDITION_CULLING_SNOOZED,
+			message:   "Culling postponed until " + at.Format(time.RFC3339),
+			remaining: 2,
+		},
+		{
+			testName:   "Cancelled under a container condition",
+			conditions: []v1beta1.NotebookCondition{running, scheduled},
+			mark:       ClearCullingStatus,
+			remaining:  1,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			status := v1beta1.NotebookStatus{Conditions: append([]v1beta1.NotebookCondition{}, c.conditions...)}
+			c.mark(&status)
+
+			if len(status.Conditions) != c.remaining || status.Conditions[0].Type != running.Type {
+				t.Fatalf("Expected %d conditions with %s on top, got %v", c.remaining, running.Type, status.Conditions)
+			}
+			if c.condition == "" {
+				return
+			}
+			if status.Conditions[1].Type != c.condition {
+				t.Fatalf("Expected %s under %s, got %v", c.condition, running.Type, status.Conditions)
+			}
+			if !strings.HasPrefix(status.Conditions[1].Message, c.message) {
+				t.Errorf("Expected message starting with %q, got %q", c.message, status.Conditions[1].Message)
+			}
+		})
+	}
+}
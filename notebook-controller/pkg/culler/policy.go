@@ -0,0 +1,191 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const DEFAULT_CULLING_POLICY_CONFIGMAP = "notebook-culling-policy"
+
+// Keys of the namespace ConfigMap. Durations use the Go syntax, e.g. 1h or 168h.
+const (
+	POLICY_KEY_ENABLED          = "enabled"
+	POLICY_KEY_IDLE_TIMEOUT     = "idleTimeout"
+	POLICY_KEY_MAX_LIFETIME     = "maxLifetime"
+	POLICY_KEY_GPU_IDLE_TIMEOUT = "gpuIdleTimeout"
+	POLICY_KEY_GPU_MAX_LIFETIME = "gpuMaxLifetime"
+	POLICY_KEY_GRACE_PERIOD     = "gracePeriod"
+)
+
+const (
+	CULLING_REASON_IDLE         = "Idle"
+	CULLING_REASON_MAX_LIFETIME = "MaxLifetime"
+)
+
+// Policy is the culling policy of a notebook. It is resolved from the
+// controller env, the ConfigMap of the notebook namespace and the culling
+// policy of the notebook spec, each one overriding the previous one.
+type Policy struct {
+	Enabled     bool
+	IdleTime    time.Duration
+	MaxLifetime time.Duration
+	// GracePeriod is the time between the culling warning and the stop.
+	GracePeriod time.Duration
+}
+
+// GetPolicyConfigMapName returns the name of the ConfigMap holding the
+// culling defaults of a namespace.
+func GetPolicyConfigMapName() string {
+	return getEnvDefault("CULLING_POLICY_CONFIGMAP", DEFAULT_CULLING_POLICY_CONFIGMAP)
+}
+
+// PolicyFromEnv returns the cluster wide policy set by ENABLE_CULLING,
+// CULL_IDLE_TIME and CULLING_GRACE_PERIOD.
+func PolicyFromEnv() Policy {
+	return Policy{
+		Enabled:     getEnvDefault("ENABLE_CULLING", DEFAULT_ENABLE_CULLING) == "true",
+		IdleTime:    getMaxIdleTime(),
+		GracePeriod: getGracePeriod(),
+	}
+}
+
+// WithNamespaceDefaults applies the ConfigMap of the notebook namespace, the
+// gpu keys take precedence for notebooks requesting GPUs. Invalid values are
+// logged and ignored.
+func (p Policy) WithNamespaceDefaults(cm *corev1.ConfigMap, gpu bool) Policy {
+	if cm == nil {
+		return p
+	}
+	if value, ok := cm.Data[POLICY_KEY_ENABLED]; ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Info(fmt.Sprintf(
+				"%s/%s: %s should be a bool. Got '%s'. Ignoring it.",
+				cm.Namespace, cm.Name, POLICY_KEY_ENABLED, value))
+		} else {
+			p.Enabled = enabled
+		}
+	}
+	p.IdleTime = configMapDuration(cm, POLICY_KEY_IDLE_TIMEOUT, p.IdleTime, false)
+	p.MaxLifetime = configMapDuration(cm, POLICY_KEY_MAX_LIFETIME, p.MaxLifetime, true)
+	p.GracePeriod = configMapDuration(cm, POLICY_KEY_GRACE_PERIOD, p.GracePeriod, true)
+	if gpu {
+		p.IdleTime = configMapDuration(cm, POLICY_KEY_GPU_IDLE_TIMEOUT, p.IdleTime, false)
+		p.MaxLifetime = configMapDuration(cm, POLICY_KEY_GPU_MAX_LIFETIME, p.MaxLifetime, true)
+	}
+	return p
+}
+
+func configMapDuration(cm *corev1.ConfigMap, key string, current time.Duration, allowZero bool) time.Duration {
+	value, ok := cm.Data[key]
+	if !ok {
+		return current
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 || (d == 0 && !allowZero) {
+		log.Info(fmt.Sprintf(
+			"%s/%s: %s should be a positive duration. Got '%s'. Ignoring it.",
+			cm.Namespace, cm.Name, key, value))
+		return current
+	}
+	return d
+}
+
+// WithSpec applies the culling policy set on the notebook.
+func (p Policy) WithSpec(spec *v1beta1.CullingPolicy) Policy {
+	if spec == nil {
+		return p
+	}
+	if spec.Enabled != nil {
+		p.Enabled = *spec.Enabled
+	}
+	if spec.IdleTimeout != nil && spec.IdleTimeout.Duration > 0 {
+		p.IdleTime = spec.IdleTimeout.Duration
+	}
+	if spec.MaxLifetime != nil && spec.MaxLifetime.Duration >= 0 {
+		p.MaxLifetime = spec.MaxLifetime.Duration
+	}
+	return p
+}
+
+// CullingReason tells why the notebook should be stopped, or returns an empty
+// string when it should keep running. startTime is the start time of the
+// notebook pod, the max lifetime is not enforced without it.
+func (p Policy) CullingReason(meta metav1.ObjectMeta, startTime *metav1.Time) string {
+	log := log.WithValues("notebook", getNamespacedNameFromMeta(meta))
+
+	if !p.Enabled {
+		log.Info("Culling of idle Pods is Disabled. To enable it set the " +
+			"ENV Var 'ENABLE_CULLING=true', the namespace ConfigMap or the culling policy of the Notebook")
+		return ""
+	}
+
+	if StopAnnotationIsSet(meta) {
+		log.Info("Notebook is already stopping")
+		return ""
+	}
+
+	if p.MaxLifetime > 0 && startTime != nil && time.Now().After(startTime.Add(p.MaxLifetime)) {
+		log.Info(fmt.Sprintf("Notebook has been running for more than %s", p.MaxLifetime))
+		return CULLING_REASON_MAX_LIFETIME
+	}
+
+	if notebookIsIdleFor(meta, p.IdleTime) {
+		return CULLING_REASON_IDLE
+	}
+	return ""
+}
+
+// NeedsCulling tells whether the notebook should be stopped.
+func (p Policy) NeedsCulling(meta metav1.ObjectMeta, startTime *metav1.Time) bool {
+	return p.CullingReason(meta, startTime) != ""
+}
+
+// RequeueAfter returns when the notebook should be checked again, which is
+// earlier than the idleness check period when its max lifetime ends before.
+func (p Policy) RequeueAfter(startTime *metav1.Time) time.Duration {
+	requeue := GetRequeueTime()
+	if !p.Enabled || p.MaxLifetime <= 0 || startTime == nil {
+		return requeue
+	}
+	if left := time.Until(startTime.Add(p.MaxLifetime)); left > 0 && left < requeue {
+		return left
+	}
+	return requeue
+}
+
+// PodSpecRequestsGPU tells whether a container of the pod requests or is
+// limited to a GPU resource, such as nvidia.com/gpu or aliyun.com/gpu-mem.
+func PodSpecRequestsGPU(spec *corev1.PodSpec) bool {
+	for _, container := range spec.Containers {
+		for _, resources := range []corev1.ResourceList{container.Resources.Limits, container.Resources.Requests} {
+			for name, quantity := range resources {
+				if strings.Contains(string(name), "gpu") && !quantity.IsZero() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPolicyResolution(t *testing.T) {
+	enabled, disabled := true, false
+	namespaceDefaults := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: DEFAULT_CULLING_POLICY_CONFIGMAP, Namespace: "test"},
+		Data: map[string]string{
+			POLICY_KEY_ENABLED:          "true",
+			POLICY_KEY_IDLE_TIMEOUT:     "168h",
+			POLICY_KEY_GPU_IDLE_TIMEOUT: "1h",
+		},
+	}
+	base := Policy{Enabled: false, IdleTime: 24 * time.Hour}
+
+	testCases := []struct {
+		testName  string
+		configMap *corev1.ConfigMap
+		gpu       bool
+		spec      *v1beta1.CullingPolicy
+		result    Policy
+	}{
+		{
+			testName: "No namespace defaults and no spec",
+			result:   base,
+		},
+		{
+			testName:  "CPU notebook with namespace defaults",
+			configMap: namespaceDefaults,
+			result:    Policy{Enabled: true, IdleTime: 168 * time.Hour},
+		},
+		{
+			testName:  "GPU notebook with namespace defaults",
+			configMap: namespaceDefaults,
+			gpu:       true,
+			result:    Policy{Enabled: true, IdleTime: time.Hour},
+		},
+		{
+			testName:  "Notebook opts out",
+			configMap: namespaceDefaults,
+			gpu:       true,
+			spec:      &v1beta1.CullingPolicy{Enabled: &disabled},
+			result:    Policy{Enabled: false, IdleTime: time.Hour},
+		},
+		{
+			testName: "Notebook opts in with its own timeouts",
+			spec: &v1beta1.CullingPolicy{
+				Enabled:     &enabled,
+				IdleTimeout: &metav1.Duration{Duration: 30 * time.Minute},
+				MaxLifetime: &metav1.Duration{Duration: 8 * time.Hour},
+			},
+			result: Policy{Enabled: true, IdleTime: 30 * time.Minute, MaxLifetime: 8 * time.Hour},
+		},
+		{
+			testName: "Invalid namespace defaults are ignored",
+			configMap: &corev1.ConfigMap{
+				Data: map[string]string{
+					POLICY_KEY_ENABLED:      "maybe",
+					POLICY_KEY_IDLE_TIMEOUT: "0s",
+					POLICY_KEY_MAX_LIFETIME: "-1h",
+				},
+			},
+			result: base,
+		},
+		{
+			testName:  "Non positive idle timeout in the spec is ignored",
+			configMap: namespaceDefaults,
+			spec:      &v1beta1.CullingPolicy{IdleTimeout: &metav1.Duration{}},
+			result:    Policy{Enabled: true, IdleTime: 168 * time.Hour},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			policy := base.WithNamespaceDefaults(c.configMap, c.gpu).WithSpec(c.spec)
+			if policy != c.result {
+				t.Errorf("Expected %+v, got %+v", c.result, policy)
+			}
+		})
+	}
+}
+
+func TestCullingReason(t *testing.T) {
+	idleFor := func(d time.Duration) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Annotations: map[string]string{
+				LAST_ACTIVITY_ANNOTATION: time.Now().Add(-d).Format(time.RFC3339),
+			},
+		}
+	}
+	startedAgo := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(time.Now().Add(-d))
+		return &t
+	}
+
+	testCases := []struct {
+		testName  string
+		policy    Policy
+		meta      metav1.ObjectMeta
+		startTime *metav1.Time
+		result    string
+	}{
+		{
+			testName:  "Culling is disabled",
+			policy:    Policy{Enabled: false, IdleTime: time.Hour, MaxLifetime: time.Hour},
+			meta:      idleFor(2 * time.Hour),
+			startTime: startedAgo(2 * time.Hour),
+			result:    "",
+		},
+		{
+			testName:  "Idle for longer than the idle timeout",
+			policy:    Policy{Enabled: true, IdleTime: time.Hour},
+			meta:      idleFor(2 * time.Hour),
+			startTime: startedAgo(2 * time.Hour),
+			result:    CULLING_REASON_IDLE,
+		},
+		{
+			testName:  "Busy notebook past its max lifetime",
+			policy:    Policy{Enabled: true, IdleTime: time.Hour, MaxLifetime: 8 * time.Hour},
+			meta:      idleFor(time.Minute),
+			startTime: startedAgo(9 * time.Hour),
+			result:    CULLING_REASON_MAX_LIFETIME,
+		},
+		{
+			testName:  "Busy notebook within its max lifetime",
+			policy:    Policy{Enabled: true, IdleTime: time.Hour, MaxLifetime: 8 * time.Hour},
+			meta:      idleFor(time.Minute),
+			startTime: startedAgo(7 * time.Hour),
+			result:    "",
+		},
+		{
+			testName: "Max lifetime without a pod start time",
+			policy:   Policy{Enabled: true, IdleTime: time.Hour, MaxLifetime: 8 * time.Hour},
+			meta:     idleFor(time.Minute),
+			result:   "",
+		},
+		{
+			testName: "Stop Annotation already set",
+			policy:   Policy{Enabled: true, IdleTime: time.Hour, MaxLifetime: 8 * time.Hour},
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					STOP_ANNOTATION:          time.Now().Format(time.RFC3339),
+					LAST_ACTIVITY_ANNOTATION: time.Now().Add(-2 * time.Hour).Format(time.RFC3339),
+				},
+			},
+			startTime: startedAgo(9 * time.Hour),
+			result:    "",
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if reason := c.policy.CullingReason(c.meta, c.startTime); reason != c.result {
+				t.Errorf("Expected reason %q, got %q", c.result, reason)
+			}
+		})
+	}
+}
+
+func TestPodSpecRequestsGPU(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		resources corev1.ResourceRequirements
+		result    bool
+	}{
+		{
+			testName: "CPU only",
+			resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			result: false,
+		},
+		{
+			testName: "nvidia.com/gpu limit",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+			},
+			result: true,
+		},
+		{
+			testName: "Shared GPU memory",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{"aliyun.com/gpu-mem": resource.MustParse("8")},
+			},
+			result: true,
+		},
+		{
+			testName: "Zero GPUs",
+			resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("0")},
+			},
+			result: false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			spec := &corev1.PodSpec{Containers: []corev1.Container{{Resources: c.resources}}}
+			if PodSpecRequestsGPU(spec) != c.result {
+				t.Errorf("Wrong result for case: %+v", c)
+			}
+		})
+	}
+}
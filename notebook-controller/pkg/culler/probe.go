@@ -0,0 +1,283 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ACTIVITY_PROBE_ANNOTATION selects the activity probes of a notebook, as a
+// comma separated list of probe names, e.g. "code-server,usage".
+const ACTIVITY_PROBE_ANNOTATION = "notebooks.kubeflow.org/activity-probe"
+
+// ACTIVITY_ENDPOINT_ANNOTATION is the path on the notebook service queried by
+// the http probe.
+const ACTIVITY_ENDPOINT_ANNOTATION = "notebooks.kubeflow.org/activity-endpoint"
+
+// NOTEBOOK_TYPE_LABEL is set by the console to jupyter, vscode or sd.
+const NOTEBOOK_TYPE_LABEL = "notebook-type"
+
+const (
+	PROBE_JUPYTER     = "jupyter"
+	PROBE_CODE_SERVER = "code-server"
+	PROBE_HTTP        = "http"
+	PROBE_USAGE       = "usage"
+)
+
+// Activity is what a probe found out about a notebook. Busy means the
+// notebook is in use right now, otherwise LastActivity is the last time it
+// was, or zero when the probe cannot tell.
+type Activity struct {
+	Busy         bool
+	LastActivity time.Time
+}
+
+// Target is the notebook an activity probe checks.
+type Target struct {
+	Meta metav1.ObjectMeta
+	// Pod is the running notebook pod, it may be nil.
+	Pod *corev1.Pod
+}
+
+// ActivityProbe reports the activity of a kind of notebook server. Probe
+// returns a nil Activity when the server has nothing to report.
+type ActivityProbe interface {
+	Name() string
+	Probe(target Target) (*Activity, error)
+}
+
+var (
+	probesLock sync.RWMutex
+	probes     = map[string]ActivityProbe{}
+)
+
+func init() {
+	RegisterProbe(jupyterProbe{})
+	RegisterProbe(codeServerProbe{})
+	RegisterProbe(httpProbe{})
+}
+
+// RegisterProbe makes a probe available to the notebooks, it replaces a
+// probe registered with the same name.
+func RegisterProbe(probe ActivityProbe) {
+	probesLock.Lock()
+	defer probesLock.Unlock()
+	probes[probe.Name()] = probe
+}
+
+func getProbe(name string) (ActivityProbe, bool) {
+	probesLock.RLock()
+	defer probesLock.RUnlock()
+	probe, ok := probes[name]
+	return probe, ok
+}
+
+// probeNames selects the probes of a notebook from its annotation, the
+// notebook type set by the console or the image of the notebook container.
+// Jupyter is the default.
+func probeNames(target Target) []string {
+	if value := target.Meta.GetAnnotations()[ACTIVITY_PROBE_ANNOTATION]; value != "" {
+		var names []string
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	switch target.Meta.GetLabels()[NOTEBOOK_TYPE_LABEL] {
+	case "vscode":
+		return []string{PROBE_CODE_SERVER}
+	case "sd":
+		return []string{PROBE_USAGE}
+	}
+
+	if target.Pod != nil && len(target.Pod.Spec.Containers) > 0 {
+		image := strings.ToLower(target.Pod.Spec.Containers[0].Image)
+		switch {
+		case strings.Contains(image, "code-server") || strings.Contains(image, "vscode"):
+			return []string{PROBE_CODE_SERVER}
+		case strings.Contains(image, "stable-diffusion") || strings.Contains(image, "sd-webui"):
+			return []string{PROBE_USAGE}
+		}
+	}
+	return []string{PROBE_JUPYTER}
+}
+
+// probeActivity runs the probes of the notebook. The notebook is busy when
+// any probe says so and its last activity is the latest one reported. It
+// returns nil when no probe could tell.
+func probeActivity(target Target) *Activity {
+	log := log.WithValues("notebook", getNamespacedNameFromMeta(target.Meta))
+
+	var result *Activity
+	for _, name := range probeNames(target) {
+		probe, ok := getProbe(name)
+		if !ok {
+			log.Info(fmt.Sprintf("Unknown activity probe '%s'", name))
+			continue
+		}
+		activity, err := probe.Probe(target)
+		if err != nil {
+			log.Error(err, fmt.Sprintf("Activity probe '%s' failed", name))
+			continue
+		}
+		if activity == nil {
+			continue
+		}
+		if result == nil {
+			result = &Activity{}
+		}
+		result.Busy = result.Busy || activity.Busy
+		if activity.LastActivity.After(result.LastActivity) {
+			result.LastActivity = activity.LastActivity
+		}
+	}
+	return result
+}
+
+func latest(current time.Time, timestamp string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return current, err
+	}
+	if t.After(current) {
+		return t, nil
+	}
+	return current, nil
+}
+
+// jupyterProbe reads the kernels and terminals of the Jupyter server.
+type jupyterProbe struct{}
+
+func (jupyterProbe) Name() string {
+	return PROBE_JUPYTER
+}
+
+func (jupyterProbe) Probe(target Target) (*Activity, error) {
+	log := log.WithValues("notebook", getNamespacedNameFromMeta(target.Meta))
+	nm, ns := target.Meta.GetName(), target.Meta.GetNamespace()
+
+	kernels, err := getNotebookApiKernels(nm, ns)
+	if err != nil {
+		return nil, err
+	}
+	// terminals may be disabled on the server
+	terminals, err := getNotebookApiTerminals(nm, ns)
+	if err != nil {
+		log.Info(fmt.Sprintf("Could not GET the terminals status: %v", err))
+	}
+
+	if len(kernels) == 0 && len(terminals) == 0 {
+		log.Info("Notebook has no kernels or terminals")
+		return nil, nil
+	}
+
+	if len(kernels) > 0 && !allKernelsAreIdle(kernels, log) {
+		return &Activity{Busy: true}, nil
+	}
+
+	activity := &Activity{}
+	for _, kernel := range kernels {
+		if activity.LastActivity, err = latest(activity.LastActivity, kernel.LastActivity); err != nil {
+			return nil, fmt.Errorf("error parsing the last-activity of kernel %s: %v", kernel.ID, err)
+		}
+	}
+	for _, terminal := range terminals {
+		if activity.LastActivity, err = latest(activity.LastActivity, terminal.LastActivity); err != nil {
+			return nil, fmt.Errorf("error parsing the last-activity of terminal %s: %v", terminal.Name, err)
+		}
+	}
+	return activity, nil
+}
+
+// codeServerHealth is the response of the /healthz endpoint of code-server.
+type codeServerHealth struct {
+	// Status is alive while a client is connected and expired otherwise.
+	Status string `json:"status"`
+	// LastHeartbeat is a unix timestamp in milliseconds.
+	LastHeartbeat int64 `json:"lastHeartbeat"`
+}
+
+// codeServerProbe reads the heartbeat of code-server.
+type codeServerProbe struct{}
+
+func (codeServerProbe) Name() string {
+	return PROBE_CODE_SERVER
+}
+
+func (codeServerProbe) Probe(target Target) (*Activity, error) {
+	health := &codeServerHealth{}
+	url := notebookServiceURL(target.Meta.GetName(), target.Meta.GetNamespace(), "/healthz")
+	if err := getJSON(url, health); err != nil {
+		return nil, err
+	}
+	if health.Status == "alive" {
+		return &Activity{Busy: true}, nil
+	}
+	if health.LastHeartbeat == 0 {
+		return nil, nil
+	}
+	return &Activity{LastActivity: time.Unix(0, health.LastHeartbeat*int64(time.Millisecond))}, nil
+}
+
+// HTTPActivity is the response expected from the endpoint of the http probe.
+type HTTPActivity struct {
+	Busy bool `json:"busy"`
+	// LastActivity is an RFC 3339 timestamp.
+	LastActivity string `json:"last_activity"`
+}
+
+// httpProbe queries the endpoint set by ACTIVITY_ENDPOINT_ANNOTATION, for
+// servers which can report their own activity.
+type httpProbe struct{}
+
+func (httpProbe) Name() string {
+	return PROBE_HTTP
+}
+
+func (httpProbe) Probe(target Target) (*Activity, error) {
+	path := target.Meta.GetAnnotations()[ACTIVITY_ENDPOINT_ANNOTATION]
+	if path == "" {
+		return nil, fmt.Errorf("the http probe needs the %s annotation", ACTIVITY_ENDPOINT_ANNOTATION)
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	response := &HTTPActivity{}
+	if err := getJSON(notebookServiceURL(target.Meta.GetName(), target.Meta.GetNamespace(), path), response); err != nil {
+		return nil, err
+	}
+	if response.Busy {
+		return &Activity{Busy: true}, nil
+	}
+	if response.LastActivity == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, response.LastActivity)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing last_activity: %v", err)
+	}
+	return &Activity{LastActivity: t}, nil
+}
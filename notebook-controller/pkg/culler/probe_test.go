@@ -0,0 +1,330 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProbeNames(t *testing.T) {
+	podWithImage := func(image string) *corev1.Pod {
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Image: image}}}}
+	}
+
+	testCases := []struct {
+		testName string
+		meta     metav1.ObjectMeta
+		pod      *corev1.Pod
+		result   []string
+	}{
+		{
+			testName: "Default is jupyter",
+			pod:      podWithImage("kubeflow/jupyter-scipy:v1"),
+			result:   []string{PROBE_JUPYTER},
+		},
+		{
+			testName: "Annotation",
+			meta: metav1.ObjectMeta{
+				Annotations: map[string]string{ACTIVITY_PROBE_ANNOTATION: "http, usage"},
+				Labels:      map[string]string{NOTEBOOK_TYPE_LABEL: "vscode"},
+			},
+			result: []string{PROBE_HTTP, PROBE_USAGE},
+		},
+		{
+			testName: "VS Code notebook of the console",
+			meta: metav1.ObjectMeta{
+				Labels: map[string]string{NOTEBOOK_TYPE_LABEL: "vscode"},
+			},
+			result: []string{PROBE_CODE_SERVER},
+		},
+		{
+			testName: "code-server image",
+			pod:      podWithImage("codercom/code-server:4.9.1"),
+			result:   []string{PROBE_CODE_SERVER},
+		},
+		{
+			testName: "Stable Diffusion image",
+			pod:      podWithImage("registry.cn-beijing.aliyuncs.com/acs/stable-diffusion-webui:v1"),
+			result:   []string{PROBE_USAGE},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if names := probeNames(Target{Meta: c.meta, Pod: c.pod}); !reflect.DeepEqual(names, c.result) {
+				t.Errorf("Expected %v, got %v", c.result, names)
+			}
+		})
+	}
+}
+
+func TestProbeActivity(t *testing.T) {
+	lastHour := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	lastMinute := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+
+	testCases := []struct {
+		testName  string
+		probe     string
+		responses map[string]string
+		result    *Activity
+	}{
+		{
+			testName: "Jupyter with a busy kernel",
+			probe:    PROBE_JUPYTER,
+			responses: map[string]string{
+				"/notebook/ns/nb/api/kernels": fmt.Sprintf(`[{"id":"1","execution_state":"idle","last_activity":"%s"},{"id":"2","execution_state":"busy","last_activity":"%s"}]`,
+					lastHour.Format(time.RFC3339), lastHour.Format(time.RFC3339)),
+			},
+			result: &Activity{Busy: true},
+		},
+		{
+			testName: "Jupyter with idle kernels and a recent terminal",
+			probe:    PROBE_JUPYTER,
+			responses: map[string]string{
+				"/notebook/ns/nb/api/kernels":   fmt.Sprintf(`[{"id":"1","execution_state":"idle","last_activity":"%s"}]`, lastHour.Format(time.RFC3339)),
+				"/notebook/ns/nb/api/terminals": fmt.Sprintf(`[{"name":"1","last_activity":"%s"}]`, lastMinute.Format(time.RFC3339)),
+			},
+			result: &Activity{LastActivity: lastMinute},
+		},
+		{
+			testName: "Jupyter without kernels nor terminals",
+			probe:    PROBE_JUPYTER,
+			responses: map[string]string{
+				"/notebook/ns/nb/api/kernels": `[]`,
+			},
+			result: nil,
+		},
+		{
+			testName: "Jupyter is not reachable",
+			probe:    PROBE_JUPYTER,
+			result:   nil,
+		},
+		{
+			testName: "code-server with a client connected",
+			probe:    PROBE_CODE_SERVER,
+			responses: map[string]string{
+				"/healthz": fmt.Sprintf(`{"status":"alive","lastHeartbeat":%d}`, lastMinute.UnixNano()/int64(time.Millisecond)),
+			},
+			result: &Activity{Busy: true},
+		},
+		{
+			testName: "code-server without clients",
+			probe:    PROBE_CODE_SERVER,
+			responses: map[string]string{
+				"/healthz": fmt.Sprintf(`{"status":"expired","lastHeartbeat":%d}`, lastHour.UnixNano()/int64(time.Millisecond)),
+			},
+			result: &Activity{LastActivity: lastHour},
+		},
+		{
+			testName: "Generic http endpoint",
+			probe:    PROBE_HTTP,
+			responses: map[string]string{
+				"/activity": fmt.Sprintf(`{"busy":false,"last_activity":"%s"}`, lastMinute.Format(time.RFC3339)),
+			},
+			result: &Activity{LastActivity: lastMinute},
+		},
+		{
+			testName: "Several probes",
+			probe:    PROBE_CODE_SERVER + "," + PROBE_HTTP,
+			responses: map[string]string{
+				"/healthz":  fmt.Sprintf(`{"status":"expired","lastHeartbeat":%d}`, lastHour.UnixNano()/int64(time.Millisecond)),
+				"/activity": fmt.Sprintf(`{"busy":false,"last_activity":"%s"}`, lastMinute.Format(time.RFC3339)),
+			},
+			result: &Activity{LastActivity: lastMinute},
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response, ok := c.responses[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, response)
+			}))
+			defer server.Close()
+
+			defer func(original func(nm, ns, path string) string) { notebookServiceURL = original }(notebookServiceURL)
+			notebookServiceURL = func(nm, ns, path string) string {
+				return server.URL + path
+			}
+
+			target := Target{
+				Meta: metav1.ObjectMeta{
+					Name:      "nb",
+					Namespace: "ns",
+					Annotations: map[string]string{
+						ACTIVITY_PROBE_ANNOTATION:    c.probe,
+						ACTIVITY_ENDPOINT_ANNOTATION: "activity",
+					},
+				},
+			}
+			activity := probeActivity(target)
+			if !sameActivity(activity, c.result) {
+				t.Errorf("Expected %+v, got %+v", c.result, activity)
+			}
+		})
+	}
+}
+
+func TestUsageProbe(t *testing.T) {
+	pod := func(gpu bool) *corev1.Pod {
+		container := corev1.Container{Name: "nb"}
+		if gpu {
+			container.Resources.Limits = corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")}
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "nb-0", Namespace: "ns"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+		}
+	}
+	podMetrics := func(cpu string) string {
+		return fmt.Sprintf(`{"containers":[{"name":"istio-proxy","usage":{"cpu":"2"}},{"name":"nb","usage":{"cpu":"%s","memory":"1Gi"}}]}`, cpu)
+	}
+	const podMetricsPath = "/apis/metrics.k8s.io/v1beta1/namespaces/ns/pods/nb-0"
+	const gpuMetricsPath = "/apis/custom.metrics.k8s.io/v1beta1/namespaces/ns/pods/nb-0/" + DEFAULT_GPU_UTILIZATION_METRIC
+
+	testCases := []struct {
+		testName  string
+		pod       *corev1.Pod
+		responses map[string]string
+		result    *Activity
+		err       bool
+	}{
+		{
+			testName:  "CPU above the threshold",
+			pod:       pod(false),
+			responses: map[string]string{podMetricsPath: podMetrics("1500m")},
+			result:    &Activity{Busy: true},
+		},
+		{
+			testName:  "CPU below the threshold",
+			pod:       pod(false),
+			responses: map[string]string{podMetricsPath: podMetrics("10m")},
+			result:    &Activity{},
+		},
+		{
+			testName: "GPU above the threshold",
+			pod:      pod(true),
+			responses: map[string]string{
+				podMetricsPath: podMetrics("10m"),
+				gpuMetricsPath: `{"items":[{"value":"0"},{"value":"87"}]}`,
+			},
+			result: &Activity{Busy: true},
+		},
+		{
+			testName:  "GPU metric is not available",
+			pod:       pod(true),
+			responses: map[string]string{podMetricsPath: podMetrics("10m")},
+			result:    &Activity{},
+		},
+		{
+			testName: "Metrics API is not available",
+			pod:      pod(false),
+			err:      true,
+		},
+		{
+			testName: "Notebook has no pod",
+			result:   nil,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			probe := NewUsageProbe(func(path string) ([]byte, error) {
+				if response, ok := c.responses[path]; ok {
+					return []byte(response), nil
+				}
+				return nil, errors.New("the server could not find the requested resource")
+			})
+			activity, err := probe.Probe(Target{Pod: c.pod})
+			if (err != nil) != c.err {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !sameActivity(activity, c.result) {
+				t.Errorf("Expected %+v, got %+v", c.result, activity)
+			}
+		})
+	}
+}
+
+func sameActivity(a, b *Activity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Busy == b.Busy && a.LastActivity.Equal(b.LastActivity)
+}
+
+func TestUpdateTimestampFromActivity(t *testing.T) {
+	lastHour := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	lastMinute := time.Now().Add(-time.Minute).Format(time.RFC3339)
+
+	testCases := []struct {
+		testName     string
+		lastActivity string
+		activity     *Activity
+		updated      bool
+	}{
+		{
+			testName:     "Busy notebook",
+			lastActivity: lastHour,
+			activity:     &Activity{Busy: true},
+			updated:      true,
+		},
+		{
+			testName:     "More recent activity",
+			lastActivity: lastHour,
+			activity:     &Activity{LastActivity: time.Now().Add(-time.Minute)},
+			updated:      true,
+		},
+		{
+			testName:     "Older activity does not move last-activity back",
+			lastActivity: lastMinute,
+			activity:     &Activity{LastActivity: time.Now().Add(-time.Hour)},
+			updated:      false,
+		},
+		{
+			testName:     "Idle notebook without a timestamp",
+			lastActivity: lastHour,
+			activity:     &Activity{},
+			updated:      false,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			meta := &metav1.ObjectMeta{Annotations: map[string]string{LAST_ACTIVITY_ANNOTATION: c.lastActivity}}
+			if updated := updateTimestampFromActivity(meta, c.activity); updated != c.updated {
+				t.Errorf("Expected updated %t, got %t", c.updated, updated)
+			}
+			if !c.updated && meta.Annotations[LAST_ACTIVITY_ANNOTATION] != c.lastActivity {
+				t.Errorf("last-activity changed to %s", meta.Annotations[LAST_ACTIVITY_ANNOTATION])
+			}
+		})
+	}
+}
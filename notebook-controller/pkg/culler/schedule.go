@@ -0,0 +1,125 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SCHEDULED_STOP_ANNOTATION is set next to STOP_ANNOTATION when the schedule
+// of a notebook stops it.
+const SCHEDULED_STOP_ANNOTATION = "notebooks.kubeflow.org/scheduled-stop"
+
+// MAX_MISSED_TRANSITIONS bounds how many transitions of a schedule are
+// replayed to find the last one due, e.g. after the controller was down.
+const MAX_MISSED_TRANSITIONS = 1000
+
+// Schedule stops and starts a notebook on cron expressions.
+type Schedule struct {
+	start, stop *cronSchedule
+	location    *time.Location
+}
+
+// ParseSchedule parses the schedule of a notebook, spec must not be nil.
+func ParseSchedule(spec *v1beta1.NotebookSchedule) (*Schedule, error) {
+	s := &Schedule{location: time.UTC}
+	var err error
+	if spec.TimeZone != "" {
+		if s.location, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %v", spec.TimeZone, err)
+		}
+	}
+	if spec.Start != "" {
+		if s.start, err = parseCron(spec.Start); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Stop != "" {
+		if s.stop, err = parseCron(spec.Stop); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Next is the first transition of the schedule after t, TRANSITION_STOPPED or
+// TRANSITION_RESUMED, or nil when there is none. A stop wins over a start
+// scheduled at the same time.
+func (s *Schedule) Next(t time.Time) *v1beta1.NotebookTransition {
+	t = t.In(s.location)
+	var next *v1beta1.NotebookTransition
+	if s.stop != nil {
+		if at := s.stop.next(t); !at.IsZero() {
+			next = &v1beta1.NotebookTransition{Type: TRANSITION_STOPPED, Time: metav1.NewTime(at), Reason: STOP_REASON_SCHEDULED}
+		}
+	}
+	if s.start != nil {
+		if at := s.start.next(t); !at.IsZero() && (next == nil || at.Before(next.Time.Time)) {
+			next = &v1beta1.NotebookTransition{Type: TRANSITION_RESUMED, Time: metav1.NewTime(at)}
+		}
+	}
+	return next
+}
+
+// DueTransition is the last transition of the schedule due by now, starting
+// from the next transition recorded in the status of the notebook, or
+// TRANSITION_NONE when it is not due yet.
+func (s *Schedule) DueTransition(next *v1beta1.NotebookTransition, now time.Time) string {
+	if next == nil || next.Time.After(now) {
+		return TRANSITION_NONE
+	}
+	due := next.Type
+	for i := 0; i < MAX_MISSED_TRANSITIONS; i++ {
+		next = s.Next(next.Time.Time)
+		if next == nil || next.Time.After(now) {
+			break
+		}
+		due = next.Type
+	}
+	return due
+}
+
+// SetScheduledTransitionAnnotations stops or starts a notebook on behalf of
+// its schedule through STOP_ANNOTATION, and tells whether it changed them.
+// A notebook already in the state the transition leads to is left alone, as
+// is a notebook stopped by users or the culler, which the schedule never
+// starts.
+func SetScheduledTransitionAnnotations(meta *metav1.ObjectMeta, transition string) bool {
+	_, scheduled := meta.GetAnnotations()[SCHEDULED_STOP_ANNOTATION]
+	switch {
+	case transition == TRANSITION_STOPPED && !StopAnnotationIsSet(*meta):
+		SetStopAnnotation(meta, nil)
+		meta.Annotations[SCHEDULED_STOP_ANNOTATION] = "true"
+		return true
+	case transition == TRANSITION_RESUMED && StopAnnotationIsSet(*meta) && scheduled:
+		delete(meta.Annotations, STOP_ANNOTATION)
+		return true
+	}
+	return false
+}
+
+// ScheduledTransitionsEqual compares two scheduled transitions, which are
+// the same when they are nil or of the same type at the same time.
+func ScheduledTransitionsEqual(a, b *v1beta1.NotebookTransition) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Type == b.Type && a.Time.Equal(&b.Time)
+}
@@ -0,0 +1,134 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testSchedule(t *testing.T) *Schedule {
+	s, err := ParseSchedule(&v1beta1.NotebookSchedule{Start: "0 8 * * 1-5", Stop: "0 20 * * 1-5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestParseSchedule(t *testing.T) {
+	testCases := []struct {
+		testName string
+		spec     v1beta1.NotebookSchedule
+		valid    bool
+	}{
+		{"Start and stop", v1beta1.NotebookSchedule{Start: "0 8 * * 1-5", Stop: "0 20 * * 1-5"}, true},
+		{"Stop only", v1beta1.NotebookSchedule{Stop: "0 20 * * *"}, true},
+		{"Invalid cron expression", v1beta1.NotebookSchedule{Stop: "0 20 * *"}, false},
+		{"Invalid time zone", v1beta1.NotebookSchedule{Stop: "0 20 * * *", TimeZone: "Mars/Olympus"}, false},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if _, err := ParseSchedule(&c.spec); (err == nil) != c.valid {
+				t.Errorf("expected valid %v, got error %v", c.valid, err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	s := testSchedule(t)
+	// A Friday.
+	friday := time.Date(2021, 8, 27, 12, 0, 0, 0, time.UTC)
+
+	next := s.Next(friday)
+	if next == nil || next.Type != TRANSITION_STOPPED || !next.Time.Time.Equal(time.Date(2021, 8, 27, 20, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a stop on Friday at 20:00, got %+v", next)
+	}
+	next = s.Next(next.Time.Time)
+	if next == nil || next.Type != TRANSITION_RESUMED || !next.Time.Time.Equal(time.Date(2021, 8, 30, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected a start on Monday at 8:00, got %+v", next)
+	}
+
+	if next := (&Schedule{location: time.UTC}).Next(friday); next != nil {
+		t.Errorf("expected no transition without cron expressions, got %+v", next)
+	}
+}
+
+func TestDueTransition(t *testing.T) {
+	s := testSchedule(t)
+	stopAt := &v1beta1.NotebookTransition{
+		Type: TRANSITION_STOPPED,
+		Time: metav1.NewTime(time.Date(2021, 8, 27, 20, 0, 0, 0, time.UTC)),
+	}
+
+	testCases := []struct {
+		testName   string
+		next       *v1beta1.NotebookTransition
+		now        time.Time
+		transition string
+	}{
+		{"No transition recorded", nil, time.Date(2021, 8, 27, 21, 0, 0, 0, time.UTC), TRANSITION_NONE},
+		{"Not due yet", stopAt, time.Date(2021, 8, 27, 19, 59, 0, 0, time.UTC), TRANSITION_NONE},
+		{"Due", stopAt, time.Date(2021, 8, 27, 20, 0, 30, 0, time.UTC), TRANSITION_STOPPED},
+		{"Missed the stop", stopAt, time.Date(2021, 8, 29, 12, 0, 0, 0, time.UTC), TRANSITION_STOPPED},
+		{"Missed the stop and the start", stopAt, time.Date(2021, 8, 30, 9, 0, 0, 0, time.UTC), TRANSITION_RESUMED},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if got := s.DueTransition(c.next, c.now); got != c.transition {
+				t.Errorf("expected transition %q, got %q", c.transition, got)
+			}
+		})
+	}
+}
+
+func TestSetScheduledTransitionAnnotations(t *testing.T) {
+	meta := &metav1.ObjectMeta{}
+	if !SetScheduledTransitionAnnotations(meta, TRANSITION_STOPPED) {
+		t.Fatal("expected the running notebook to be stopped")
+	}
+	if !StopAnnotationIsSet(*meta) || meta.Annotations[SCHEDULED_STOP_ANNOTATION] == "" {
+		t.Errorf("expected the stop and scheduled stop annotations, got %v", meta.Annotations)
+	}
+	if SetScheduledTransitionAnnotations(meta, TRANSITION_STOPPED) {
+		t.Error("expected the stopped notebook to be left alone")
+	}
+
+	status := &v1beta1.NotebookStatus{}
+	MarkStopped(*meta, status, time.Now())
+	if status.StopReason != STOP_REASON_SCHEDULED {
+		t.Errorf("expected stop reason %q, got %q", STOP_REASON_SCHEDULED, status.StopReason)
+	}
+
+	if !SetScheduledTransitionAnnotations(meta, TRANSITION_RESUMED) || StopAnnotationIsSet(*meta) {
+		t.Errorf("expected the stopped notebook to be started, got %v", meta.Annotations)
+	}
+	if SetScheduledTransitionAnnotations(meta, TRANSITION_RESUMED) {
+		t.Error("expected the running notebook to be left alone")
+	}
+
+	// A notebook stopped by its user stays stopped.
+	stopped := &metav1.ObjectMeta{}
+	SetStopAnnotation(stopped, nil)
+	if SetScheduledTransitionAnnotations(stopped, TRANSITION_RESUMED) || !StopAnnotationIsSet(*stopped) {
+		t.Errorf("expected the notebook stopped by its user to be left alone, got %v", stopped.Annotations)
+	}
+}
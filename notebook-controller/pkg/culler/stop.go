@@ -0,0 +1,161 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/pkg/metrics"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CULLING_REASON_ANNOTATION is set next to STOP_ANNOTATION when the culler
+// stops a notebook. A stop annotation without it or SCHEDULED_STOP_ANNOTATION
+// was set by a user.
+const CULLING_REASON_ANNOTATION = "notebooks.kubeflow.org/culling-reason"
+
+// Why a notebook was stopped, reported in the status of the notebook.
+const (
+	STOP_REASON_USER      = "User"
+	STOP_REASON_CULLED    = "Culled"
+	STOP_REASON_SCHEDULED = "Scheduled"
+)
+
+// Conditions the controller adds to a notebook when it is stopped or resumed,
+// next to the Running|Waiting|Terminated conditions of its container.
+const (
+	CONDITION_STOPPED  = "Stopped"
+	CONDITION_CULLED   = "Culled"
+	CONDITION_STARTING = "Starting"
+)
+
+// Stop and resume transitions kept in the status of a notebook.
+const (
+	TRANSITION_NONE    = ""
+	TRANSITION_STOPPED = "Stopped"
+	TRANSITION_RESUMED = "Resumed"
+)
+
+// MAX_TRANSITIONS is how many stop and resume transitions a notebook keeps.
+const MAX_TRANSITIONS = 10
+
+// SetCullingStopAnnotations stops a notebook on behalf of the culler.
+func SetCullingStopAnnotations(meta *metav1.ObjectMeta, reason string, m *metrics.Metrics) {
+	SetStopAnnotation(meta, m)
+	if meta == nil {
+		return
+	}
+	meta.Annotations[CULLING_REASON_ANNOTATION] = reason
+}
+
+// NextStopTransition compares the stop annotation of a notebook with its
+// status and tells whether it was stopped or resumed since the last reconcile.
+func NextStopTransition(meta metav1.ObjectMeta, status *v1beta1.NotebookStatus) string {
+	stopped := StopAnnotationIsSet(meta)
+	if stopped && status.StoppedAt == nil {
+		return TRANSITION_STOPPED
+	}
+	if !stopped && status.StoppedAt != nil {
+		return TRANSITION_RESUMED
+	}
+	return TRANSITION_NONE
+}
+
+// MarkStopped records in the status that the notebook was stopped, by whom
+// and when. The stop time is read from STOP_ANNOTATION, now is used when it
+// is not a RFC3339 timestamp.
+func MarkStopped(meta metav1.ObjectMeta, status *v1beta1.NotebookStatus, now time.Time) {
+	stoppedAt := now
+	if t, err := time.Parse(time.RFC3339, meta.GetAnnotations()[STOP_ANNOTATION]); err == nil {
+		stoppedAt = t
+	}
+
+	condition := v1beta1.NotebookCondition{
+		Type:          CONDITION_STOPPED,
+		LastProbeTime: metav1.NewTime(now),
+		Reason:        STOP_REASON_USER,
+		Message:       "Notebook stopped by a user",
+	}
+	transition := v1beta1.NotebookTransition{
+		Type:   TRANSITION_STOPPED,
+		Reason: STOP_REASON_USER,
+		Time:   metav1.NewTime(stoppedAt),
+	}
+	if reason, ok := meta.GetAnnotations()[CULLING_REASON_ANNOTATION]; ok {
+		condition.Type = CONDITION_CULLED
+		condition.Reason = reason
+		condition.Message = fmt.Sprintf("Notebook stopped by the culling policy: %s", reason)
+		transition.Reason = STOP_REASON_CULLED
+		transition.Message = reason
+	} else if _, ok := meta.GetAnnotations()[SCHEDULED_STOP_ANNOTATION]; ok {
+		condition.Reason = STOP_REASON_SCHEDULED
+		condition.Message = "Notebook stopped by its schedule"
+		transition.Reason = STOP_REASON_SCHEDULED
+	}
+
+	status.StoppedAt = &transition.Time
+	status.StopReason = transition.Reason
+	// The Culled or Stopped condition replaces the culling condition.
+	ClearCullingStatus(status)
+	status.Conditions = append([]v1beta1.NotebookCondition{condition}, status.Conditions...)
+	addTransition(status, transition)
+}
+
+// ResetAnnotationsForResume drops the annotations a stopped notebook should
+// not carry over once it is resumed, so its idle time starts over.
+func ResetAnnotationsForResume(meta *metav1.ObjectMeta) bool {
+	changed := false
+	for _, key := range []string{CULLING_REASON_ANNOTATION, SCHEDULED_STOP_ANNOTATION, LAST_ACTIVITY_ANNOTATION} {
+		if _, ok := meta.GetAnnotations()[key]; ok {
+			delete(meta.Annotations, key)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// MarkResumed records in the status that the notebook is starting again. The
+// container state is reset so the state of the new pod is reported.
+func MarkResumed(status *v1beta1.NotebookStatus, now time.Time) {
+	condition := v1beta1.NotebookCondition{
+		Type:          CONDITION_STARTING,
+		LastProbeTime: metav1.NewTime(now),
+		Reason:        TRANSITION_RESUMED,
+		Message:       "Notebook resumed",
+	}
+
+	status.StoppedAt = nil
+	status.StopReason = ""
+	status.ContainerState = corev1.ContainerState{}
+	status.Conditions = append([]v1beta1.NotebookCondition{condition}, status.Conditions...)
+	addTransition(status, v1beta1.NotebookTransition{
+		Type: TRANSITION_RESUMED,
+		Time: metav1.NewTime(now),
+	})
+}
+
+// addTransition prepends a transition and drops the oldest ones past
+// MAX_TRANSITIONS.
+func addTransition(status *v1beta1.NotebookStatus, transition v1beta1.NotebookTransition) {
+	transitions := append([]v1beta1.NotebookTransition{transition}, status.Transitions...)
+	if len(transitions) > MAX_TRANSITIONS {
+		transitions = transitions[:MAX_TRANSITIONS]
+	}
+	status.Transitions = transitions
+}
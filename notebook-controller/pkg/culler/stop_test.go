@@ -0,0 +1,182 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNextStopTransition(t *testing.T) {
+	stoppedAt := metav1.Now()
+	stopped := metav1.ObjectMeta{Annotations: map[string]string{STOP_ANNOTATION: stoppedAt.Format(time.RFC3339)}}
+
+	testCases := []struct {
+		testName   string
+		meta       metav1.ObjectMeta
+		status     v1beta1.NotebookStatus
+		transition string
+	}{
+		{
+			testName:   "Running notebook",
+			transition: TRANSITION_NONE,
+		},
+		{
+			testName:   "Notebook just stopped",
+			meta:       stopped,
+			transition: TRANSITION_STOPPED,
+		},
+		{
+			testName:   "Stopped notebook",
+			meta:       stopped,
+			status:     v1beta1.NotebookStatus{StoppedAt: &stoppedAt},
+			transition: TRANSITION_NONE,
+		},
+		{
+			testName:   "Notebook just resumed",
+			status:     v1beta1.NotebookStatus{StoppedAt: &stoppedAt},
+			transition: TRANSITION_RESUMED,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			if got := NextStopTransition(c.meta, &c.status); got != c.transition {
+				t.Errorf("expected transition %q, got %q", c.transition, got)
+			}
+		})
+	}
+}
+
+func TestMarkStopped(t *testing.T) {
+	now := time.Now()
+	stoppedAt := now.Add(-time.Minute).Truncate(time.Second)
+	running := v1beta1.NotebookCondition{Type: "Running"}
+
+	testCases := []struct {
+		testName    string
+		annotations map[string]string
+		condition   string
+		reason      string
+		stoppedAt   time.Time
+	}{
+		{
+			testName:    "Stopped by a user",
+			annotations: map[string]string{STOP_ANNOTATION: stoppedAt.Format(time.RFC3339)},
+			condition:   CONDITION_STOPPED,
+			reason:      STOP_REASON_USER,
+			stoppedAt:   stoppedAt,
+		},
+		{
+			testName: "Culled",
+			annotations: map[string]string{
+				STOP_ANNOTATION:           stoppedAt.Format(time.RFC3339),
+				CULLING_REASON_ANNOTATION: CULLING_REASON_IDLE,
+			},
+			condition: CONDITION_CULLED,
+			reason:    STOP_REASON_CULLED,
+			stoppedAt: stoppedAt,
+		},
+		{
+			testName:    "Stop annotation without a timestamp",
+			annotations: map[string]string{STOP_ANNOTATION: "true"},
+			condition:   CONDITION_STOPPED,
+			reason:      STOP_REASON_USER,
+			stoppedAt:   now,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.testName, func(t *testing.T) {
+			scheduledAt := metav1.NewTime(now)
+			status := v1beta1.NotebookStatus{
+				Conditions:         []v1beta1.NotebookCondition{running, {Type: CONDITION_CULLING_SCHEDULED}},
+				CullingScheduledAt: &scheduledAt,
+				CullingReason:      CULLING_REASON_IDLE,
+			}
+			MarkStopped(metav1.ObjectMeta{Annotations: c.annotations}, &status, now)
+
+			if status.StoppedAt == nil || !status.StoppedAt.Time.Equal(c.stoppedAt) {
+				t.Errorf("expected stoppedAt %v, got %v", c.stoppedAt, status.StoppedAt)
+			}
+			if status.StopReason != c.reason {
+				t.Errorf("expected stop reason %q, got %q", c.reason, status.StopReason)
+			}
+			// The condition goes on top, the CullingScheduled one is removed.
+			if len(status.Conditions) != 2 || status.Conditions[0].Type != c.condition || status.Conditions[1].Type != running.Type {
+				t.Errorf("expected %s condition on top of %v", c.condition, status.Conditions)
+			}
+			if len(status.Transitions) != 1 || status.Transitions[0].Type != TRANSITION_STOPPED {
+				t.Errorf("expected a single Stopped transition, got %v", status.Transitions)
+			}
+			if status.CullingScheduledAt != nil || status.CullingReason != "" {
+				t.Errorf("expected culling status to be cleared, got %v", status)
+			}
+		})
+	}
+}
+
+func TestMarkResumed(t *testing.T) {
+	now := time.Now()
+	stoppedAt := metav1.NewTime(now.Add(-time.Hour))
+	transitions := make([]v1beta1.NotebookTransition, MAX_TRANSITIONS)
+	for i := range transitions {
+		transitions[i] = v1beta1.NotebookTransition{Type: TRANSITION_STOPPED, Time: stoppedAt}
+	}
+	status := v1beta1.NotebookStatus{
+		StoppedAt:      &stoppedAt,
+		StopReason:     STOP_REASON_USER,
+		ContainerState: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+		Transitions:    transitions,
+	}
+
+	MarkResumed(&status, now)
+
+	if status.StoppedAt != nil || status.StopReason != "" {
+		t.Errorf("expected stop status to be cleared, got %v", status)
+	}
+	if status.ContainerState.Running != nil {
+		t.Errorf("expected container state to be reset, got %v", status.ContainerState)
+	}
+	if len(status.Conditions) != 1 || status.Conditions[0].Type != CONDITION_STARTING {
+		t.Errorf("expected a Starting condition, got %v", status.Conditions)
+	}
+	if len(status.Transitions) != MAX_TRANSITIONS || status.Transitions[0].Type != TRANSITION_RESUMED {
+		t.Errorf("expected %d transitions starting with Resumed, got %v", MAX_TRANSITIONS, status.Transitions)
+	}
+}
+
+func TestResetAnnotationsForResume(t *testing.T) {
+	meta := metav1.ObjectMeta{Annotations: map[string]string{
+		CULLING_REASON_ANNOTATION: CULLING_REASON_IDLE,
+		LAST_ACTIVITY_ANNOTATION:  createTimestamp(),
+		"keep":                    "me",
+	}}
+
+	if !ResetAnnotationsForResume(&meta) {
+		t.Errorf("expected annotations to change")
+	}
+	if len(meta.Annotations) != 1 || meta.Annotations["keep"] != "me" {
+		t.Errorf("expected only unrelated annotations to remain, got %v", meta.Annotations)
+	}
+	if ResetAnnotationsForResume(&meta) {
+		t.Errorf("expected no change on the second call")
+	}
+}
@@ -0,0 +1,159 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package culler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const DEFAULT_ACTIVITY_CPU_THRESHOLD = "100m"
+const DEFAULT_ACTIVITY_GPU_THRESHOLD = "5"
+const DEFAULT_GPU_UTILIZATION_METRIC = "DCGM_FI_DEV_GPU_UTIL"
+
+// MetricsGetter returns the document at an absolute path of the API server,
+// the usage probe reads the resource and custom metrics APIs with it.
+type MetricsGetter func(path string) ([]byte, error)
+
+type podMetrics struct {
+	Containers []struct {
+		Name  string              `json:"name"`
+		Usage corev1.ResourceList `json:"usage"`
+	} `json:"containers"`
+}
+
+type metricValueList struct {
+	Items []struct {
+		Value resource.Quantity `json:"value"`
+	} `json:"items"`
+}
+
+// usageProbe considers a notebook busy while its container uses more CPU, or
+// its GPUs more utilization, than a threshold. It suits servers which do not
+// report their activity, such as Stable Diffusion web UIs.
+type usageProbe struct {
+	get MetricsGetter
+}
+
+// NewUsageProbe creates the usage probe. CPU usage comes from the
+// metrics.k8s.io API and GPU utilization from the custom.metrics.k8s.io API.
+func NewUsageProbe(get MetricsGetter) ActivityProbe {
+	return &usageProbe{get: get}
+}
+
+func (p *usageProbe) Name() string {
+	return PROBE_USAGE
+}
+
+func (p *usageProbe) Probe(target Target) (*Activity, error) {
+	log := log.WithValues("notebook", getNamespacedNameFromMeta(target.Meta))
+	if target.Pod == nil || len(target.Pod.Spec.Containers) == 0 {
+		return nil, nil
+	}
+	pod := target.Pod
+
+	cpu, err := p.cpuUsage(pod.Namespace, pod.Name, pod.Spec.Containers[0].Name)
+	if err != nil {
+		return nil, err
+	}
+	if threshold := getCPUThreshold(); cpu.Cmp(threshold) >= 0 {
+		log.Info(fmt.Sprintf("Notebook uses %s CPU", cpu.String()))
+		return &Activity{Busy: true}, nil
+	}
+
+	if PodSpecRequestsGPU(&pod.Spec) {
+		utilization, err := p.gpuUtilization(pod.Namespace, pod.Name)
+		if err != nil {
+			// the custom metrics API is optional
+			log.Info(fmt.Sprintf("Could not get the GPU utilization: %v", err))
+		} else if utilization >= getGPUThreshold() {
+			log.Info(fmt.Sprintf("Notebook uses %.1f%% of its GPUs", utilization))
+			return &Activity{Busy: true}, nil
+		}
+	}
+
+	// an idle notebook tells nothing about when it was last used
+	return &Activity{}, nil
+}
+
+func (p *usageProbe) cpuUsage(ns, pod, container string) (resource.Quantity, error) {
+	usage := resource.Quantity{}
+	data, err := p.get(fmt.Sprintf("/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods/%s", ns, pod))
+	if err != nil {
+		return usage, err
+	}
+	metrics := &podMetrics{}
+	if err := json.Unmarshal(data, metrics); err != nil {
+		return usage, err
+	}
+	for _, c := range metrics.Containers {
+		if c.Name == container {
+			return c.Usage[corev1.ResourceCPU], nil
+		}
+	}
+	return usage, fmt.Errorf("no metrics for container %s of pod %s/%s", container, ns, pod)
+}
+
+// gpuUtilization returns the highest utilization, in percent, of the GPUs of the pod.
+func (p *usageProbe) gpuUtilization(ns, pod string) (float64, error) {
+	metric := getEnvDefault("GPU_UTILIZATION_METRIC", DEFAULT_GPU_UTILIZATION_METRIC)
+	data, err := p.get(fmt.Sprintf("/apis/custom.metrics.k8s.io/v1beta1/namespaces/%s/pods/%s/%s", ns, pod, metric))
+	if err != nil {
+		return 0, err
+	}
+	values := &metricValueList{}
+	if err := json.Unmarshal(data, values); err != nil {
+		return 0, err
+	}
+	if len(values.Items) == 0 {
+		return 0, fmt.Errorf("no %s metric for pod %s/%s", metric, ns, pod)
+	}
+	var utilization float64
+	for _, item := range values.Items {
+		if v := float64(item.Value.MilliValue()) / 1000; v > utilization {
+			utilization = v
+		}
+	}
+	return utilization, nil
+}
+
+func getCPUThreshold() resource.Quantity {
+	value := getEnvDefault("ACTIVITY_CPU_THRESHOLD", DEFAULT_ACTIVITY_CPU_THRESHOLD)
+	threshold, err := resource.ParseQuantity(value)
+	if err != nil {
+		log.Info(fmt.Sprintf(
+			"ACTIVITY_CPU_THRESHOLD should be a quantity. Got '%s'. Using default value.",
+			value))
+		threshold = resource.MustParse(DEFAULT_ACTIVITY_CPU_THRESHOLD)
+	}
+	return threshold
+}
+
+func getGPUThreshold() float64 {
+	value := getEnvDefault("ACTIVITY_GPU_THRESHOLD", DEFAULT_ACTIVITY_GPU_THRESHOLD)
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Info(fmt.Sprintf(
+			"ACTIVITY_GPU_THRESHOLD should be a number. Got '%s'. Using default value.",
+			value))
+		threshold, _ = strconv.ParseFloat(DEFAULT_ACTIVITY_GPU_THRESHOLD, 64)
+	}
+	return threshold
+}
@@ -31,6 +31,13 @@ type Metrics struct {
 	NotebookFailCreation     *prometheus.CounterVec
 	NotebookCullingCount     *prometheus.CounterVec
 	NotebookCullingTimestamp *prometheus.GaugeVec
+	NotebookCullingEvents    *prometheus.CounterVec
+	NotebookRequestedCPU     *prometheus.GaugeVec
+	NotebookRequestedMemory  *prometheus.GaugeVec
+	NotebookRequestedGPU     *prometheus.GaugeVec
+	NotebookGPUHours         *prometheus.GaugeVec
+	NotebookIdleSeconds      *prometheus.GaugeVec
+	NotebookState            *prometheus.GaugeVec
 }
 
 func NewMetrics(cli client.Client) *Metrics {
@@ -71,6 +78,55 @@ func NewMetrics(cli client.Client) *Metrics {
 			},
 			[]string{"namespace", "name"},
 		),
+		NotebookCullingEvents: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "notebook_culling_events_total",
+				Help: "Total times notebooks were warned, snoozed or culled",
+			},
+			[]string{"namespace", "name", "action"},
+		),
+		NotebookRequestedCPU: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_requested_cpu_cores",
+				Help: "CPU cores requested by notebooks",
+			},
+			[]string{"namespace", "name", "owner"},
+		),
+		NotebookRequestedMemory: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_requested_memory_bytes",
+				Help: "Memory requested by notebooks in bytes",
+			},
+			[]string{"namespace", "name", "owner"},
+		),
+		NotebookRequestedGPU: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_requested_gpus",
+				Help: "GPUs requested by notebooks",
+			},
+			[]string{"namespace", "name", "owner"},
+		),
+		NotebookGPUHours: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_gpu_hours",
+				Help: "GPU hours used by notebooks while running",
+			},
+			[]string{"namespace", "name", "owner"},
+		),
+		NotebookIdleSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_idle_seconds",
+				Help: "Time since the last activity of notebooks in seconds",
+			},
+			[]string{"namespace", "name", "owner"},
+		),
+		NotebookState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "notebook_state",
+				Help: "Whether notebooks are running or stopped",
+			},
+			[]string{"namespace", "name", "owner", "state"},
+		),
 	}
 
 	metrics.Registry.MustRegister(m)
@@ -81,13 +137,32 @@ func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
 	m.runningNotebooks.Describe(ch)
 	m.NotebookCreation.Describe(ch)
 	m.NotebookFailCreation.Describe(ch)
+	m.NotebookCullingCount.Describe(ch)
+	m.NotebookCullingTimestamp.Describe(ch)
+	m.NotebookCullingEvents.Describe(ch)
+	m.NotebookRequestedCPU.Describe(ch)
+	m.NotebookRequestedMemory.Describe(ch)
+	m.NotebookRequestedGPU.Describe(ch)
+	m.NotebookGPUHours.Describe(ch)
+	m.NotebookIdleSeconds.Describe(ch)
+	m.NotebookState.Describe(ch)
 }
 
 func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
 	m.scrape()
+	m.scrapeUsage()
 	m.runningNotebooks.Collect(ch)
 	m.NotebookCreation.Collect(ch)
 	m.NotebookFailCreation.Collect(ch)
+	m.NotebookCullingCount.Collect(ch)
+	m.NotebookCullingTimestamp.Collect(ch)
+	m.NotebookCullingEvents.Collect(ch)
+	m.NotebookRequestedCPU.Collect(ch)
+	m.NotebookRequestedMemory.Collect(ch)
+	m.NotebookRequestedGPU.Collect(ch)
+	m.NotebookGPUHours.Collect(ch)
+	m.NotebookIdleSeconds.Collect(ch)
+	m.NotebookState.Collect(ch)
 }
 
 func (m *Metrics) scrape() {
@@ -0,0 +1,167 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Resources a notebook is accounted for.
+const (
+	ResourceCPU       = "cpu"
+	ResourceMemory    = "memory"
+	ResourceGPU       = "gpu"
+	ResourceGPUMemory = "gpuMemory"
+)
+
+var gpuResourceNames = []corev1.ResourceName{"nvidia.com/gpu", "aliyun.com/gpu"}
+
+const gpuMemoryResourceName = corev1.ResourceName("aliyun.com/gpu-mem")
+
+// Same as culler.LAST_ACTIVITY_ANNOTATION, the culler imports this package.
+const lastActivityAnnotation = "notebooks.kubeflow.org/last-activity"
+
+// Labels holding the user a notebook was created for, by order of preference.
+// The console sets User, with the @ of the user name replaced by a dash.
+var ownerLabels = []string{"User", "userName", "arena.kubeflow.org/console-user"}
+
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+)
+
+// NotebookResources sums the resources of the containers of a notebook, their
+// limits or their requests when they have no limit.
+func NotebookResources(podSpec *corev1.PodSpec) map[string]*resource.Quantity {
+	total := map[string]*resource.Quantity{
+		ResourceCPU:       resource.NewQuantity(0, resource.DecimalSI),
+		ResourceMemory:    resource.NewQuantity(0, resource.BinarySI),
+		ResourceGPU:       resource.NewQuantity(0, resource.DecimalSI),
+		ResourceGPUMemory: resource.NewQuantity(0, resource.DecimalSI),
+	}
+	get := func(c *corev1.Container, name corev1.ResourceName) (resource.Quantity, bool) {
+		if q, ok := c.Resources.Limits[name]; ok {
+			return q, true
+		}
+		q, ok := c.Resources.Requests[name]
+		return q, ok
+	}
+	for i := range podSpec.Containers {
+		c := &podSpec.Containers[i]
+		if q, ok := get(c, corev1.ResourceCPU); ok {
+			total[ResourceCPU].Add(q)
+		}
+		if q, ok := get(c, corev1.ResourceMemory); ok {
+			total[ResourceMemory].Add(q)
+		}
+		for _, name := range gpuResourceNames {
+			if q, ok := get(c, name); ok {
+				total[ResourceGPU].Add(q)
+			}
+		}
+		if q, ok := get(c, gpuMemoryResourceName); ok {
+			total[ResourceGPUMemory].Add(q)
+		}
+	}
+	return total
+}
+
+// NotebookOwner is the user a notebook was created for, empty when unknown.
+func NotebookOwner(meta metav1.ObjectMeta) string {
+	for _, label := range ownerLabels {
+		if owner := meta.GetLabels()[label]; owner != "" {
+			return owner
+		}
+	}
+	return ""
+}
+
+// RunningSince is when the current run of a notebook started, when it was
+// last resumed or else created. It is zero for a stopped notebook.
+func RunningSince(notebook *v1beta1.Notebook) time.Time {
+	if notebook.Status.StoppedAt != nil {
+		return time.Time{}
+	}
+	// The newest transition of a notebook that is not stopped is its resume.
+	if len(notebook.Status.Transitions) > 0 {
+		return notebook.Status.Transitions[0].Time.Time
+	}
+	return notebook.CreationTimestamp.Time
+}
+
+// GPUSeconds is the GPU time the notebook uses between from and to.
+func GPUSeconds(notebook *v1beta1.Notebook, from, to time.Time) int64 {
+	if from.IsZero() || !to.After(from) {
+		return 0
+	}
+	gpus := NotebookResources(&notebook.Spec.Template.Spec)[ResourceGPU].MilliValue()
+	return gpus * int64(to.Sub(from)/time.Millisecond) / 1000 / 1000
+}
+
+// scrapeUsage rebuilds the per-notebook gauges from the notebooks, so they
+// survive restarts of the controller and drop deleted notebooks.
+func (m *Metrics) scrapeUsage() {
+	notebooks := &v1beta1.NotebookList{}
+	if err := m.cli.List(context.TODO(), notebooks); err != nil {
+		return
+	}
+	m.resetUsage()
+	for i := range notebooks.Items {
+		m.setUsage(&notebooks.Items[i], time.Now())
+	}
+}
+
+func (m *Metrics) resetUsage() {
+	m.NotebookRequestedCPU.Reset()
+	m.NotebookRequestedMemory.Reset()
+	m.NotebookRequestedGPU.Reset()
+	m.NotebookGPUHours.Reset()
+	m.NotebookIdleSeconds.Reset()
+	m.NotebookState.Reset()
+}
+
+func (m *Metrics) setUsage(notebook *v1beta1.Notebook, now time.Time) {
+	owner := NotebookOwner(notebook.ObjectMeta)
+	resources := NotebookResources(&notebook.Spec.Template.Spec)
+	m.NotebookRequestedCPU.WithLabelValues(notebook.Namespace, notebook.Name, owner).
+		Set(float64(resources[ResourceCPU].MilliValue()) / 1000)
+	m.NotebookRequestedMemory.WithLabelValues(notebook.Namespace, notebook.Name, owner).
+		Set(float64(resources[ResourceMemory].Value()))
+	m.NotebookRequestedGPU.WithLabelValues(notebook.Namespace, notebook.Name, owner).
+		Set(float64(resources[ResourceGPU].MilliValue()) / 1000)
+
+	gpuSeconds := notebook.Status.GPUSeconds + GPUSeconds(notebook, RunningSince(notebook), now)
+	m.NotebookGPUHours.WithLabelValues(notebook.Namespace, notebook.Name, owner).
+		Set(float64(gpuSeconds) / 3600)
+
+	if t, err := time.Parse(time.RFC3339, notebook.GetAnnotations()[lastActivityAnnotation]); err == nil {
+		m.NotebookIdleSeconds.WithLabelValues(notebook.Namespace, notebook.Name, owner).
+			Set(now.Sub(t).Seconds())
+	}
+
+	running, stopped := 1.0, 0.0
+	if notebook.Status.StoppedAt != nil {
+		running, stopped = 0, 1
+	}
+	m.NotebookState.WithLabelValues(notebook.Namespace, notebook.Name, owner, StateRunning).Set(running)
+	m.NotebookState.WithLabelValues(notebook.Namespace, notebook.Name, owner, StateStopped).Set(stopped)
+}
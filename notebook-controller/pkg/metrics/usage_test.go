@@ -0,0 +1,136 @@
+/*
+*Copyright (c) 2021, Alibaba Group;
+*Licensed under the Apache License, Version 2.0 (the "License");
+*you may not use this file except in compliance with the License.
+*You may obtain a copy of the License at
+
+*   http://www.apache.org/licenses/LICENSE-2.0
+
+*Unless required by applicable law or agreed to in writing, software
+*distributed under the License is distributed on an "AS IS" BASIS,
+*WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+*See the License for the specific language governing permissions and
+*limitations under the License.
+*/
+    
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AliyunContainerService/data-on-ack/notebook-controller/api/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var testNow = time.Date(2021, 8, 30, 12, 0, 0, 0, time.UTC)
+
+func testNotebook(gpus string, transitions ...v1beta1.NotebookTransition) *v1beta1.Notebook {
+	notebook := &v1beta1.Notebook{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "test-notebook",
+			Namespace:         "test-namespace",
+			Labels:            map[string]string{"User": "alice"},
+			CreationTimestamp: metav1.NewTime(testNow.Add(-10 * time.Hour)),
+		},
+	}
+	notebook.Spec.Template.Spec.Containers = []corev1.Container{{
+		Name: "test-notebook",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+			Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse(gpus)},
+		},
+	}}
+	notebook.Status.Transitions = transitions
+	return notebook
+}
+
+func TestRunningSince(t *testing.T) {
+	resumedAt := metav1.NewTime(testNow.Add(-time.Hour))
+	stopped := testNotebook("1", v1beta1.NotebookTransition{Type: "Stopped", Time: resumedAt})
+	stopped.Status.StoppedAt = &resumedAt
+
+	tests := []struct {
+		name     string
+		notebook *v1beta1.Notebook
+		expected time.Time
+	}{
+		{"never stopped", testNotebook("1"), testNow.Add(-10 * time.Hour)},
+		{"resumed", testNotebook("1", v1beta1.NotebookTransition{Type: "Resumed", Time: resumedAt}), resumedAt.Time},
+		{"stopped", stopped, time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if since := RunningSince(test.notebook); !since.Equal(test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, since)
+			}
+		})
+	}
+}
+
+func TestGPUSeconds(t *testing.T) {
+	tests := []struct {
+		name     string
+		gpus     string
+		from     time.Time
+		expected int64
+	}{
+		{"two GPUs for an hour", "2", testNow.Add(-time.Hour), 7200},
+		{"no GPU", "0", testNow.Add(-time.Hour), 0},
+		{"stopped", "2", time.Time{}, 0},
+		{"clock skew", "2", testNow.Add(time.Minute), 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if seconds := GPUSeconds(testNotebook(test.gpus), test.from, testNow); seconds != test.expected {
+				t.Errorf("expected %d GPU seconds, got %d", test.expected, seconds)
+			}
+		})
+	}
+}
+
+func gaugeValue(t *testing.T, vec *prometheus.GaugeVec, labels ...string) float64 {
+	metric := &dto.Metric{}
+	if err := vec.WithLabelValues(labels...).Write(metric); err != nil {
+		t.Fatal(err)
+	}
+	return metric.GetGauge().GetValue()
+}
+
+func TestSetUsage(t *testing.T) {
+	m := NewMetrics(nil)
+
+	notebook := testNotebook("1", v1beta1.NotebookTransition{Type: "Resumed", Time: metav1.NewTime(testNow.Add(-time.Hour))})
+	notebook.Status.GPUSeconds = 3600
+	notebook.Annotations = map[string]string{lastActivityAnnotation: testNow.Add(-5 * time.Minute).Format(time.RFC3339)}
+	m.setUsage(notebook, testNow)
+
+	labels := []string{"test-namespace", "test-notebook", "alice"}
+	for _, test := range []struct {
+		name     string
+		vec      *prometheus.GaugeVec
+		labels   []string
+		expected float64
+	}{
+		{"cpu", m.NotebookRequestedCPU, labels, 0.5},
+		{"memory", m.NotebookRequestedMemory, labels, 1 << 30},
+		{"gpu", m.NotebookRequestedGPU, labels, 1},
+		{"gpu hours of this and the previous runs", m.NotebookGPUHours, labels, 2},
+		{"idle", m.NotebookIdleSeconds, labels, 300},
+		{"running", m.NotebookState, append(labels, StateRunning), 1},
+		{"stopped", m.NotebookState, append(labels, StateStopped), 0},
+	} {
+		if value := gaugeValue(t, test.vec, test.labels...); value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, value)
+		}
+	}
+}